@@ -15,6 +15,8 @@ package main
 
 import "sourcemod"
 
+//go2sp:forward OnClientCookiesCached
+
 type (
 	CookieAccess int
 	CookieMenu int
@@ -15,6 +15,8 @@ package main
 
 import "sourcemod"
 
+//go2sp:forward CS_OnBuyCommand CS_OnCSWeaponDrop CS_OnGetWeaponPrice CS_OnTerminateRound
+
 
 type (
 	CSRoundEndReason int
@@ -29,9 +29,13 @@ func (DataPack) WriteCell(cell any, insert bool)
 func (DataPack) WriteFloat(val float, insert bool)
 func (DataPack) WriteString(val string, insert bool)
 func (DataPack) WriteFunction(fktptr Function, insert bool)
+func (DataPack) WriteCellArray(array any, count int, insert bool)
+func (DataPack) WriteFloatArray(array any, count int, insert bool)
 func (DataPack) ReadCell() any
 func (DataPack) ReadFloat() float
 func (DataPack) ReadString(buffer []char, maxlen int)
 func (DataPack) ReadFunction() Function
+func (DataPack) ReadCellArray(buffer any, count int)
+func (DataPack) ReadFloatArray(buffer any, count int)
 func (DataPack) Reset(clear bool)
 func (DataPack) IsReadable(unused int) bool
\ No newline at end of file
@@ -38,6 +38,10 @@ const (
 	OptFlagForce
 	OptFlagNoCompile
 	OptFlagVerbose
+	OptFlagOptimize
+	OptFlagDebugRuntime
+	OptFlagTrace
+	OptFlagProfile
 	
 	ErrStr string = "[ERROR]"
 	WrnStr string = "[WARNING]"
@@ -50,13 +54,17 @@ func DoImports(dir string, file *ast.File, fset *token.FileSet, pkgs map[string]
 	var ast_files []*ast.File
 	ast_files = append(ast_files, file)
 	for _, imp := range file.Imports {
+		if ASTMod.IsShimPackage(strings.Replace(imp.Path.Value, `"`, "", -1)) {
+			/// shim packages are provided by SourceGo itself.
+			continue
+		}
 		file_to_import := dir + "/" + strings.Replace(imp.Path.Value, `"`, "", -1) + ".go"
 		if _, ok := pkgs[file_to_import]; ok {
 			/// prevent multiple importing.
 			continue
 		}
 		
-		imp_ast, imp_err := parser.ParseFile(fset, file_to_import, nil, parser.DeclarationErrors)
+		imp_ast, imp_err := parser.ParseFile(fset, file_to_import, nil, parser.DeclarationErrors | parser.ParseComments)
 		if imp_err != nil {
 			switch err_type := imp_err.(type) {
 				case *os.PathError:
@@ -83,47 +91,80 @@ func main() {
 	var opts int
 	for _, argStr := range srcgo_args {
 		var bad_compile bool
+		if strings.HasPrefix(argStr, "--strbuf=") {
+			/// size of the char buffers made for string concatenation.
+			if _, scan_err := fmt.Sscanf(argStr, "--strbuf=%d", &ASTMod.ASTCtxt.StrBufLen); scan_err != nil || ASTMod.ASTCtxt.StrBufLen==0 {
+				fmt.Printf(FmtStr, "bad string buffer size: '" + argStr + "'", ErrStr)
+				ASTMod.ASTCtxt.StrBufLen = 256
+			}
+			continue
+		}
 		switch argStr {
 			case "--debug", "-d":
 				opts |= OptFlagDebug
 			case "-f", "--force", "--force-gen":
 				opts |= OptFlagForce
 			case "--help", "-h":
-				fmt.Println("SourceGo Usage: " + os.Args[0] + " [options] files... | options: [--debug, --force, --help, --version, --no-spcomp, --verbose]")
+				fmt.Println("SourceGo Usage: " + os.Args[0] + " [options] files... | options: [--debug, --force, --help, --version, --no-spcomp, --verbose, --optimize, --debug-runtime[=trace], --profile, --strbuf=N]")
 			case "--version":
 				fmt.Println("SourceGo version: v1.4b")
 			case "--verbose", "-v":
 				opts |= OptFlagVerbose
 			case "--no-spcomp", "-n":
 				opts |= OptFlagNoCompile
+			case "--optimize", "-O":
+				opts |= OptFlagOptimize
+			case "--debug-runtime":
+				opts |= OptFlagDebugRuntime
+			case "--debug-runtime=trace":
+				opts |= OptFlagDebugRuntime | OptFlagTrace
+			case "--profile":
+				opts |= OptFlagProfile
 			default:
 				new_file_name := fmt.Sprintf("%s.sp", argStr)
 				fset := token.NewFileSet()
 				code, read_err := ioutil.ReadFile(argStr)
 				CheckErr(read_err)
 				/// parse the file and get a File AST Node.
-				file_ast, parse_err := parser.ParseFile(fset, argStr, code, parser.AllErrors)
+				file_ast, parse_err := parser.ParseFile(fset, argStr, code, parser.AllErrors | parser.ParseComments)
 				if parse_err != nil {
 					for _, e := range parse_err.(scanner.ErrorList) {
 						fmt.Println(e)
 					}
 					bad_compile = true
 				} else {
+					ASTMod.AddProfilerImport(file_ast, opts & OptFlagProfile > 0)
 					dir, _ := os.Getwd()
 					pkgs := make(map[string]*ast.File)
 					ast_files := DoImports(dir, file_ast, fset, pkgs)
+					ASTMod.CollectForwards(ast_files)
 					
 					var typeErrs, transpileErrs []error
 					conf := types.Config{
-						Importer: importer.Default(),
+						Importer: ASTMod.SrcGoImporter{Default: importer.Default()},
 						DisableUnusedImportCheck: true,
 						Error: func(err error) {
 							if strings.Contains(err.Error(), "could not import") {
+							} else if strings.Contains(err.Error(), "cannot range over") {
+								/// only StringMap and ArrayList ranges get lowered to for-loops.
+								if strings.Contains(err.Error(), "type StringMap)") || strings.Contains(err.Error(), "type ArrayList)") {
+									if opts & OptFlagVerbose > 0 {
+										fmt.Printf(FmtStr, err, WrnStr)
+									}
+								} else {
+									typeErrs = append(typeErrs, err)
+									bad_compile = true
+								}
 							} else if strings.Contains(err.Error(), "cannot convert") || strings.Contains(err.Error(), "variable of type") || strings.Contains(err.Error(), "value of type") || strings.Contains(err.Error(), "too few arguments in call") {
 								if opts & OptFlagVerbose > 0 {
 									fmt.Printf(FmtStr, err, WrnStr)
 								}
-							} else if strings.Contains(err.Error(), "declared but not used") {
+							} else if IsStrBufMismatch(err.Error()) {
+								/// string buffers are compared and concatenated with the string natives.
+								if opts & OptFlagVerbose > 0 {
+									fmt.Printf(FmtStr, err, WrnStr)
+								}
+							} else if strings.Contains(err.Error(), "declared but not used") || strings.Contains(err.Error(), "declared and not used") {
 								fmt.Printf(FmtStr, err, WrnStr)
 							} else {
 								typeErrs = append(typeErrs, err)
@@ -137,7 +178,8 @@ func main() {
 						Uses:       make(map[*ast.Ident]types.Object),
 						Implicits:  make(map[ast.Node]types.Object),
 						//Scopes:     make(map[ast.Node]*types.Scope),
-						//Selections: make(map[*ast.SelectorExpr]*types.Selection),
+						Selections: make(map[*ast.SelectorExpr]*types.Selection),
+						Instances:  make(map[*ast.Ident]types.Instance),
 					}
 					
 					/// initialize our transpiler.
@@ -149,23 +191,84 @@ func main() {
 					/// first step: Analyze for illegal golang constructs.
 					ASTMod.AnalyzeIllegalCode(file_ast)
 					
-					ASTMod.NameAnonFuncs(file_ast)
+					ASTMod.MutateAsyncRets(file_ast)
 					
-					/// Do initial type-check of the File AST Node so we can get type information.
-					if _, err := conf.Check(``, fset, ast_files, info); err != nil {
+					/// passes re-check what they generate, their new type errors are printed once each.
+					printed := make(map[string]bool)
+					type_check := func() {
+						conf.Check(``, fset, ast_files, info)
 						for _, e := range typeErrs {
-							fmt.Printf(FmtStr, e, ErrStr)
+							if !printed[e.Error()] {
+								printed[e.Error()] = true
+								fmt.Printf(FmtStr, e, ErrStr)
+							}
 						}
 					}
 					
+					/// Do initial type-check of the File AST Node so we can get type information.
+					type_check()
+					
+					/// function literals are hoisted with the locals they capture, type-check the hoisted functions.
+					if ASTMod.NameAnonFuncs(file_ast) {
+						type_check()
+					}
+					
+					/// embedded structs are flattened, so they can't be used as values.
+					ASTMod.CheckEmbeddedValues(file_ast)
+					
+					/// specialized generics can instantiate more generics, so type-check them until there's none left.
+					for ASTMod.MutateGenerics(file_ast) {
+						type_check()
+					}
+					
+					/// ConVar structs become ConVars with cached globals, type-check the globals.
+					if ASTMod.MutateConVars(file_ast) {
+						type_check()
+					}
+					
+					/// async functions become state machines, type-check them to type their states.
+					if ASTMod.MutateAsyncFuncs(file_ast) {
+						type_check()
+					}
+					
+					/// errors are lowered to bool results with message buffers, type-check their new signatures.
+					if ASTMod.MutateErrors(file_ast) {
+						type_check()
+					}
+					
+					/// inlined bodies can call more inline functions, type-check them until there's none left.
+					for ASTMod.MutateInlines(file_ast, opts & OptFlagOptimize > 0) {
+						type_check()
+					}
+					
+					ASTMod.MutateMethodValues(file_ast)
+					
+					ASTMod.MutateInterfaces(file_ast)
+					
+					ASTMod.MutateGoStmts(file_ast)
+					
+					ASTMod.MutateGlobalInits(file_ast)
+					
 					ASTMod.MergeRetVals(file_ast)
 					
 					ASTMod.ChangeRecvrNames(file_ast)
 					
+					ASTMod.MutateStmtInits(file_ast)
+					
+					ASTMod.MutateSwitches(file_ast)
+					
+					ASTMod.MutateForInits(file_ast)
+					
 					ASTMod.MutateAndNotExpr(file_ast)
 					
 					ASTMod.MutateRets(file_ast)
 					
+					ASTMod.MutateFmtCalls(file_ast)
+					
+					ASTMod.MutateVariadics(file_ast)
+					
+					ASTMod.MutateStructLits(file_ast)
+					
 					ASTMod.MutateAssignDefs(file_ast)
 					
 					ASTMod.MutateAssigns(file_ast)
@@ -174,16 +277,29 @@ func main() {
 					
 					ASTMod.MutateNoRetCalls(file_ast)
 					
-					/// TODO: for for-loop inits that have multiple vars.
-					//ASTMod.MutateForInits(file_ast)
-					
 					//ASTMod.MutateMaps(file_ast)
 					
+					ASTMod.MutateCommands(file_ast)
+					
+					ASTMod.MutateProfiling(file_ast, opts & OptFlagProfile > 0)
+					
+					/// debug builds check indices, handles and entities at runtime.
+					if opts & OptFlagDebugRuntime > 0 {
+						ASTMod.MutateDebugRuntime(file_ast, opts & OptFlagTrace > 0)
+					}
+					
 					for _, e := range transpileErrs {
 						fmt.Printf(FmtStr, e, ErrStr)
 					}
 					
-					conf.Check(``, fset, ast_files, info)
+					type_check()
+					if opts & OptFlagOptimize > 0 {
+						ASTMod.FoldConstants(file_ast)
+						ASTMod.SimplifyNegations(file_ast)
+						ASTMod.RemoveDeadCode(file_ast)
+						ASTMod.RemoveUnusedDecls(file_ast)
+					}
+					
 					if opts & OptFlagDebug > 0 {
 						WriteToFile(fmt.Sprintf("%s_AST.txt",   argStr), ASTMod.PrintAST(file_ast))
 						WriteToFile(fmt.Sprintf("%s_output.go", argStr), ASTMod.PrettyPrintAST(file_ast))
@@ -209,6 +325,26 @@ func main() {
 	}
 }
 
+/// '[N]char' compared or concatenated with a string or another '[N]char' is lowered to the string natives.
+func IsStrBufMismatch(msg string) bool {
+	const mismatch = "(mismatched types "
+	start := strings.LastIndex(msg, mismatch)
+	if start < 0 {
+		return false
+	}
+	operands := strings.SplitN(strings.TrimSuffix(msg[start+len(mismatch):], ")"), " and ", 2)
+	if len(operands) != 2 {
+		return false
+	}
+	is_buf := func(t string) bool {
+		return strings.HasPrefix(t, "[") && strings.HasSuffix(t, "]char")
+	}
+	is_str := func(t string) bool {
+		return is_buf(t) || t=="string" || t=="untyped string"
+	}
+	return (is_buf(operands[0]) || is_buf(operands[1])) && is_str(operands[0]) && is_str(operands[1])
+}
+
 func CheckErr(e error) {
 	if e != nil {
 		panic(e)
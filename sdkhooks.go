@@ -15,6 +15,8 @@ package main
 
 import "sourcemod/types"
 
+//go2sp:forward OnEntityCreated OnEntityDestroyed OnGetGameDescription OnLevelInit
+
 const (
 	DMG_GENERIC                 = 0          /**< generic damage was done */
 	DMG_CRUSH                   = (1 << 0)    /**< crushed by falling or moving object.
@@ -13,6 +13,8 @@
 
 package main
 
+//go2sp:forward OnPlayerRunCmd OnPlayerRunCmdPost OnFileSend OnFileReceive OnClientSpeaking OnClientSpeakingEnd
+
 
 import (
 	"sourcemod/core"
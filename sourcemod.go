@@ -13,6 +13,8 @@
 
 package main
 
+//go2sp:forward OnPluginStart OnPluginEnd OnPluginPauseChange OnGameFrame OnMapInit OnMapStart OnMapEnd OnConfigsExecuted OnAutoConfigsBuffered OnAllPluginsLoaded OnLibraryAdded OnLibraryRemoved OnClientFloodCheck OnClientFloodResult AskPluginLoad2 OnNotifyPluginUnloaded OnServerEnterHibernation OnServerExitHibernation
+
 
 import (
 	"sourcemod/core"
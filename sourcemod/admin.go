@@ -13,6 +13,8 @@
 
 package main
 
+//go2sp:forward OnRebuildAdminCache
+
 
 type AdminFlag int
 const (
@@ -13,6 +13,8 @@
 
 package main
 
+//go2sp:forward OnBanClient OnBanIdentity OnRemoveBan
+
 
 const (
 	BANFLAG_AUTO =        (1<<0)  /**< Auto-detects whether to ban by steamid or IP */
@@ -13,6 +13,8 @@
 
 package main
 
+//go2sp:forward OnClientConnect OnClientConnected OnClientPutInServer OnClientDisconnect OnClientDisconnect_Post OnClientCommand OnClientCommandKeyValues OnClientCommandKeyValues_Post OnClientSettingsChanged OnClientAuthorized OnClientPreAdminCheck OnClientPostAdminFilter OnClientPostAdminCheck OnMaxPlayersChanged OnClientLanguageChanged
+
 
 type NetFlow int
 const (
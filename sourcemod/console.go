@@ -13,6 +13,8 @@
 
 package main
 
+//go2sp:forward OnClientSayCommand OnClientSayCommand_Post
+
 
 const INVALID_FCVAR_FLAGS = -1
 
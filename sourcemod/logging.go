@@ -13,6 +13,8 @@
 
 package main
 
+//go2sp:forward OnLogAction
+
 
 func LogMessage(format string, args ...any)
 func LogToFile(file, format string, args ...any)
@@ -13,6 +13,8 @@
 
 package main
 
+//go2sp:forward OnMapTimeLeftChanged
+
 
 const (
 	TIMER_REPEAT            = (1<<0)      /**< Timer will repeat until it returns Plugin_Stop */
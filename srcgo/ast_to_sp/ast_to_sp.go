@@ -16,6 +16,7 @@ package GoToSPGen
 
 import (
 	"strings"
+	"strconv"
 	"fmt"
 	"unicode"
 	//"bytes"
@@ -23,7 +24,7 @@ import (
 	"go/ast"
 	"go/types"
 	//"go/format"
-	//"go/constant"
+	"go/constant"
 	"../ast_transform"
 )
 
@@ -36,6 +37,14 @@ var (
 	IdenNames = map[string]string{
 		"nil":  "null",
 	}
+	
+	/// for '//go2sp:round mode' on functions that convert floats to ints.
+	RoundNatives = map[string]string{
+		"zero":    "RoundToZero",
+		"floor":   "RoundToFloor",
+		"ceil":    "RoundToCeil",
+		"nearest": "RoundToNearest",
+	}
 )
 
 const (
@@ -77,13 +86,14 @@ type (
 	
 	MethodMap struct {
 		Methods, Props []FuncBlock
-		Name string
+		Name, Parent string
 	}
 	
 	SMPlugin struct {
 		Includes, Globals []string
 		Structs map[string]EStruct
-		//MethodMaps []MethodMap
+		StructOrder []string
+		MethodMaps []MethodMap
 		Funcs []FuncBlock
 	}
 )
@@ -132,10 +142,15 @@ func (ts TypeString) Join(param, is_ref, is_array bool) string {
  * SP ret    ->         TypeName ([])
  */
 func GetTypeString(expr ast.Expr, name string, param bool) string {
+	return MakeTypeString(ASTMod.ASTCtxt.TypeInfo.TypeOf(expr), name, param, expr.Pos())
+}
+
+func MakeTypeString(typ types.Type, name string, param bool, pos token.Pos) string {
 	ts := TypeString{Name: name}
 	var is_ref, is_array bool
-	if typ := ASTMod.ASTCtxt.TypeInfo.TypeOf(expr); typ != nil {
+	if typ != nil {
 	recheck:
+		typ = types.Unalias(typ)
 		original := typ.String()
 		type_name := strings.Replace(original, "untyped ", "", -1)
 		if strings.HasPrefix(type_name, "func(") {
@@ -184,7 +199,7 @@ func GetTypeString(expr ast.Expr, name string, param bool) string {
 				if !is_ref {
 					is_ref = true
 				} else {
-					ASTMod.PrintSrcGoErr(expr.Pos(), "Multi-Pointers are Illegal.")
+					ASTMod.PrintSrcGoErr(pos, "Multi-Pointers are Illegal.")
 				}
 				typ = t.Elem()
 				goto recheck
@@ -219,6 +234,15 @@ func IsSameType(a ast.Expr, b []ast.Expr, param bool) bool {
 func WriteParams(flist *ast.FieldList) []string {
 	param_list := make([]string, 0)
 	for _, parm := range flist.List {
+		if ellipsis, is_variadic := parm.Type.(*ast.Ellipsis); is_variadic {
+			/// SourcePawn varargs are unnamed and only reachable through 'VFormat'.
+			if ASTMod.IsBasicStringExpr(ellipsis.Elt) {
+				param_list = append(param_list, "const char[] ...")
+			} else {
+				param_list = append(param_list, "any ...")
+			}
+			continue
+		}
 		for _, name := range parm.Names {
 			param_list = append(param_list, GetTypeString(parm.Type, name.Name, true))
 		}
@@ -229,8 +253,21 @@ func WriteParams(flist *ast.FieldList) []string {
 func WriteStructMembs(flist *ast.FieldList) []string {
 	field_list := make([]string, 0)
 	for _, field := range flist.List {
+		if field.Names==nil {
+			/// embedded structs are flattened into the enum struct.
+			typ := ASTMod.ASTCtxt.TypeInfo.TypeOf(field.Type)
+			if struc, is_struct := typ.Underlying().(*types.Struct); is_struct {
+				field_list = append(field_list, WriteEmbeddedMembs(struc, field.Pos())...)
+			} else {
+				field_list = append(field_list, MakeTypeString(typ, GetTypeString(field.Type, "", false), false, field.Pos()))
+			}
+			continue
+		}
 		for _, member_name := range field.Names {
 			field_str := GetTypeString(field.Type, member_name.Name, false)
+			if ASTMod.IsBasicStringExpr(field.Type) {
+				field_str = SizeStrBuf(field_str)
+			}
 			field_list = append(field_list, field_str)
 		}
 	}
@@ -238,6 +275,127 @@ func WriteStructMembs(flist *ast.FieldList) []string {
 }
 
 
+func WriteEmbeddedMembs(struc *types.Struct, pos token.Pos) []string {
+	field_list := make([]string, 0)
+	for i := 0; i < struc.NumFields(); i++ {
+		field := struc.Field(i)
+		if embedded, is_struct := field.Type().Underlying().(*types.Struct); field.Embedded() && is_struct {
+			field_list = append(field_list, WriteEmbeddedMembs(embedded, pos)...)
+		} else if basic, is_basic := field.Type().Underlying().(*types.Basic); is_basic && basic.Kind()==types.String {
+			field_list = append(field_list, SizeStrBuf(MakeTypeString(field.Type(), field.Name(), false, pos)))
+		} else {
+			field_list = append(field_list, MakeTypeString(field.Type(), field.Name(), false, pos))
+		}
+	}
+	return field_list
+}
+
+/// 'char name[]' needs a size outside of params.
+func SizeStrBuf(type_str string) string {
+	return strings.TrimSuffix(type_str, "[]") + fmt.Sprintf("[%d]", ASTMod.ASTCtxt.StrBufLen)
+}
+
+/// gets every field name of a struct, with embedded structs flattened.
+func GetFlatFieldNames(struc *types.Struct) []string {
+	names := make([]string, 0)
+	for i := 0; i < struc.NumFields(); i++ {
+		field := struc.Field(i)
+		if embedded, is_struct := field.Type().Underlying().(*types.Struct); field.Embedded() && is_struct {
+			names = append(names, GetFlatFieldNames(embedded)...)
+		} else {
+			names = append(names, field.Name())
+		}
+	}
+	return names
+}
+
+/**
+ * Methods promoted from an embedded struct are forwarded by copying into a temporary of the embedded type.
+ * type Player struct { Entity }
+ * 
+ * void Teleport(const float pos[3]) {
+ *     Entity base;
+ *     base.Origin = this.Origin;
+ *     base.Teleport(pos);
+ *     this.Origin = base.Origin; /// only for pointer receivers.
+ * }
+ */
+func (plugin *SMPlugin) MakePromotedMethods(type_spec *ast.TypeSpec) {
+	obj, found := ASTMod.ASTCtxt.TypeInfo.Defs[type_spec.Name]
+	if !found || obj==nil {
+		return
+	}
+	named, is_named := obj.Type().(*types.Named)
+	if !is_named {
+		return
+	}
+	struc, is_struct := named.Underlying().(*types.Struct)
+	if !is_struct {
+		return
+	}
+	
+	outer := plugin.Structs[type_spec.Name.Name]
+	method_set := types.NewMethodSet(types.NewPointer(named))
+	for i := 0; i < method_set.Len(); i++ {
+		sel := method_set.At(i)
+		if len(sel.Index()) < 2 {
+			continue
+		}
+		embedded_field := struc.Field(sel.Index()[0])
+		embedded_struc, is_embedded_struct := embedded_field.Type().Underlying().(*types.Struct)
+		if !is_embedded_struct {
+			continue
+		}
+		embedded_name := types.Unalias(embedded_field.Type()).(*types.Named).Obj().Name()
+		
+		var base_method *FuncBlock
+		if inner, found := plugin.Structs[embedded_name]; found {
+			for j := range inner.Methods {
+				if inner.Methods[j].Name==sel.Obj().Name() {
+					base_method = &inner.Methods[j]
+					break
+				}
+			}
+		}
+		if base_method==nil {
+			continue
+		}
+		
+		method := sel.Obj().(*types.Func)
+		sig := method.Type().(*types.Signature)
+		args := make([]string, 0)
+		for j := 0; j < sig.Params().Len(); j++ {
+			args = append(args, sig.Params().At(j).Name())
+		}
+		
+		fn := FuncBlock{ Tabs: 1, Params: base_method.Params, RetType: base_method.RetType, Name: base_method.Name }
+		tabstr, tabstrone := WriteTabStr(1), WriteTabStr(2)
+		fields := GetFlatFieldNames(embedded_struc)
+		fn.Body.WriteString("\n" + tabstr + "{")
+		fn.Body.WriteString("\n" + tabstrone + embedded_name + " base;")
+		for _, field := range fields {
+			fn.Body.WriteString("\n" + tabstrone + "base." + field + " = this." + field + ";")
+		}
+		call := "base." + fn.Name + "(" + strings.Join(args, ", ") + ")"
+		if fn.RetType != "void" {
+			fn.Body.WriteString("\n" + tabstrone + fn.RetType + " result = " + call + ";")
+		} else {
+			fn.Body.WriteString("\n" + tabstrone + call + ";")
+		}
+		if _, ptr_recv := sig.Recv().Type().(*types.Pointer); ptr_recv {
+			for _, field := range fields {
+				fn.Body.WriteString("\n" + tabstrone + "this." + field + " = base." + field + ";")
+			}
+		}
+		if fn.RetType != "void" {
+			fn.Body.WriteString("\n" + tabstrone + "return result;")
+		}
+		fn.Body.WriteString("\n" + tabstr + "}")
+		outer.Methods = append(outer.Methods, fn)
+	}
+	plugin.Structs[type_spec.Name.Name] = outer
+}
+
 func GeneratePluginFile(file *ast.File) string {
 	var plugin_src_code strings.Builder
 	plugin := SMPlugin{Structs: make(map[string]EStruct)}
@@ -245,7 +403,9 @@ func GeneratePluginFile(file *ast.File) string {
 	ast.Inspect(file, func(n ast.Node) bool {
 		if n != nil {
 			if imp, is_import := n.(*ast.ImportSpec); is_import {
-				if imp.Path.Value[1] == '.' {
+				if ASTMod.IsShimPackage(imp.Path.Value[1 : len(imp.Path.Value)-1]) {
+					return true
+				} else if imp.Path.Value[1] == '.' {
 					plugin.Includes = append(plugin.Includes, `#include "` + imp.Path.Value[2:] + `"`)
 				} else {
 					plugin.Includes = append(plugin.Includes, "#include <" + imp.Path.Value[1 : len(imp.Path.Value)-1] + ">")
@@ -283,8 +443,33 @@ func GeneratePluginFile(file *ast.File) string {
 	}
 	plugin_src_code.WriteString("\n")
 	
+	for _, d := range file.Decls {
+		switch decl := d.(type) {
+			case *ast.GenDecl:
+				switch decl.Tok {
+					case token.VAR:
+						for _, spec := range decl.Specs {
+							plugin.Globals = append(plugin.Globals, MakeVarSpec(spec.(*ast.ValueSpec), 0))
+						}
+				}
+			case *ast.FuncDecl:
+				plugin.MakeFuncDecl(decl)
+		}
+	}
+	
+	/// methods have to be generated first before they can be promoted.
+	ast.Inspect(file, func(n ast.Node) bool {
+		if type_spec, is_type_spec := n.(*ast.TypeSpec); is_type_spec {
+			if _, is_struct := type_spec.Type.(*ast.StructType); is_struct {
+				plugin.MakePromotedMethods(type_spec)
+			}
+		}
+		return true
+	})
+	
 	single_tab := WriteTabStr(1)
-	for name, struc := range plugin.Structs {
+	for _, name := range plugin.StructOrder {
+		struc := plugin.Structs[name]
 		plugin_src_code.WriteString(fmt.Sprintf("enum struct %s {", name))
 		for _, field := range struc.Fields {
 			plugin_src_code.WriteString("\n" + single_tab + field + ";")
@@ -303,18 +488,24 @@ func GeneratePluginFile(file *ast.File) string {
 		plugin_src_code.WriteString("\n}\n\n")
 	}
 	
-	for _, d := range file.Decls {
-		switch decl := d.(type) {
-			case *ast.GenDecl:
-				switch decl.Tok {
-					case token.VAR:
-						for _, spec := range decl.Specs {
-							plugin.Globals = append(plugin.Globals, MakeVarSpec(spec.(*ast.ValueSpec), 0))
-						}
-				}
-			case *ast.FuncDecl:
-				plugin.MakeFuncDecl(decl)
+	for _, methodmap := range plugin.MethodMaps {
+		if methodmap.Methods==nil {
+			continue
+		}
+		plugin_src_code.WriteString("methodmap " + methodmap.Name)
+		if methodmap.Parent != "" {
+			plugin_src_code.WriteString(" < " + methodmap.Parent)
 		}
+		plugin_src_code.WriteString(" {\n")
+		for i, method := range methodmap.Methods {
+			plugin_src_code.WriteString(single_tab + "public " + method.RetType + " " + method.Name + "(")
+			plugin_src_code.WriteString(strings.Join(method.Params, ", "))
+			plugin_src_code.WriteString(")" + method.Body.String())
+			if i+1 != len(methodmap.Methods) {
+				plugin_src_code.WriteString("\n")
+			}
+		}
+		plugin_src_code.WriteString("\n}\n\n")
 	}
 	
 	plugin_src_code.WriteString("\n")
@@ -389,14 +580,20 @@ func MakeVarSpec(var_spec *ast.ValueSpec, tabs uint) string {
 	var var_str strings.Builder
 	if var_spec.Type != nil {
 		for i, name := range var_spec.Names {
-			var_str.WriteString(tabstr + GetTypeString(var_spec.Type, name.Name, false))
+			type_str := GetTypeString(var_spec.Type, name.Name, false)
+			if (var_spec.Values==nil || i >= len(var_spec.Values)) && ASTMod.IsBasicStringExpr(var_spec.Type) {
+				/// 'char name[];' needs a size.
+				type_str = SizeStrBuf(type_str)
+			}
+			var_str.WriteString(tabstr + type_str)
 			if var_spec.Values != nil && i < len(var_spec.Values) {
 				switch val := var_spec.Values[i].(type) {
 					case *ast.CompositeLit:
-						var_str.WriteString(" = {")
-						for n, expr := range val.Elts {
-							var_str.WriteString(tabstrone + GetExprString(expr))
-							if n+1 != len(val.Elts) {
+						var_str.WriteString(" = {\n")
+						elts := GetCompositeElts(val)
+						for n, elt := range elts {
+							var_str.WriteString(tabstrone + elt)
+							if n+1 != len(elts) {
 								var_str.WriteString(",")
 							}
 							var_str.WriteString("\n")
@@ -415,9 +612,10 @@ func MakeVarSpec(var_spec *ast.ValueSpec, tabs uint) string {
 				switch val := value.(type) {
 					case *ast.CompositeLit:
 						var_str.WriteString(" = {\n")
-						for n, expr := range val.Elts {
-							var_str.WriteString(tabstrone + GetExprString(expr))
-							if n+1 != len(val.Elts) {
+						elts := GetCompositeElts(val)
+						for n, elt := range elts {
+							var_str.WriteString(tabstrone + elt)
+							if n+1 != len(elts) {
 								var_str.WriteString(",")
 							}
 							var_str.WriteString("\n")
@@ -439,6 +637,23 @@ func (plugin *SMPlugin) MakeTypeSpec(type_spec *ast.TypeSpec) {
 			plugin.Structs[type_spec.Name.Name] = EStruct{
 				Fields:  WriteStructMembs(t.Fields),
 			}
+			plugin.StructOrder = append(plugin.StructOrder, type_spec.Name.Name)
+		
+		case *ast.Ident, *ast.SelectorExpr:
+			/// named types with methods become methodmaps, Handle-based ones inherit their methods.
+			if type_spec.Assign.IsValid() {
+				break
+			}
+			typ := ASTMod.ASTCtxt.TypeInfo.TypeOf(t)
+			if typ==nil || types.IsInterface(typ) {
+				/// placeholders like 'type Database any' are declared by includes.
+				break
+			}
+			methodmap := MethodMap{Name: type_spec.Name.Name}
+			if basic, is_basic := typ.(*types.Basic); !is_basic || basic.Info() & types.IsString > 0 {
+				methodmap.Parent = strings.TrimSpace(GetTypeString(t, "", false))
+			}
+			plugin.MethodMaps = append(plugin.MethodMaps, methodmap)
 		
 		case *ast.FuncType:
 			var func_type strings.Builder
@@ -463,6 +678,8 @@ func (plugin *SMPlugin) MakeTypeSpec(type_spec *ast.TypeSpec) {
 }
 
 func (plugin *SMPlugin) MakeFuncDecl(f *ast.FuncDecl) {
+	ASTMod.ASTCtxt.CurrFunc = f
+	defer func() { ASTMod.ASTCtxt.CurrFunc = nil }()
 	fn := FuncBlock{}
 	if f.Type.Results != nil {
 		fn.RetType = GetTypeString(f.Type.Results.List[0].Type, "", false)
@@ -483,7 +700,11 @@ func (plugin *SMPlugin) MakeFuncDecl(f *ast.FuncDecl) {
 		fn.Tabs = 0
 	}
 	
-	if f.Body != nil {
+	if _, internal := ASTMod.GetDirective(f.Doc, "internal"); internal && f.Body != nil {
+		/// generated helpers nothing outside the plugin calls.
+		fn.Storage = "static"
+		fn.MakeStmts(f.Body.List, GENFLAG_NEWLINE | GENFLAG_SEMICOLON)
+	} else if f.Body != nil {
 		fn.Storage = "public"
 		fn.MakeStmts(f.Body.List, GENFLAG_NEWLINE | GENFLAG_SEMICOLON)
 	} else {
@@ -496,6 +717,13 @@ func (plugin *SMPlugin) MakeFuncDecl(f *ast.FuncDecl) {
 		if struc, ok := plugin.Structs[struct_type]; ok {
 			struc.Methods = append(struc.Methods, fn)
 			plugin.Structs[struct_type] = struc
+		} else if f.Body != nil {
+			for i := range plugin.MethodMaps {
+				if plugin.MethodMaps[i].Name==struct_type {
+					plugin.MethodMaps[i].Methods = append(plugin.MethodMaps[i].Methods, fn)
+					break
+				}
+			}
 		}
 	} else {
 		plugin.Funcs = append(plugin.Funcs, fn)
@@ -545,16 +773,22 @@ func (cb *FuncBlock) MakeStmt(stmt ast.Stmt, flags int) {
 						case *ast.CompositeLit:
 							cb.Body.WriteString(tabstr + GetTypeString(n.Lhs[i], var_name.Name, false) +" = {\n")
 							tabstrone := WriteTabStr(cb.Tabs + 1)
-							for n, expr := range exp.Elts {
-								cb.Body.WriteString(tabstrone + GetExprString(expr))
-								if n+1 != len(exp.Elts) {
+							elts := GetCompositeElts(exp)
+							for n, elt := range elts {
+								cb.Body.WriteString(tabstrone + elt)
+								if n+1 != len(elts) {
 									cb.Body.WriteString(",")
 								}
 								cb.Body.WriteString("\n")
 							}
 							cb.Body.WriteString(tabstr + "}")
 						default:
-							cb.Body.WriteString(tabstr + GetTypeString(n.Lhs[i], var_name.Name, false) + " = " + GetExprString(n.Rhs[i]))
+							type_expr := n.Lhs[i]
+							if call, is_call := n.Rhs[i].(*ast.CallExpr); is_call && ASTMod.IsTypeConversion(call) {
+								/// conversions Go rejects, like int(bool), leave the var untyped.
+								type_expr = call.Fun
+							}
+							cb.Body.WriteString(tabstr + GetTypeString(type_expr, var_name.Name, false) + " = " + GetExprString(n.Rhs[i]))
 					}
 					if flags & GENFLAG_SEMICOLON > 0 {
 						cb.Body.WriteString(";")
@@ -573,7 +807,12 @@ func (cb *FuncBlock) MakeStmt(stmt ast.Stmt, flags int) {
 							cb.Body.WriteString(";")
 						}
 						if i+1 != left_len {
-							cb.Body.WriteString("\n")
+							if flags & GENFLAG_NEWLINE > 0 {
+								cb.Body.WriteString("\n")
+							} else {
+								/// for-loop headers.
+								cb.Body.WriteString(", ")
+							}
 						}
 					}
 				} else if rite_len==1 && left_len >= rite_len {
@@ -644,7 +883,17 @@ func (cb *FuncBlock) MakeStmt(stmt ast.Stmt, flags int) {
 		
 		case *ast.ForStmt:
 			cb.Body.WriteString(tabstr + "for (")
-			if n.Init != nil {
+			if init, is_assign := n.Init.(*ast.AssignStmt); is_assign && init.Tok==token.DEFINE && len(init.Lhs) > 1 {
+				/// same-typed vars, 'int i = 0, j = n - 1'.
+				for i := range init.Lhs {
+					if i==0 {
+						cb.Body.WriteString(GetTypeString(init.Lhs[i], GetExprString(init.Lhs[i]), false))
+					} else {
+						cb.Body.WriteString(", " + GetExprString(init.Lhs[i]))
+					}
+					cb.Body.WriteString(" = " + GetExprString(init.Rhs[i]))
+				}
+			} else if n.Init != nil {
 				old := cb.Tabs
 				cb.Tabs = 0
 				cb.MakeStmt(n.Init, 0)
@@ -765,6 +1014,9 @@ func (cb *FuncBlock) MakeStmt(stmt ast.Stmt, flags int) {
 }
 
 func GetExprString(e ast.Expr) string {
+	if folded, is_folded := FoldFloatConst(e); is_folded {
+		return folded
+	}
 	switch x := e.(type) {
 		case *ast.IndexExpr:
 			return GetExprString(x.X) + "[" + GetExprString(x.Index) + "]"
@@ -772,6 +1024,9 @@ func GetExprString(e ast.Expr) string {
 		case *ast.KeyValueExpr:
 			return GetExprString(x.Key) + " = " + GetExprString(x.Value)
 		
+		case *ast.CompositeLit:
+			return "{" + strings.Join(GetCompositeElts(x), ", ") + "}"
+		
 		case *ast.ParenExpr:
 			return "(" + GetExprString(x.X) + ")"
 		
@@ -788,6 +1043,9 @@ func GetExprString(e ast.Expr) string {
 			return x.Op.String() + GetExprString(x.X)
 		
 		case *ast.CallExpr:
+			if ASTMod.IsTypeConversion(x) {
+				return MakeConversion(x)
+			}
 			var call strings.Builder
 			name := GetExprString(x.Fun)
 			if n, found := FuncNames[name]; found {
@@ -804,10 +1062,29 @@ func GetExprString(e ast.Expr) string {
 			return call.String()
 		
 		case *ast.BinaryExpr:
+			if ASTMod.IsStringExpr(x.X) && ASTMod.IsStringExpr(x.Y) {
+				switch x.Op {
+					case token.EQL:
+						return "StrEqual(" + GetExprString(x.X) + ", " + GetExprString(x.Y) + ")"
+					case token.NEQ:
+						return "!StrEqual(" + GetExprString(x.X) + ", " + GetExprString(x.Y) + ")"
+					case token.LSS, token.GTR, token.LEQ, token.GEQ:
+						return "strcmp(" + GetExprString(x.X) + ", " + GetExprString(x.Y) + ") " + x.Op.String() + " 0"
+				}
+			}
 			return GetExprString(x.X) + " " + x.Op.String() + " " + GetExprString(x.Y)
 		
 		case *ast.SelectorExpr:
-			return GetExprString(x.X) + "." + GetExprString(x.Sel)
+			/// embedded structs are flattened, p.Entity.Origin -> p.Origin
+			owner := x.X
+			for {
+				if sel, is_sel := owner.(*ast.SelectorExpr); is_sel && ASTMod.IsEmbeddedField(sel.Sel) {
+					owner = sel.X
+				} else {
+					break
+				}
+			}
+			return GetExprString(owner) + "." + GetExprString(x.Sel)
 		
 		case *ast.Ident:
 			if n, found := IdenNames[x.Name]; found {
@@ -816,6 +1093,13 @@ func GetExprString(e ast.Expr) string {
 			return x.Name
 		
 		case *ast.BasicLit:
+			/// untyped constants that Go promoted, 'var f float = 1' needs to be '1.0'.
+			if tv, found := ASTMod.ASTCtxt.TypeInfo.Types[x]; found && tv.Value != nil {
+				info := GetBasicInfo(tv.Type)
+				if (x.Kind==token.INT && info & types.IsFloat > 0) || (x.Kind==token.FLOAT && info & types.IsInteger > 0) {
+					return MakeNumLit(tv.Value, tv.Type)
+				}
+			}
 			return x.Value
 		
 		case *ast.TypeAssertExpr, *ast.SliceExpr:
@@ -823,3 +1107,224 @@ func GetExprString(e ast.Expr) string {
 	}
 	return ""
 }
+
+/// constant float expressions made from untyped ints, 'var p float = c * 2' with 'const c = 7' becomes '14.0'.
+func FoldFloatConst(e ast.Expr) (string, bool) {
+	tv, found := ASTMod.ASTCtxt.TypeInfo.Types[e]
+	if !found || tv.Value==nil || GetBasicInfo(tv.Type) & types.IsFloat==0 {
+		return "", false
+	}
+	/// int constants would be emitted as ints, float constants and literals keep their name or promotion.
+	is_int_const := func(n ast.Node) bool {
+		if iden, is_ident := n.(*ast.Ident); is_ident {
+			obj, is_const := ASTMod.ASTCtxt.TypeInfo.Uses[iden].(*types.Const)
+			return is_const && GetBasicInfo(obj.Type()) & types.IsFloat==0
+		}
+		return false
+	}
+	switch e.(type) {
+		case *ast.Ident, *ast.BinaryExpr, *ast.ParenExpr, *ast.UnaryExpr:
+			has_int_const := false
+			ast.Inspect(e, func(n ast.Node) bool {
+				has_int_const = has_int_const || is_int_const(n)
+				return !has_int_const
+			})
+			if has_int_const {
+				return MakeNumLit(tv.Value, tv.Type), true
+			}
+	}
+	return "", false
+}
+
+func GetBasicInfo(typ types.Type) types.BasicInfo {
+	if typ != nil {
+		if basic, is_basic := types.Unalias(typ).Underlying().(*types.Basic); is_basic {
+			return basic.Info()
+		}
+	}
+	return 0
+}
+
+/// the zero value of a type as an initializer.
+func MakeZeroValue(typ types.Type) string {
+	if ASTMod.IsStringType(typ) {
+		return `""`
+	}
+	switch t := typ.Underlying().(type) {
+		case *types.Struct:
+			return "{" + strings.Join(GetStructLitElts(nil, t), ", ") + "}"
+		case *types.Array:
+			return "{" + MakeZeroValue(t.Elem()) + "}"
+	}
+	info := GetBasicInfo(typ)
+	switch {
+		case info & types.IsFloat > 0:
+			return "0.0"
+		case info & types.IsBoolean > 0:
+			return "false"
+	}
+	return "0"
+}
+
+/// structs declared in the plugin's file become enum structs, the rest come from includes.
+func IsEnumStruct(typ types.Type, pos token.Pos) bool {
+	named, is_named := types.Unalias(typ).(*types.Named)
+	if !is_named {
+		return false
+	}
+	fset := ASTMod.ASTCtxt.FSet
+	return fset.File(named.Obj().Pos())==fset.File(pos)
+}
+
+/// positional initializers, enum structs are in field order with omitted fields zeroed.
+func GetCompositeElts(lit *ast.CompositeLit) []string {
+	elts := make([]string, 0)
+	if typ := ASTMod.ASTCtxt.TypeInfo.TypeOf(lit); typ != nil {
+		switch t := typ.Underlying().(type) {
+			case *types.Struct:
+				if IsEnumStruct(typ, lit.Pos()) {
+					return GetStructLitElts(lit, t)
+				}
+				/// SourceMod's own structs, like 'Plugin', take named fields.
+			case *types.Array, *types.Slice:
+				/// keyed elements like '[4]int{2: 5}' are placed by index.
+				keyed := make(map[int64]ast.Expr)
+				var idx, count int64
+				for _, elt := range lit.Elts {
+					if kv, is_kv := elt.(*ast.KeyValueExpr); is_kv {
+						if key := ASTMod.ASTCtxt.TypeInfo.Types[kv.Key].Value; key != nil {
+							idx, _ = constant.Int64Val(constant.ToInt(key))
+						}
+						elt = kv.Value
+					}
+					keyed[idx] = elt
+					idx++
+					if idx > count {
+						count = idx
+					}
+				}
+				var elem types.Type
+				if arr, is_arr := t.(*types.Array); is_arr {
+					elem = arr.Elem()
+				} else {
+					elem = t.(*types.Slice).Elem()
+				}
+				for i := int64(0); i < count; i++ {
+					if elt, found := keyed[i]; found {
+						elts = append(elts, GetExprString(elt))
+					} else {
+						elts = append(elts, MakeZeroValue(elem))
+					}
+				}
+				return elts
+		}
+	}
+	for _, elt := range lit.Elts {
+		elts = append(elts, GetExprString(elt))
+	}
+	return elts
+}
+
+/// enum struct fields for a struct literal, embedded structs are flattened.
+func GetStructLitElts(lit *ast.CompositeLit, struc *types.Struct) []string {
+	values := make(map[string]ast.Expr)
+	if lit != nil {
+		for i, elt := range lit.Elts {
+			if kv, is_kv := elt.(*ast.KeyValueExpr); is_kv {
+				values[kv.Key.(*ast.Ident).Name] = kv.Value
+			} else if i < struc.NumFields() {
+				values[struc.Field(i).Name()] = elt
+			}
+		}
+	}
+	elts := make([]string, 0)
+	for i := 0; i < struc.NumFields(); i++ {
+		field := struc.Field(i)
+		value, given := values[field.Name()]
+		if embedded, is_struct := field.Type().Underlying().(*types.Struct); field.Embedded() && is_struct {
+			sub_lit, is_lit := value.(*ast.CompositeLit)
+			if given && !is_lit {
+				ASTMod.PrintSrcGoErr(value.Pos(), "Embedded struct fields in initializers must be struct literals.")
+			}
+			elts = append(elts, GetStructLitElts(sub_lit, embedded)...)
+		} else if given {
+			elts = append(elts, GetExprString(value))
+		} else {
+			elts = append(elts, MakeZeroValue(field.Type()))
+		}
+	}
+	return elts
+}
+
+func MakeNumLit(val constant.Value, typ types.Type) string {
+	info := GetBasicInfo(typ)
+	switch {
+		case info & types.IsFloat > 0:
+			f, _ := constant.Float64Val(constant.ToFloat(val))
+			lit := strconv.FormatFloat(f, 'f', -1, 64)
+			if !strings.Contains(lit, ".") {
+				lit += ".0"
+			}
+			return lit
+		case info & types.IsInteger > 0:
+			if i := constant.ToInt(val); i.Kind()==constant.Int {
+				return i.ExactString()
+			}
+	}
+	return val.ExactString()
+}
+
+/**
+ * float(int)         -> float(x)
+ * int(float)         -> RoundToZero(x) or the native from '//go2sp:round mode'
+ * int(bool)/bool(int) -> view_as<int>(x)/view_as<bool>(x)
+ * NamedType(x)       -> view_as<NamedType>(x)
+ */
+func MakeConversion(call *ast.CallExpr) string {
+	to, from := ASTMod.ASTCtxt.TypeInfo.TypeOf(call.Fun), ASTMod.ASTCtxt.TypeInfo.TypeOf(call.Args[0])
+	_, to_named := types.Unalias(to).(*types.Named)
+	if tv, found := ASTMod.ASTCtxt.TypeInfo.Types[call]; found && tv.Value != nil && !to_named && GetBasicInfo(to) & types.IsNumeric > 0 {
+		return MakeNumLit(tv.Value, to)
+	}
+	
+	arg := GetExprString(call.Args[0])
+	if to==nil || from==nil || ASTMod.IsStringType(to) || ASTMod.IsCharType(to) {
+		return arg
+	} else if to_named && ASTMod.ASTCtxt.TypeInfo.Types[call.Args[0]].Value != nil {
+		/// Go gives the constant the named type already.
+		return "view_as<" + GetTypeString(call.Fun, "", false) + ">(" + arg + ")"
+	} else if types.Identical(to, from) {
+		return arg
+	}
+	
+	to_info, from_info := GetBasicInfo(to), GetBasicInfo(from)
+	switch {
+		case to_info & types.IsFloat > 0 && from_info & types.IsFloat > 0:
+			return arg
+		case to_info & types.IsFloat > 0 && from_info & types.IsInteger > 0:
+			return "float(" + arg + ")"
+		case to_info & types.IsInteger > 0 && from_info & types.IsFloat > 0:
+			round_native := RoundNatives["zero"]
+			if f := ASTMod.ASTCtxt.CurrFunc; f != nil {
+				if mode, found := ASTMod.GetDirective(f.Doc, "round"); found {
+					if native, valid := RoundNatives[mode]; valid {
+						round_native = native
+					} else {
+						ASTMod.PrintSrcGoErr(f.Pos(), "unknown rounding mode '" + mode + "', expected zero, floor, ceil or nearest.")
+					}
+				}
+			}
+			if to_named {
+				return "view_as<" + GetTypeString(call.Fun, "", false) + ">(" + round_native + "(" + arg + "))"
+			}
+			return round_native + "(" + arg + ")"
+		case ASTMod.IsCharType(to) || ASTMod.IsCharType(from):
+			return arg
+	}
+	
+	_, from_named := types.Unalias(from).(*types.Named)
+	if to_named || from_named || (to_info & types.IsBoolean) != (from_info & types.IsBoolean) {
+		return "view_as<" + GetTypeString(call.Fun, "", false) + ">(" + arg + ")"
+	}
+	return arg
+}
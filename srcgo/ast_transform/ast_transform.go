@@ -18,13 +18,17 @@ import (
 	"fmt"
 	"bytes"
 	"strings"
-	//"unicode"
+	"strconv"
+	"unicode"
 	"errors"
 	"go/token"
 	"go/ast"
+	"go/parser"
 	"go/types"
 	"go/format"
 	"go/constant"
+	"reflect"
+	"path/filepath"
 )
 
 
@@ -36,7 +40,19 @@ var ASTCtxt struct {
 	FSet          *token.FileSet
 	BuiltInTypes  map[string]types.Object
 	Err           func(err error)
+	ShimPkgs      map[string]*types.Package
+	Ifaces        map[*types.Named]*types.Named
+	IfaceImpls    []*IfaceImpl
+	Delays        map[ast.Stmt]string
+	Captures      map[string][]ast.Expr
+	ErrFuncs      map[*types.Func]bool
+	ErrVars       map[*types.Var]bool
+	Inlines       map[*types.Func]*ast.FuncDecl
+	Inlined       bool
+	InlineErrs    map[*ast.FuncDecl]bool
+	Forwards      map[string]bool
 	RangeIter,TmpVar,TmpFunc uint
+	StrBufLen     uint
 }
 
 func PtrizeExpr(x ast.Expr) *ast.StarExpr {
@@ -74,6 +90,19 @@ func MakeIndex(index, x ast.Expr) *ast.IndexExpr {
 }
 
 
+func MakeCall(name string, args ...ast.Expr) *ast.CallExpr {
+	call := new(ast.CallExpr)
+	call.Fun = ast.NewIdent(name)
+	call.Args = args
+	return call
+}
+
+func MakeExprStmt(x ast.Expr) *ast.ExprStmt {
+	expr_stmt := new(ast.ExprStmt)
+	expr_stmt.X = x
+	return expr_stmt
+}
+
 func MakeAssign(create bool) *ast.AssignStmt {
 	assign := new(ast.AssignStmt)
 	assign.TokPos = token.NoPos
@@ -108,6 +137,44 @@ func MakeVarDecl(names []*ast.Ident, val ast.Expr, typ types.Type) *ast.DeclStmt
 }
 
 
+/// var name [StrBufLen]char
+func MakeStrBufDecl(name *ast.Ident) *ast.DeclStmt {
+	decl_stmt := new(ast.DeclStmt)
+	gen_decl := new(ast.GenDecl)
+	gen_decl.Tok = token.VAR
+	
+	val_spec := new(ast.ValueSpec)
+	val_spec.Names = append(val_spec.Names, name)
+	val_spec.Type = Arrayify(ast.NewIdent("char"), MakeBasicLit(token.INT, fmt.Sprintf("%d", ASTCtxt.StrBufLen)))
+	gen_decl.Specs = append(gen_decl.Specs, val_spec)
+	
+	decl_stmt.Decl = gen_decl
+	return decl_stmt
+}
+
+/// Format(buffer, sizeof(buffer), "%s%s...", operands...)
+func MakeStrFormat(buffer ast.Expr, operands []ast.Expr) *ast.ExprStmt {
+	args := []ast.Expr{ buffer, MakeCall("sizeof", buffer), MakeBasicLit(token.STRING, `"` + strings.Repeat("%s", len(operands)) + `"`) }
+	return MakeExprStmt(MakeCall("Format", append(args, operands...)...))
+}
+
+func MakeBinaryExpr(x ast.Expr, op token.Token, y ast.Expr) *ast.BinaryExpr {
+	bin := new(ast.BinaryExpr)
+	bin.X = x
+	bin.Op = op
+	bin.Y = y
+	return bin
+}
+
+/// 'lhs tok rhs' like 'a := b' or 'a += b'.
+func MakeAssignTok(lhs ast.Expr, tok token.Token, rhs ast.Expr) *ast.AssignStmt {
+	assign := MakeAssign(false)
+	assign.Tok = tok
+	assign.Lhs = append(assign.Lhs, lhs)
+	assign.Rhs = append(assign.Rhs, rhs)
+	return assign
+}
+
 func MakeBitNotExpr(e ast.Expr) *ast.UnaryExpr {
 	u := new(ast.UnaryExpr)
 	u.Op = token.XOR
@@ -137,6 +204,7 @@ func GetTypeBase(t types.Type) types.Type {
 func TypeToASTExpr(typ types.Type) ast.Expr {
 	var type_stack []types.Type
 	for typ != nil {
+		typ = types.Unalias(typ)
 		type_stack = append(type_stack, typ)
 		typ = GetTypeBase(typ)
 	}
@@ -222,10 +290,12 @@ func FindParam(fn *ast.FuncDecl, name string) (*ast.Field, int) {
  * Modifies the return values of a function by mutating them into references and moving them to the parameters.
  * Example Go code: func f() (int, float) {}
  * Result  Go code: func f(f_param1 *float) int {}
+ * 
+ * Named results are dropped from the signature and returned so they can be declared as locals.
  */
-func MutateRetTypes(retvals **ast.FieldList, curr_params *ast.FieldList, obj_name string) []*ast.Field {
+func MutateRetTypes(retvals **ast.FieldList, curr_params *ast.FieldList, obj_name string) ([]*ast.Field, []*ast.Ident) {
 	if *retvals==nil || (*retvals).List==nil {
-		return curr_params.List
+		return curr_params.List, nil
 	}
 	
 	new_params := make([]*ast.Field, 0)
@@ -233,31 +303,75 @@ func MutateRetTypes(retvals **ast.FieldList, curr_params *ast.FieldList, obj_nam
 		new_params = append(new_params, param)
 	}
 	
-	results := len((*retvals).List)
+	/// flatten '(x, y float, z int)' into a single type per result.
+	named := make([]*ast.Ident, 0)
+	types := make([]ast.Expr, 0)
+	for _, ret := range (*retvals).List {
+		if ret.Names==nil {
+			types = append(types, ret.Type)
+			continue
+		}
+		for _, name := range ret.Names {
+			named = append(named, name)
+			types = append(types, ret.Type)
+		}
+	}
 	
 	/// multiple different return values.
-	if results > 1 {
-		for i := 1; i<results; i++ {
-			ret := (*retvals).List[i]
-			/// if they're named, treat as reference types.
-			if ret.Names != nil && len(ret.Names) > 1 {
-				ret.Type = PtrizeExpr(ret.Type)
-				new_params = append(new_params, ret)
-			} else {
-				//param_num := len(new_params)
-				ret.Names = append(ret.Names, ast.NewIdent(fmt.Sprintf("%s_param%d", obj_name, i)))
-				ret.Type = PtrizeExpr(ret.Type)
-				new_params = append(new_params, ret)
-			}
+	for i := 1; i<len(types); i++ {
+		ret := new(ast.Field)
+		ret.Names = append(ret.Names, ast.NewIdent(fmt.Sprintf("%s_param%d", obj_name, i)))
+		ret.Type = PtrizeExpr(types[i])
+		new_params = append(new_params, ret)
+	}
+	first := new(ast.Field)
+	first.Type = types[0]
+	(*retvals).List = []*ast.Field{first}
+	return new_params, named
+}
+
+/// declares the named results of a function at its entry and fills in its bare returns.
+func DeclareNamedRets(f *ast.FuncDecl, named []*ast.Ident) {
+	if f.Body==nil || len(named)==0 {
+		return
+	}
+	
+	for i := len(named)-1; i>=0; i-- {
+		obj := ASTCtxt.TypeInfo.Defs[named[i]]
+		if obj==nil {
+			continue
 		}
-		(*retvals).List = (*retvals).List[:1]
-	} else if results==1 && (*retvals).List[0].Names != nil && len((*retvals).List[0].Names) > 1 {
-		/// This condition can happen if there's multiple return values of the same type but they're named!
-		(*retvals).List[0].Type = PtrizeExpr((*retvals).List[0].Type)
-		new_params = append(new_params, (*retvals).List[0])
-		*retvals = nil
+		if named[i].Name=="_" {
+			named[i] = ast.NewIdent(fmt.Sprintf("%s_ret%d", f.Name.Name, i))
+			ASTCtxt.TypeInfo.Defs[named[i]] = obj
+		}
+		f.Body.List = InsertStmt(f.Body.List, 0, MakeVarDecl([]*ast.Ident{named[i]}, nil, obj.Type()))
+	}
+	
+	/// falling off the end of the function is an implicit bare return.
+	if _, is_ret := f.Body.List[len(f.Body.List)-1].(*ast.ReturnStmt); !is_ret {
+		f.Body.List = append(f.Body.List, new(ast.ReturnStmt))
 	}
-	return new_params
+	
+	ast.Inspect(f.Body, func(n ast.Node) bool {
+		if n != nil {
+			switch r := n.(type) {
+				case *ast.FuncLit:
+					return false
+				case *ast.ReturnStmt:
+					if r.Results==nil {
+						for _, name := range named {
+							res := ast.NewIdent(name.Name)
+							if obj := ASTCtxt.TypeInfo.Defs[name]; obj != nil {
+								ASTCtxt.TypeInfo.Uses[res] = obj
+							}
+							r.Results = append(r.Results, res)
+						}
+					}
+			}
+		}
+		return true
+	})
 }
 
 func IsMapType(expr ast.Expr) bool {
@@ -312,6 +426,254 @@ func IsFuncPtr(expr ast.Expr) bool {
 	return false
 }
 
+/// checks for Go strings and char arrays/slices, which are all char arrays in SourcePawn.
+func IsStringType(typ types.Type) bool {
+	switch t := types.Unalias(typ).(type) {
+		case *types.Basic:
+			return t.Info() & types.IsString > 0
+		case *types.Array:
+			return IsCharType(t.Elem())
+		case *types.Slice:
+			return IsCharType(t.Elem())
+		case *types.Pointer:
+			return IsStringType(t.Elem())
+	}
+	return false
+}
+
+func IsCharType(typ types.Type) bool {
+	if named, is_named := types.Unalias(typ).(*types.Named); is_named {
+		return named.Obj().Name()=="char"
+	}
+	return false
+}
+
+func IsStringExpr(expr ast.Expr) bool {
+	if typ := ASTCtxt.TypeInfo.TypeOf(expr); typ != nil {
+		return IsStringType(typ)
+	}
+	return false
+}
+
+/// only a Go 'string' typed expr, char arrays don't count.
+func IsBasicStringExpr(expr ast.Expr) bool {
+	if typ := ASTCtxt.TypeInfo.TypeOf(expr); typ != nil {
+		if t, is_basic := types.Unalias(typ).(*types.Basic); is_basic {
+			return t.Info() & types.IsString > 0
+		}
+	}
+	return false
+}
+
+/// string buffers we can get the size of, 'char[]' params don't have a size.
+func IsStringBufExpr(expr ast.Expr) bool {
+	if typ := ASTCtxt.TypeInfo.TypeOf(expr); typ != nil {
+		if ptr, is_ptr := types.Unalias(typ).(*types.Pointer); is_ptr {
+			typ = ptr.Elem()
+		}
+		switch t := types.Unalias(typ).(type) {
+			case *types.Basic:
+				return t.Info() & types.IsString > 0
+			case *types.Array:
+				return IsCharType(t.Elem())
+		}
+	}
+	return false
+}
+
+/// a + b + c where a, b, c are strings and the result isn't a constant.
+func IsStrConcat(expr ast.Expr) bool {
+	if bin, is_bin := expr.(*ast.BinaryExpr); is_bin && bin.Op==token.ADD && IsStringExpr(bin) {
+		return ASTCtxt.TypeInfo.Types[bin].Value==nil
+	}
+	return false
+}
+
+func FlattenStrConcat(expr ast.Expr, operands []ast.Expr) []ast.Expr {
+	switch e := expr.(type) {
+		case *ast.ParenExpr:
+			if IsStrConcat(e.X) {
+				return FlattenStrConcat(e.X, operands)
+			}
+		case *ast.BinaryExpr:
+			if IsStrConcat(e) {
+				operands = FlattenStrConcat(e.X, operands)
+				return FlattenStrConcat(e.Y, operands)
+			} else if tv, found := ASTCtxt.TypeInfo.Types[e]; found && tv.Value != nil && tv.Value.Kind()==constant.String {
+				/// "a" + "b" is folded by Go, use the folded string instead.
+				return append(operands, MakeBasicLit(token.STRING, tv.Value.ExactString()))
+			}
+	}
+	return append(operands, expr)
+}
+
+func IsEmbeddedField(iden *ast.Ident) bool {
+	if field, is_var := ASTCtxt.TypeInfo.Uses[iden].(*types.Var); is_var {
+		return field.Embedded()
+	}
+	return false
+}
+
+/** embedded structs are flattened into the outer enum struct, so there's nothing to read or write as a whole:
+ * 
+ * g_boss.Base.Health => g_boss.Health
+ * Use(g_boss.Base)   => error, there's no 'Base' member.
+ */
+func CheckEmbeddedValues(file *ast.File) {
+	owners := make(map[ast.Expr]bool)
+	ast.Inspect(file, func(n ast.Node) bool {
+		sel, is_sel := n.(*ast.SelectorExpr)
+		if !is_sel {
+			return true
+		}
+		owners[sel.X] = true
+		if !owners[sel] && IsEmbeddedField(sel.Sel) {
+			if typ := ASTCtxt.TypeInfo.TypeOf(sel); typ != nil {
+				if _, is_struct := typ.Underlying().(*types.Struct); is_struct {
+					PrintSrcGoErr(sel.Sel.Pos(), fmt.Sprintf("embedded struct '%s' is flattened into its owner and can't be used as a value, use its fields instead.", sel.Sel.Name))
+				}
+			}
+		}
+		return true
+	})
+}
+
+/// T(x) where T is a type.
+func IsTypeConversion(call *ast.CallExpr) bool {
+	if tv, found := ASTCtxt.TypeInfo.Types[call.Fun]; found && len(call.Args)==1 {
+		return tv.IsType()
+	}
+	return false
+}
+
+/// gets the arguments of a '//go2sp:name args' directive from a doc comment.
+/// makes the doc comment for a generated '//go2sp:name' directive.
+func MakeDirective(name string) *ast.CommentGroup {
+	doc := new(ast.CommentGroup)
+	comment := new(ast.Comment)
+	comment.Text = "//go2sp:" + name
+	doc.List = append(doc.List, comment)
+	return doc
+}
+
+func GetDirective(doc *ast.CommentGroup, name string) (string, bool) {
+	if doc != nil {
+		directive := "//go2sp:" + name
+		for _, comment := range doc.List {
+			if comment.Text==directive || strings.HasPrefix(comment.Text, directive + " ") {
+				return strings.TrimSpace(comment.Text[len(directive):]), true
+			}
+		}
+	}
+	return "", false
+}
+
+/// the variable that an assignable expression like 'a.b[i]' writes into.
+func GetRootIdent(expr ast.Expr) *ast.Ident {
+	switch e := expr.(type) {
+		case *ast.Ident:
+			return e
+		case *ast.IndexExpr:
+			return GetRootIdent(e.X)
+		case *ast.SelectorExpr:
+			return GetRootIdent(e.X)
+		case *ast.StarExpr:
+			return GetRootIdent(e.X)
+		case *ast.ParenExpr:
+			return GetRootIdent(e.X)
+	}
+	return nil
+}
+
+/// whether 'x' could read what 'dest' writes into, conservative for fields and indexes.
+func IsExprReadIn(dest, x ast.Expr) bool {
+	root := GetRootIdent(dest)
+	if root==nil || root.Name=="_" {
+		return false
+	}
+	root_obj := ASTCtxt.TypeInfo.ObjectOf(root)
+	found := false
+	ast.Inspect(x, func(n ast.Node) bool {
+		if iden, is_ident := n.(*ast.Ident); is_ident && iden.Name==root.Name {
+			if obj := ASTCtxt.TypeInfo.ObjectOf(iden); obj==nil || root_obj==nil || obj==root_obj {
+				found = true
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+/** SourcePawn assigns one at a time, Go assigns all at once.
+ * a values that reads an earlier assigned var is saved into a temp first:
+ * 
+ * a, b = b, a+b => assign_temp# = a+b, a = b, b = assign_temp#
+ * 
+ * Go also evaluates the indexes on the left before assigning anything,
+ * so an index that reads an earlier assigned var is saved the same way:
+ * 
+ * i, arr[i] = 5, 7 => assign_temp# = i, i = 5, arr[assign_temp#] = 7
+ */
+func SequenceParallelAssign(n *ast.AssignStmt) ([]*ast.Ident, *ast.AssignStmt) {
+	var temps []*ast.Ident
+	seq := MakeAssign(false)
+	for k := 1; k < len(n.Lhs); k++ {
+		SaveLhsIndexes(n.Lhs[k], n.Lhs[:k], &temps, seq)
+	}
+	values := make([]ast.Expr, len(n.Rhs))
+	copy(values, n.Rhs)
+	for k := range n.Rhs {
+		for j:=0; j<k; j++ {
+			if IsExprReadIn(n.Lhs[j], n.Rhs[k]) {
+				tmp := ast.NewIdent(fmt.Sprintf("assign_temp%d", ASTCtxt.TmpVar))
+				ASTCtxt.TmpVar++
+				temps = append(temps, tmp)
+				seq.Lhs = append(seq.Lhs, tmp)
+				seq.Rhs = append(seq.Rhs, n.Rhs[k])
+				values[k] = ast.NewIdent(tmp.Name)
+				break
+			}
+		}
+	}
+	seq.Lhs = append(seq.Lhs, n.Lhs...)
+	seq.Rhs = append(seq.Rhs, values...)
+	return temps, seq
+}
+
+/// saves each index of 'dest' that reads one of 'earlier' into a temp.
+func SaveLhsIndexes(dest ast.Expr, earlier []ast.Expr, temps *[]*ast.Ident, seq *ast.AssignStmt) {
+	switch e := dest.(type) {
+		case *ast.IndexExpr:
+			SaveLhsIndexes(e.X, earlier, temps, seq)
+			for _, prev := range earlier {
+				if IsExprReadIn(prev, e.Index) {
+					tmp := ast.NewIdent(fmt.Sprintf("assign_temp%d", ASTCtxt.TmpVar))
+					ASTCtxt.TmpVar++
+					*temps = append(*temps, tmp)
+					seq.Lhs = append(seq.Lhs, tmp)
+					seq.Rhs = append(seq.Rhs, e.Index)
+					e.Index = ast.NewIdent(tmp.Name)
+					break
+				}
+			}
+		case *ast.SelectorExpr:
+			SaveLhsIndexes(e.X, earlier, temps, seq)
+		case *ast.StarExpr:
+			SaveLhsIndexes(e.X, earlier, temps, seq)
+		case *ast.ParenExpr:
+			SaveLhsIndexes(e.X, earlier, temps, seq)
+	}
+}
+
+/// var temp T, typed after the value it saves.
+func MakeTempDecl(tmp *ast.Ident, value ast.Expr) *ast.DeclStmt {
+	typ := ASTCtxt.TypeInfo.TypeOf(value)
+	if typ==nil {
+		return MakeVarDecl([]*ast.Ident{tmp}, value, nil)
+	}
+	return MakeVarDecl([]*ast.Ident{tmp}, nil, types.Default(typ))
+}
+
 func GetFuncName(expr ast.Expr) string {
 	if expr != nil {
 		switch e := expr.(type) {
@@ -391,6 +753,66 @@ func MakeFunc(name string, recv *types.Var, params, results *types.Tuple, variad
 }
 
 
+/// packages like 'fmt' which SourceGo provides and lowers itself rather than #include'ing.
+type SrcGoImporter struct {
+	Default types.Importer
+}
+
+func (imp SrcGoImporter) Import(path string) (*types.Package, error) {
+	if pkg, found := ASTCtxt.ShimPkgs[path]; found {
+		return pkg, nil
+	}
+	return imp.Default.Import(path)
+}
+
+func IsShimPackage(path string) bool {
+	_, found := ASTCtxt.ShimPkgs[path]
+	return found
+}
+
+func MakeShimFunc(pkg *types.Package, name string, params, results *types.Tuple, variadic bool) {
+	sig := types.NewSignatureType(nil, nil, nil, params, results, variadic)
+	pkg.Scope().Insert(types.NewFunc(token.NoPos, pkg, name, sig))
+}
+
+func MakeFmtShim() *types.Package {
+	fmt_pkg := types.NewPackage("fmt", "fmt")
+	any_type := types.NewInterfaceType(nil, nil).Complete()
+	any_slice := types.NewSlice(any_type)
+	
+	/// func Sprintf(format string, a ...any) string
+	MakeShimFunc(fmt_pkg, "Sprintf", MakeParams([]string{"format", "a"}, []types.Type{types.Typ[types.String], any_slice}), MakeRet([]types.Type{types.Typ[types.String]}), true)
+	
+	/// func Printf(format string, a ...any)
+	MakeShimFunc(fmt_pkg, "Printf", MakeParams([]string{"format", "a"}, []types.Type{types.Typ[types.String], any_slice}), nil, true)
+	
+	/// func Println(a ...any)
+	MakeShimFunc(fmt_pkg, "Println", MakeParams([]string{"a"}, []types.Type{any_slice}), nil, true)
+	
+	/// func Errorf(format string, a ...any) error
+	MakeShimFunc(fmt_pkg, "Errorf", MakeParams([]string{"format", "a"}, []types.Type{types.Typ[types.String], any_slice}), MakeRet([]types.Type{types.Universe.Lookup("error").Type()}), true)
+	fmt_pkg.MarkComplete()
+	return fmt_pkg
+}
+
+func MakeErrorsShim() *types.Package {
+	errors_pkg := types.NewPackage("errors", "errors")
+	
+	/// func New(text string) error
+	MakeShimFunc(errors_pkg, "New", MakeParams([]string{"text"}, []types.Type{types.Typ[types.String]}), MakeRet([]types.Type{types.Universe.Lookup("error").Type()}), false)
+	errors_pkg.MarkComplete()
+	return errors_pkg
+}
+
+func MakeGo2SPShim() *types.Package {
+	go2sp_pkg := types.NewPackage("go2sp", "go2sp")
+	
+	/// func After(delay float64, fn func())
+	MakeShimFunc(go2sp_pkg, "After", MakeParams([]string{"delay", "fn"}, []types.Type{types.Typ[types.Float64], types.NewSignatureType(nil, nil, nil, nil, nil, false)}), nil, false)
+	go2sp_pkg.MarkComplete()
+	return go2sp_pkg
+}
+
 func AddSrcGoTypes() {
 	/**
 	 * func NewTypeName(pos token.Pos, pkg *Package, name string, typ Type) *TypeName
@@ -409,6 +831,30 @@ func AddSrcGoTypes() {
 	/// func __sp__(code string)
 	/// void __sp__(const char[] code);
 	MakeFunc("__sp__", nil, MakeParams([]string{"code"}, []types.Type{types.Typ[types.String]}), nil, false)
+	
+	/// func sizeof(x any) int
+	/// used by generated code where 'len' would become 'strlen'.
+	MakeFunc("sizeof", nil, MakeParams([]string{"x"}, []types.Type{types.NewInterfaceType(nil, nil).Complete()}), MakeRet([]types.Type{types.Typ[types.Int]}), false)
+	
+	/// Task ids of async functions, 0 is no task.
+	MakeTypeAlias("Task", types.Typ[types.Int], false)
+	
+	/// func Sleep(seconds float)
+	/// func WaitFrames(frames int)
+	/// suspend a '//go2sp:async' function.
+	MakeFunc("Sleep", nil, MakeParams([]string{"seconds"}, []types.Type{types.Typ[types.Float64]}), nil, false)
+	MakeFunc("WaitFrames", nil, MakeParams([]string{"frames"}, []types.Type{types.Typ[types.Int]}), nil, false)
+	
+	/// func CancelTask(task Task) bool
+	/// generated along with the async functions.
+	MakeFunc("CancelTask", nil, MakeParams([]string{"task"}, []types.Type{types.Typ[types.Int]}), MakeRet([]types.Type{types.Typ[types.Bool]}), false)
+	
+	ASTCtxt.StrBufLen = 256
+	
+	ASTCtxt.ShimPkgs = make(map[string]*types.Package)
+	ASTCtxt.ShimPkgs["fmt"] = MakeFmtShim()
+	ASTCtxt.ShimPkgs["go2sp"] = MakeGo2SPShim()
+	ASTCtxt.ShimPkgs["errors"] = MakeErrorsShim()
 }
 
 func SetUpSrcGo(fset *token.FileSet, info *types.Info, err_fn func(err error)) {
@@ -417,6 +863,22 @@ func SetUpSrcGo(fset *token.FileSet, info *types.Info, err_fn func(err error)) {
 	ASTCtxt.FSet = fset
 }
 
+/// collects the '//go2sp:forward' names the imported includes declare.
+func CollectForwards(files []*ast.File) {
+	ASTCtxt.Forwards = make(map[string]bool)
+	for _, file := range files {
+		for _, group := range file.Comments {
+			for _, comment := range group.List {
+				if names, found := strings.CutPrefix(comment.Text, "//go2sp:forward "); found {
+					for _, name := range strings.Fields(names) {
+						ASTCtxt.Forwards[name] = true
+					}
+				}
+			}
+		}
+	}
+}
+
 
 func AnalyzeIllegalCode(file *ast.File) {
 	ast.Inspect(file, func(n ast.Node) bool {
@@ -455,7 +917,7 @@ func AnalyzeIllegalCode(file *ast.File) {
 						}
 					}
 				case *ast.BranchStmt:
-					if x.Tok==token.GOTO || x.Tok==token.FALLTHROUGH {
+					if x.Tok==token.GOTO {
 						PrintSrcGoErr(x.Pos(), fmt.Sprintf(" %s is Illegal.", x.Tok.String()))
 					} else if x.Label != nil {
 						PrintSrcGoErr(x.Pos(), "Branched Labels are Illegal.")
@@ -469,8 +931,6 @@ func AnalyzeIllegalCode(file *ast.File) {
 					PrintSrcGoErr(x.Pos(), "Type-Switches are Illegal.")
 				case *ast.LabeledStmt:
 					PrintSrcGoErr(x.Pos(), "Labels are Illegal.")
-				case *ast.GoStmt:
-					PrintSrcGoErr(x.Pos(), "Goroutines are Illegal.")
 				case *ast.SelectStmt:
 					PrintSrcGoErr(x.Pos(), "Select Statements are Illegal.")
 				case *ast.SendStmt:
@@ -496,707 +956,7103 @@ func AnalyzeIllegalCode(file *ast.File) {
 }
 
 
-func MergeRetVals(file *ast.File) {
-	ast.Inspect(file, func(n ast.Node) bool {
-		if n != nil {
-			switch f := n.(type) {
-				case *ast.FuncDecl:
-					f.Type.Params.List = MutateRetTypes(&f.Type.Results, f.Type.Params, f.Name.Name)
-				case *ast.TypeSpec:
-					if t, is_func_type := f.Type.(*ast.FuncType); is_func_type {
-						t.Params.List = MutateRetTypes(&t.Results, t.Params, f.Name.Name)
-					}
+/// whether a global's initializer is a compile-time constant SourcePawn allows.
+func IsConstInit(expr ast.Expr) bool {
+	if tv, found := ASTCtxt.TypeInfo.Types[expr]; found && tv.Value != nil {
+		return true
+	}
+	switch e := expr.(type) {
+		case *ast.CompositeLit:
+			for _, elt := range e.Elts {
+				if kv, is_kv := elt.(*ast.KeyValueExpr); is_kv {
+					elt = kv.Value
+				}
+				if !IsConstInit(elt) {
+					return false
+				}
+			}
+			return true
+		case *ast.ParenExpr:
+			return IsConstInit(e.X)
+		case *ast.Ident:
+			/// function names.
+			_, is_func := ASTCtxt.TypeInfo.Uses[e].(*types.Func)
+			return is_func
+	}
+	return false
+}
+
+/** SourcePawn globals can only have constant initializers.
+ * 
+ * var cvar = CreateConVar(...)
+ * var start = GetGameTime()
+ * 
+ * become bare globals that are set, in Go's initialization order, by a generated function:
+ * 
+ * ConVar cvar; float start;
+ * static void __go2sp_init() { cvar = CreateConVar(...); start = GetGameTime(); }
+ * public void OnPluginStart() { __go2sp_init(); ... }
+ */
+func MutateGlobalInits(file *ast.File) {
+	moved := make(map[*types.Var]bool)
+	for _, decl := range file.Decls {
+		gen_decl, is_gen := decl.(*ast.GenDecl)
+		if !is_gen || gen_decl.Tok != token.VAR {
+			continue
+		}
+		var specs []ast.Spec
+		for _, spec := range gen_decl.Specs {
+			val_spec := spec.(*ast.ValueSpec)
+			constant_init := true
+			if len(val_spec.Names) != len(val_spec.Values) {
+				/// var a, b = f()
+				constant_init = len(val_spec.Values)==0
+			} else {
+				for _, value := range val_spec.Values {
+					constant_init = constant_init && IsConstInit(value)
+				}
+			}
+			if constant_init {
+				specs = append(specs, val_spec)
+				continue
+			}
+			/// each var gets its own bare spec since they can have different types.
+			for _, name := range val_spec.Names {
+				obj, is_var := ASTCtxt.TypeInfo.Defs[name].(*types.Var)
+				if !is_var {
+					continue
+				}
+				moved[obj] = true
+				bare_spec := new(ast.ValueSpec)
+				bare_spec.Doc = val_spec.Doc
+				bare_spec.Names = append(bare_spec.Names, name)
+				if val_spec.Type != nil {
+					bare_spec.Type = val_spec.Type
+				} else {
+					bare_spec.Type = TypeToASTExpr(obj.Type())
+				}
+				specs = append(specs, bare_spec)
 			}
 		}
-		return true
-	})
+		gen_decl.Specs = specs
+		if len(specs) > 1 && !gen_decl.Lparen.IsValid() {
+			gen_decl.Lparen = gen_decl.Pos()
+		}
+	}
+	if len(moved)==0 {
+		return
+	}
+	
+	init_fn := new(ast.FuncDecl)
+	init_fn.Name = ast.NewIdent("__go2sp_init")
+	init_fn.Type = new(ast.FuncType)
+	init_fn.Type.Params = new(ast.FieldList)
+	init_fn.Body = new(ast.BlockStmt)
+	/// InitOrder has Go's dependency order for package-level vars.
+	for _, initializer := range ASTCtxt.TypeInfo.InitOrder {
+		if !moved[initializer.Lhs[0]] {
+			continue
+		}
+		assign := MakeAssign(false)
+		assign.TokPos = initializer.Rhs.Pos()
+		for _, v := range initializer.Lhs {
+			iden := ast.NewIdent(v.Name())
+			ASTCtxt.TypeInfo.Uses[iden] = v
+			assign.Lhs = append(assign.Lhs, iden)
+		}
+		assign.Rhs = append(assign.Rhs, initializer.Rhs)
+		init_fn.Body.List = append(init_fn.Body.List, assign)
+	}
+	
+	/// the init runs first thing in 'main'/'OnPluginStart' and isn't a public callback.
+	init_fn.Doc = MakeDirective("internal")
+	file.Decls = append(file.Decls, init_fn)
+	AddPluginStartStmt(file, MakeExprStmt(MakeCall(init_fn.Name.Name)))
 }
 
-func ChangeRecvrNames(file *ast.File) {
-	ast.Inspect(file, func(n ast.Node) bool {
-		if n != nil {
-			switch f := n.(type) {
-				case *ast.FuncDecl:
-					if f.Recv != nil && f.Recv.List[0].Names != nil && len(f.Recv.List[0].Names) > 0 {
-						recvr := f.Recv.List[0].Names[0].Name
-						ast.Inspect(f.Body, func(n ast.Node) bool {
-							if n != nil {
-								switch i := n.(type) {
-									case *ast.Ident:
-										if recvr==i.Name {
-											i.Name = "this"
-										}
-								}
-							}
-							return true
-						})
+/// deep copies an AST node, 'subst' replaces the idents of type params with their type args.
+func CloneAST(node ast.Node, subst map[types.Object]ast.Expr) ast.Node {
+	return CloneValue(reflect.ValueOf(node), subst).Interface().(ast.Node)
+}
+
+func CloneValue(v reflect.Value, subst map[types.Object]ast.Expr) reflect.Value {
+	switch v.Kind() {
+		case reflect.Pointer:
+			if v.IsNil() {
+				return v
+			}
+			switch x := v.Interface().(type) {
+				case *ast.Object, *ast.Scope:
+					return reflect.Zero(v.Type())
+				case *ast.Ident:
+					if typ_expr, found := subst[ASTCtxt.TypeInfo.Uses[x]]; found {
+						return CloneValue(reflect.ValueOf(typ_expr), nil)
 					}
 			}
-		}
-		return true
-	})
+			clone := reflect.New(v.Elem().Type())
+			clone.Elem().Set(CloneValue(v.Elem(), subst))
+			return clone
+		case reflect.Interface:
+			if v.IsNil() {
+				return v
+			}
+			clone := reflect.New(v.Type()).Elem()
+			SetClone(clone, v.Elem(), subst)
+			return clone
+		case reflect.Struct:
+			clone := reflect.New(v.Type()).Elem()
+			clone.Set(v)
+			for i := 0; i < v.NumField(); i++ {
+				if field := clone.Field(i); field.CanSet() {
+					SetClone(field, v.Field(i), subst)
+				}
+			}
+			return clone
+		case reflect.Slice:
+			if v.IsNil() {
+				return v
+			}
+			clone := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+			for i := 0; i < v.Len(); i++ {
+				SetClone(clone.Index(i), v.Index(i), subst)
+			}
+			return clone
+	}
+	return v
 }
 
-func MakeFuncPtrArgCall(arg ast.Expr, by_ref bool, pretyp types.Type) *ast.ExprStmt {
-	var typ types.Type
-	if typ = ASTCtxt.TypeInfo.TypeOf(arg); typ == nil && pretyp != nil {
-		typ = pretyp
+/// type args can only replace type params where an expression fits.
+func SetClone(dest, src reflect.Value, subst map[types.Object]ast.Expr) {
+	clone := CloneValue(src, subst)
+	if !clone.Type().AssignableTo(dest.Type()) {
+		clone = CloneValue(src, nil)
 	}
-	switch t := typ.(type) {
-		case *types.Array:
-			switch t.Elem().String() {
-				case "char":
-					Call_PushStringEx := new(ast.CallExpr)
-					Call_PushStringEx.Fun = ast.NewIdent("Call_PushStringEx")
-					Call_PushStringEx.Args = append(Call_PushStringEx.Args, arg)
-					
-					Call_PushStringEx.Args = append(Call_PushStringEx.Args, MakeBasicLit(token.INT, fmt.Sprintf("%d", t.Len())))
-					
-					Call_PushStringEx.Args = append(Call_PushStringEx.Args, MakeBasicLit(token.INT, "1"))
-					
-					Call_PushStringEx.Args = append(Call_PushStringEx.Args, MakeBasicLit(token.INT, "2"))
-					
-					Call_PushString_stmt := new(ast.ExprStmt)
-					Call_PushString_stmt.X = Call_PushStringEx
-					return Call_PushString_stmt
-				default:
-					Call_PushArrayEx := new(ast.CallExpr)
-					Call_PushArrayEx.Fun = ast.NewIdent("Call_PushArrayEx")
-					Call_PushArrayEx.Args = append(Call_PushArrayEx.Args, arg)
-					
-					Call_PushArrayEx.Args = append(Call_PushArrayEx.Args, MakeBasicLit(token.INT, fmt.Sprintf("%d", t.Len())))
-					
-					Call_PushArrayEx.Args = append(Call_PushArrayEx.Args, MakeBasicLit(token.INT, "1"))
-					
-					Call_PushArrayEx_stmt := new(ast.ExprStmt)
-					Call_PushArrayEx_stmt.X = Call_PushArrayEx
-					return Call_PushArrayEx_stmt
+	dest.Set(clone)
+}
+
+/// replaces every expression under 'v' with what 'fn' returns for it, children first.
+func ReplaceExprs(v reflect.Value, fn func(ast.Expr) ast.Expr) {
+	switch v.Kind() {
+		case reflect.Pointer:
+			if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+				return
 			}
-		case *types.Pointer:
-			switch t.Elem().String() {
-				case "float":
-					Call_PushFloatRef := new(ast.CallExpr)
-					Call_PushFloatRef.Fun = ast.NewIdent("Call_PushFloatRef")
-					if by_ref {
-						Call_PushFloatRef.Args = append(Call_PushFloatRef.Args, MakeReference(arg))
-					} else {
-						Call_PushFloatRef.Args = append(Call_PushFloatRef.Args, arg)
-					}
-					
-					Call_PushFloatRef_stmt := new(ast.ExprStmt)
-					Call_PushFloatRef_stmt.X = Call_PushFloatRef
-					return Call_PushFloatRef_stmt
-				default:
-					Call_PushCellRef := new(ast.CallExpr)
-					Call_PushCellRef.Fun = ast.NewIdent("Call_PushCellRef")
-					if by_ref {
-						Call_PushCellRef.Args = append(Call_PushCellRef.Args, MakeReference(arg))
-					} else {
-						Call_PushCellRef.Args = append(Call_PushCellRef.Args, arg)
-					}
-					
-					Call_PushCellRef_stmt := new(ast.ExprStmt)
-					Call_PushCellRef_stmt.X = Call_PushCellRef
-					return Call_PushCellRef_stmt
+			switch v.Interface().(type) {
+				case *ast.Object, *ast.Scope:
+					return
 			}
-		case *types.Basic:
-			switch t.Name() {
-				case "string":
-					Call_PushString := new(ast.CallExpr)
-					Call_PushString.Fun = ast.NewIdent("Call_PushString")
-					Call_PushString.Args = append(Call_PushString.Args, arg)
-					
-					Call_PushString_stmt := new(ast.ExprStmt)
-					Call_PushString_stmt.X = Call_PushString
-					return Call_PushString_stmt
-				case "float", "float32":
-					Call_PushFloat := new(ast.CallExpr)
-					if by_ref {
-						Call_PushFloat.Fun = ast.NewIdent("Call_PushFloatRef")
-						Call_PushFloat.Args = append(Call_PushFloat.Args, MakeReference(arg))
-					} else {
-						Call_PushFloat.Fun = ast.NewIdent("Call_PushFloat")
-						Call_PushFloat.Args = append(Call_PushFloat.Args, arg)
-					}
-					Call_PushFloat_stmt := new(ast.ExprStmt)
-					Call_PushFloat_stmt.X = Call_PushFloat
-					return Call_PushFloat_stmt
-				default:
-					Call_PushCell := new(ast.CallExpr)
-					if by_ref {
-						Call_PushCell.Fun = ast.NewIdent("Call_PushCellRef")
-						Call_PushCell.Args = append(Call_PushCell.Args, MakeReference(arg))
-					} else {
-						Call_PushCell.Fun = ast.NewIdent("Call_PushCell")
-						Call_PushCell.Args = append(Call_PushCell.Args, arg)
-					}
-					Call_PushCell_stmt := new(ast.ExprStmt)
-					Call_PushCell_stmt.X = Call_PushCell
-					return Call_PushCell_stmt
+			ReplaceExprs(v.Elem(), fn)
+		case reflect.Interface:
+			if v.IsNil() {
+				return
+			}
+			ReplaceExprs(v.Elem(), fn)
+			if expr, is_expr := v.Interface().(ast.Expr); is_expr && v.CanSet() {
+				if value := reflect.ValueOf(fn(expr)); value.Type().AssignableTo(v.Type()) {
+					v.Set(value)
+				}
+			}
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				if v.Field(i).CanSet() {
+					ReplaceExprs(v.Field(i), fn)
+				}
+			}
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				ReplaceExprs(v.Index(i), fn)
 			}
 	}
-	return nil
 }
 
-func ExpandFuncPtrCalls(x *ast.CallExpr, retvals []ast.Expr, retTypes []types.Type) []ast.Stmt {
-	new_stmts := make([]ast.Stmt, 0)
-	Call_StartFunction := new(ast.CallExpr)
-	Call_StartFunction.Fun = ast.NewIdent("Call_StartFunction")
-	Call_StartFunction.Args = append(Call_StartFunction.Args, ast.NewIdent("nil"))
-	Call_StartFunction.Args = append(Call_StartFunction.Args, x.Fun)
-	
-	Call_StartFunction_stmt := new(ast.ExprStmt)
-	Call_StartFunction_stmt.X = Call_StartFunction
-	new_stmts = append(new_stmts, Call_StartFunction_stmt)
-	
-	for _, arg := range x.Args {
-		if func_call := MakeFuncPtrArgCall(arg, false, nil); func_call != nil {
-			new_stmts = append(new_stmts, func_call)
-		}
-	}
-	
-	if retvals != nil && len(retvals) > 0 {
-		rets := len(retvals)
-		for i:=1; i<rets; i++ {
-			_, is_ptr := retvals[i].(*ast.StarExpr)
-			func_call := MakeFuncPtrArgCall(retvals[i], !is_ptr, nil)
-			if func_call != nil {
-				new_stmts = append(new_stmts, func_call)
-			} else if retTypes != nil {
-				func_call = MakeFuncPtrArgCall(retvals[i], !is_ptr, retTypes[i])
-				new_stmts = append(new_stmts, func_call)
+/// names specializations after their type args: Clamp[float] => Clamp__float, Fill[[3]int] => Fill__int_3
+func MangleTypeArg(typ types.Type) string {
+	switch t := types.Unalias(typ).(type) {
+		case *types.Basic:
+			if t.Info() & types.IsFloat > 0 {
+				return "float"
 			}
-		}
-		Call_Finish := new(ast.CallExpr)
-		Call_Finish.Fun = ast.NewIdent("Call_Finish")
-		
-		var retval_type ast.Expr
-		if field, _ := FindParam(ASTCtxt.CurrFunc, retvals[0].(*ast.Ident).Name); field != nil {
-			retval_type = field.Type
-		}
-		
-		if _, is_ptr := retval_type.(*ast.StarExpr); is_ptr {
-			Call_Finish.Args = append(Call_Finish.Args, retvals[0])
-		} else {
-			Call_Finish.Args = append(Call_Finish.Args, MakeReference(retvals[0]))
-		}
-		
-		Call_Finish_stmt := new(ast.ExprStmt)
-		Call_Finish_stmt.X = Call_Finish
-		new_stmts = append(new_stmts, Call_Finish_stmt)
-	} else {
-		Call_Finish := new(ast.CallExpr)
-		Call_Finish.Fun = ast.NewIdent("Call_Finish")
-		Call_Finish.Args = nil
-		
-		Call_Finish_stmt := new(ast.ExprStmt)
-		Call_Finish_stmt.X = Call_Finish
-		new_stmts = append(new_stmts, Call_Finish_stmt)
+			return t.Name()
+		case *types.Named:
+			name := t.Obj().Name()
+			for i := 0; i < t.TypeArgs().Len(); i++ {
+				name += "__" + MangleTypeArg(t.TypeArgs().At(i))
+			}
+			return name
+		case *types.Array:
+			/// ends in a letter, generated type names have their non-letter ends trimmed.
+			return fmt.Sprintf("%s_%darr", MangleTypeArg(t.Elem()), t.Len())
+		case *types.Pointer:
+			return MangleTypeArg(t.Elem())
 	}
-	return new_stmts
+	return "any"
 }
 
-
-type (
-	StmtMutator  func(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator)
-	BlockMutator func(b *ast.BlockStmt, mutator StmtMutator)
-)
-
-func MutateRets(file *ast.File) {
-	/** Case Studies of Returning statements to transform:
-	 * 
-	 * return m3() /// func m3() (type, type, type)
-	 * 
-	 * return int, float, m1() /// func m1() type
-	 */
-	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.CurrFunc = d
-				if d.Body != nil {
-					MutateBlock(d.Body, MutateRetStmts)
+func MakeTypeArgExpr(typ types.Type) ast.Expr {
+	switch t := types.Unalias(typ).(type) {
+		case *types.Basic:
+			if t.Info() & types.IsFloat > 0 {
+				return ast.NewIdent("float")
+			}
+			return ast.NewIdent(t.Name())
+		case *types.Named:
+			/// generic types are left instantiated so the next round specializes them.
+			if t.TypeArgs().Len()==1 {
+				return MakeIndex(MakeTypeArgExpr(t.TypeArgs().At(0)), ast.NewIdent(t.Obj().Name()))
+			} else if t.TypeArgs().Len() > 1 {
+				index_list := new(ast.IndexListExpr)
+				index_list.X = ast.NewIdent(t.Obj().Name())
+				for i := 0; i < t.TypeArgs().Len(); i++ {
+					index_list.Indices = append(index_list.Indices, MakeTypeArgExpr(t.TypeArgs().At(i)))
 				}
-				ASTCtxt.CurrFunc = nil
-		}
+				return index_list
+			}
+			return ast.NewIdent(t.Obj().Name())
+		case *types.Array:
+			return Arrayify(MakeTypeArgExpr(t.Elem()), MakeBasicLit(token.INT, fmt.Sprintf("%d", t.Len())))
+		case *types.Pointer:
+			return PtrizeExpr(MakeTypeArgExpr(t.Elem()))
 	}
+	return TypeToASTExpr(typ)
 }
 
-func MutateAssignDefs(file *ast.File) {
-	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.CurrFunc = d
-				if d.Body != nil {
-					MutateBlock(d.Body, MutateAssignDefStmts)
+func HasTypeParam(typ types.Type) bool {
+	switch t := types.Unalias(typ).(type) {
+		case *types.TypeParam:
+			return true
+		case *types.Named:
+			for i := 0; i < t.TypeArgs().Len(); i++ {
+				if HasTypeParam(t.TypeArgs().At(i)) {
+					return true
 				}
-				ASTCtxt.CurrFunc = nil
-		}
+			}
+		case *types.Array:
+			return HasTypeParam(t.Elem())
+		case *types.Slice:
+			return HasTypeParam(t.Elem())
+		case *types.Pointer:
+			return HasTypeParam(t.Elem())
 	}
+	return false
 }
 
-func MutateAssigns(file *ast.File) {
-	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.CurrFunc = d
-				if d.Body != nil {
-					MutateBlock(d.Body, MutateAssignStmts)
-				}
-				ASTCtxt.CurrFunc = nil
+/// maps the type params declared by 'fields' to the type args.
+func MakeTypeSubst(fields []*ast.Ident, type_args *types.TypeList) map[types.Object]ast.Expr {
+	subst := make(map[types.Object]ast.Expr)
+	for i, name := range fields {
+		if i < type_args.Len() {
+			subst[ASTCtxt.TypeInfo.Defs[name]] = MakeTypeArgExpr(type_args.At(i))
 		}
 	}
+	return subst
 }
 
-func MutateRanges(file *ast.File) {
-	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.CurrFunc = d
-				if d.Body != nil {
-					MutateBlock(d.Body, MutateRangeStmts)
-				}
-				ASTCtxt.CurrFunc = nil
-				ASTCtxt.RangeIter = 0
+func GetFieldNames(fields *ast.FieldList) []*ast.Ident {
+	names := make([]*ast.Ident, 0)
+	if fields != nil {
+		for _, field := range fields.List {
+			names = append(names, field.Names...)
 		}
 	}
+	return names
 }
 
-func MutateNoRetCalls(file *ast.File) {
-	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.CurrFunc = d
-				if d.Body != nil {
-					MutateBlock(d.Body, MutateNoRetCallStmts)
-				}
-				ASTCtxt.CurrFunc = nil
+/// 'Pair[T]' or '*Pair[T]' => 'Pair', [T]
+func GetGenericRecv(recv ast.Expr) (*ast.Ident, []*ast.Ident) {
+	if star, is_star := recv.(*ast.StarExpr); is_star {
+		recv = star.X
+	}
+	var base ast.Expr
+	var indices []ast.Expr
+	switch x := recv.(type) {
+		case *ast.IndexExpr:
+			base, indices = x.X, []ast.Expr{x.Index}
+		case *ast.IndexListExpr:
+			base, indices = x.X, x.Indices
+		default:
+			return nil, nil
+	}
+	iden, is_ident := base.(*ast.Ident)
+	if !is_ident {
+		return nil, nil
+	}
+	params := make([]*ast.Ident, 0)
+	for _, index := range indices {
+		if param, is_ident := index.(*ast.Ident); is_ident {
+			params = append(params, param)
 		}
 	}
+	return iden, params
 }
 
-func NameAnonFuncs(file *ast.File) {
-	/**
-	 * Function Literals can be represented in different ways:
-	 * 
-	 * f := func(params){
-	 *     code
-	 * }
-	 * f(args)
-	 * 
-	 * OR
-	 * 
-	 * func(params){
-	 *     code
-	 * }(args)
-	 */
-	ASTCtxt.NewDecls = make([]ast.Decl, 0)
-	ASTCtxt.FuncMap = make(map[string]*ast.FuncDecl)
+/**
+ * SourcePawn has no generics, so generic functions and types get one copy per instantiation:
+ * 
+ * func Clamp[T int | float](x, lo, hi T) T {}
+ * Clamp(f, 0.0, 1.0)               => Clamp__float(f, 0.0, 1.0)
+ * 
+ * type Pair[T any] struct { a, b T }
+ * var p Pair[int]                  => var p Pair__int   /// with its methods copied for 'int'.
+ * 
+ * Copies can instantiate more generics, so this is rerun after type-checking the copies
+ * until it has nothing left to do, then the generic declarations are removed.
+ */
+func MutateGenerics(file *ast.File) bool {
+	generic_funcs := make(map[types.Object]*ast.FuncDecl)
+	generic_types := make(map[types.Object]*ast.TypeSpec)
+	generic_methods := make(map[types.Object][]*ast.FuncDecl)
+	existing := make(map[string]bool)
+	is_generic := make(map[ast.Node]bool)
 	for _, decl := range file.Decls {
 		switch d := decl.(type) {
 			case *ast.FuncDecl:
-				ASTCtxt.FuncMap[d.Name.Name] = d
+				existing[d.Name.Name] = true
+				if d.Type.TypeParams != nil {
+					generic_funcs[ASTCtxt.TypeInfo.Defs[d.Name]] = d
+					is_generic[d] = true
+				} else if d.Recv != nil {
+					if base, _ := GetGenericRecv(d.Recv.List[0].Type); base != nil {
+						obj := ASTCtxt.TypeInfo.Uses[base]
+						generic_methods[obj] = append(generic_methods[obj], d)
+						is_generic[d] = true
+					}
+				}
+			case *ast.GenDecl:
+				if d.Tok != token.TYPE {
+					continue
+				}
+				for _, spec := range d.Specs {
+					type_spec := spec.(*ast.TypeSpec)
+					existing[type_spec.Name.Name] = true
+					if type_spec.TypeParams != nil {
+						generic_types[ASTCtxt.TypeInfo.Defs[type_spec.Name]] = type_spec
+						is_generic[type_spec] = true
+					}
+				}
 		}
-		ASTCtxt.NewDecls = append(ASTCtxt.NewDecls, decl)
+	}
+	if len(generic_funcs)==0 && len(generic_types)==0 {
+		return false
 	}
 	
-	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.CurrFunc = d
+	changed := false
+	specialized := make(map[*ast.Ident]bool)
+	specialize := func(iden *ast.Ident) {
+		inst, found := ASTCtxt.TypeInfo.Instances[iden]
+		if !found {
+			return
+		}
+		obj := ASTCtxt.TypeInfo.Uses[iden]
+		if generic_funcs[obj]==nil && generic_types[obj]==nil {
+			return
+		}
+		name := obj.Name()
+		for i := 0; i < inst.TypeArgs.Len(); i++ {
+			if HasTypeParam(inst.TypeArgs.At(i)) {
+				return
+			}
+			name += "__" + MangleTypeArg(inst.TypeArgs.At(i))
+		}
+		iden.Name = name
+		specialized[iden] = true
+		changed = true
+		if existing[name] {
+			return
+		}
+		existing[name] = true
+		
+		if fn_decl := generic_funcs[obj]; fn_decl != nil {
+			clone := CloneAST(fn_decl, MakeTypeSubst(GetFieldNames(fn_decl.Type.TypeParams), inst.TypeArgs)).(*ast.FuncDecl)
+			clone.Name.Name = name
+			clone.Type.TypeParams = nil
+			file.Decls = append(file.Decls, clone)
+			ASTCtxt.FuncMap[name] = clone
+			return
+		}
+		
+		type_spec := generic_types[obj]
+		clone := CloneAST(type_spec, MakeTypeSubst(GetFieldNames(type_spec.TypeParams), inst.TypeArgs)).(*ast.TypeSpec)
+		clone.Name.Name = name
+		clone.TypeParams = nil
+		gen_decl := new(ast.GenDecl)
+		gen_decl.Tok = token.TYPE
+		gen_decl.Specs = append(gen_decl.Specs, clone)
+		file.Decls = append(file.Decls, gen_decl)
+		
+		/// methods declare their own names for the type params.
+		for _, method := range generic_methods[obj] {
+			_, params := GetGenericRecv(method.Recv.List[0].Type)
+			method_clone := CloneAST(method, MakeTypeSubst(params, inst.TypeArgs)).(*ast.FuncDecl)
+			if star, is_star := method_clone.Recv.List[0].Type.(*ast.StarExpr); is_star {
+				star.X = ast.NewIdent(name)
+			} else {
+				method_clone.Recv.List[0].Type = ast.NewIdent(name)
+			}
+			file.Decls = append(file.Decls, method_clone)
+		}
+	}
+	
+	decls := file.Decls
+	for _, decl := range decls {
+		if is_generic[decl] {
+			continue
+		}
+		if gen_decl, is_gen := decl.(*ast.GenDecl); is_gen && gen_decl.Tok==token.TYPE {
+			for _, spec := range gen_decl.Specs {
+				if !is_generic[spec] {
+					ReplaceExprs(reflect.ValueOf(spec), func(expr ast.Expr) ast.Expr {
+						return MutateInstance(expr, specialize, specialized)
+					})
+				}
+			}
+			continue
+		}
+		ReplaceExprs(reflect.ValueOf(decl), func(expr ast.Expr) ast.Expr {
+			return MutateInstance(expr, specialize, specialized)
+		})
+	}
+	if changed {
+		return true
+	}
+	
+	/// every instantiation has its own copy now.
+	new_decls := make([]ast.Decl, 0)
+	for _, decl := range file.Decls {
+		if is_generic[decl] {
+			if fn_decl, is_func := decl.(*ast.FuncDecl); is_func {
+				delete(ASTCtxt.FuncMap, fn_decl.Name.Name)
+			}
+			continue
+		}
+		if gen_decl, is_gen := decl.(*ast.GenDecl); is_gen && gen_decl.Tok==token.TYPE {
+			specs := make([]ast.Spec, 0)
+			for _, spec := range gen_decl.Specs {
+				if !is_generic[spec] {
+					specs = append(specs, spec)
+				}
+			}
+			if len(specs)==0 {
+				continue
+			}
+			gen_decl.Specs = specs
+		}
+		new_decls = append(new_decls, decl)
+	}
+	file.Decls = new_decls
+	return false
+}
+
+/// renames an instantiated generic and drops its explicit type args: Clamp[float] => Clamp__float
+func MutateInstance(expr ast.Expr, specialize func(*ast.Ident), specialized map[*ast.Ident]bool) ast.Expr {
+	switch x := expr.(type) {
+		case *ast.Ident:
+			specialize(x)
+		case *ast.IndexExpr:
+			if iden, is_ident := x.X.(*ast.Ident); is_ident && specialized[iden] {
+				return iden
+			}
+		case *ast.IndexListExpr:
+			if iden, is_ident := x.X.(*ast.Ident); is_ident && specialized[iden] {
+				return iden
+			}
+	}
+	return expr
+}
+
+/**
+ * Method values and method expressions can't be SourcePawn callbacks,
+ * so they're replaced by public trampolines that forward to the method:
+ * 
+ * CreateTimer(1.0, g_player.Think, 0, 0)
+ *     => CreateTimer(1.0, g_player_Think, 0, 0)
+ *        public Action g_player_Think(Handle timer, any data) { return g_player.Think(timer, data); }
+ * 
+ * Elements of global arrays are indexed by the callback's 'client' param,
+ * or else by its 'any' param with the index passed as the callback's data arg:
+ * 
+ * HookClient(client, g_players[client].OnThink)
+ *     => public void g_players_OnThink(int client) { g_players[client].OnThink(client); }
+ * CreateTimer(1.0, g_players[i].Think, 0, 0)
+ *     => CreateTimer(1.0, g_players_Think, i, 0)
+ *        public Action g_players_Think(Handle timer, any data) { return g_players[int(data)].Think(timer, data); }
+ * 
+ * Method expressions take the receiver as their first param:
+ * 
+ * (*Player).Compare => public int Player_Compare(Player p, ...) { return p.Compare(...); }
+ */
+func MutateMethodValues(file *ast.File) {
+	trampolines := make(map[string]bool)
+	new_decls := make([]ast.Decl, 0)
+	mutate := func(e *ast.Expr, call *ast.CallExpr, arg int) {
+		if fn_decl := MutateMethodValue(e, call, arg, file); fn_decl != nil && !trampolines[fn_decl.Name.Name] {
+			trampolines[fn_decl.Name.Name] = true
+			new_decls = append(new_decls, fn_decl)
+		}
+	}
+	
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n != nil {
+			switch x := n.(type) {
+				case *ast.CallExpr:
+					for i := range x.Args {
+						mutate(&x.Args[i], x, i)
+					}
+				case *ast.AssignStmt:
+					for i := range x.Rhs {
+						mutate(&x.Rhs[i], nil, 0)
+					}
+				case *ast.ValueSpec:
+					for i := range x.Values {
+						value := x.Values[i]
+						mutate(&x.Values[i], nil, 0)
+						ReplaceInitValue(value, x.Values[i])
+					}
+				case *ast.ReturnStmt:
+					for i := range x.Results {
+						mutate(&x.Results[i], nil, 0)
+					}
+			}
+		}
+		return true
+	})
+	for _, decl := range new_decls {
+		file.Decls = append(file.Decls, decl)
+		fn_decl := decl.(*ast.FuncDecl)
+		ASTCtxt.FuncMap[fn_decl.Name.Name] = fn_decl
+	}
+}
+
+/// replaces a method value with the name of its trampoline and returns the trampoline.
+func MutateMethodValue(e *ast.Expr, call *ast.CallExpr, arg int, file *ast.File) *ast.FuncDecl {
+	sel, is_sel := (*e).(*ast.SelectorExpr)
+	if !is_sel {
+		return nil
+	}
+	selection := ASTCtxt.TypeInfo.Selections[sel]
+	if selection==nil || selection.Kind()==types.FieldVal {
+		return nil
+	}
+	
+	recv_type := selection.Recv()
+	if ptr, is_ptr := recv_type.(*types.Pointer); is_ptr {
+		recv_type = ptr.Elem()
+	}
+	named, is_named := types.Unalias(recv_type).(*types.Named)
+	if !is_named {
+		return nil
+	} else if _, is_struct := named.Underlying().(*types.Struct); !is_struct {
+		return nil
+	}
+	
+	method := selection.Obj().(*types.Func)
+	var method_decl *ast.FuncDecl
+	for _, decl := range file.Decls {
+		if d, is_func := decl.(*ast.FuncDecl); is_func && d.Recv != nil && ASTCtxt.TypeInfo.Defs[d.Name]==method {
+			method_decl = d
+			break
+		}
+	}
+	if method_decl==nil {
+		return nil
+	}
+	
+	tramp := new(ast.FuncDecl)
+	tramp.Type = new(ast.FuncType)
+	tramp.Type.Params = new(ast.FieldList)
+	add_param := func(name string, typ types.Type, typ_expr ast.Expr) *ast.Ident {
+		iden := ast.NewIdent(name)
+		ASTCtxt.TypeInfo.Defs[iden] = types.NewVar(token.NoPos, nil, name, typ)
+		field := new(ast.Field)
+		field.Names = append(field.Names, iden)
+		field.Type = typ_expr
+		tramp.Type.Params.List = append(tramp.Type.Params.List, field)
+		return iden
+	}
+	
+	var recv ast.Expr
+	var client_param, data_param *ast.Ident
+	if selection.Kind()==types.MethodExpr {
+		recv_name := strings.ToLower(named.Obj().Name()[:1])
+		recv = add_param(recv_name, selection.Recv(), method_decl.Recv.List[0].Type)
+		tramp.Name = ast.NewIdent(fmt.Sprintf("%s_%s", named.Obj().Name(), method.Name()))
+	}
+	
+	/// forward the method's params under the same names.
+	param_types := make([]ast.Expr, 0)
+	for _, field := range method_decl.Type.Params.List {
+		param_types = append(param_types, field.Type)
+		for i := 1; i < len(field.Names); i++ {
+			param_types = append(param_types, field.Type)
+		}
+	}
+	sig := method.Type().(*types.Signature)
+	args := make([]ast.Expr, 0)
+	for i := 0; i < sig.Params().Len(); i++ {
+		param_var := sig.Params().At(i)
+		name := param_var.Name()
+		if name=="" || name=="_" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		param := add_param(name, param_var.Type(), param_types[i])
+		args = append(args, param)
+		if basic, is_basic := param_var.Type().Underlying().(*types.Basic); is_basic && basic.Info() & types.IsInteger > 0 && name=="client" {
+			client_param = param
+		} else if _, is_iface := param_var.Type().Underlying().(*types.Interface); is_iface && data_param==nil {
+			data_param = param
+		}
+	}
+	
+	if selection.Kind()==types.MethodVal {
+		root := GetRootIdent(sel.X)
+		if root==nil {
+			PrintSrcGoErr(sel.Pos(), "method value callbacks need a global receiver.")
+			return nil
+		} else if obj := ASTCtxt.TypeInfo.Uses[root]; obj==nil || obj.Pkg()==nil || obj.Parent() != obj.Pkg().Scope() {
+			PrintSrcGoErr(sel.Pos(), fmt.Sprintf("method value callback '%s.%s' needs a global receiver.", root.Name, method.Name()))
+			return nil
+		}
+		
+		/// the whole receiver path is in the name so 'g_boss.Head.Think' and 'g_boss.Body.Think' get their own trampolines.
+		if path, is_const := MangleReceiverPath(sel.X); is_const {
+			tramp.Name = ast.NewIdent(fmt.Sprintf("%s_%s", path, method.Name()))
+			recv = CloneLvalue(sel.X)
+		} else if x, is_index := sel.X.(*ast.IndexExpr); is_index {
+			if _, is_ident := x.X.(*ast.Ident); !is_ident {
+				PrintSrcGoErr(sel.Pos(), "method value callbacks can only index a global array.")
+				return nil
+			} else if client_param != nil {
+				recv = MakeIndex(ast.NewIdent(client_param.Name), CloneLvalue(x.X))
+			} else if data_param != nil && call != nil && SetCallbackData(call, arg, x.Index) {
+				/// keep the position so the tolerated 'any' conversion is reported at the callback.
+				data := ast.NewIdent(data_param.Name)
+				data.NamePos = sel.Pos()
+				to_int := MakeCall("int", data)
+				recv = MakeIndex(to_int, CloneLvalue(x.X))
+			} else {
+				PrintSrcGoErr(sel.Pos(), fmt.Sprintf("can't get the index of '%s' in callback '%s', it needs a 'client' param or a free data arg.", root.Name, method.Name()))
+				return nil
+			}
+			/// the element is picked at runtime, so every element shares one trampoline.
+			tramp.Name = ast.NewIdent(fmt.Sprintf("%s_elem_%s", root.Name, method.Name()))
+		} else {
+			PrintSrcGoErr(sel.Pos(), "method value callbacks need a global receiver with constant indexes.")
+			return nil
+		}
+	}
+	
+	/// same results as the method, unnamed.
+	if sig.Results().Len() > 0 {
+		tramp.Type.Results = new(ast.FieldList)
+		for _, field := range method_decl.Type.Results.List {
+			for i := 0; i==0 || i < len(field.Names); i++ {
+				result := new(ast.Field)
+				result.Type = field.Type
+				tramp.Type.Results.List = append(tramp.Type.Results.List, result)
+			}
+		}
+	}
+	
+	forward := MakeMethodCall(recv, method.Name(), args...)
+	tramp.Body = new(ast.BlockStmt)
+	if sig.Results().Len() > 0 {
+		ret := new(ast.ReturnStmt)
+		ret.Results = append(ret.Results, forward)
+		tramp.Body.List = append(tramp.Body.List, ret)
+	} else {
+		expr_stmt := new(ast.ExprStmt)
+		expr_stmt.X = forward
+		tramp.Body.List = append(tramp.Body.List, expr_stmt)
+	}
+	
+	*e = ast.NewIdent(tramp.Name.Name)
+	return tramp
+}
+
+/// g_boss.Parts[2].Head => "g_boss_Parts_2_Head", false if the path has a non-constant index.
+func MangleReceiverPath(expr ast.Expr) (string, bool) {
+	switch e := expr.(type) {
+		case *ast.Ident:
+			return e.Name, true
+		case *ast.SelectorExpr:
+			path, is_const := MangleReceiverPath(e.X)
+			return path + "_" + e.Sel.Name, is_const
+		case *ast.IndexExpr:
+			value := ASTCtxt.TypeInfo.Types[e.Index].Value
+			if value==nil {
+				return "", false
+			}
+			path, is_const := MangleReceiverPath(e.X)
+			return path + "_" + value.ExactString(), is_const
+		case *ast.ParenExpr:
+			return MangleReceiverPath(e.X)
+	}
+	return "", false
+}
+
+/// passes 'index' as the 'any' data arg that follows the callback at 'arg', false if it's taken.
+func SetCallbackData(call *ast.CallExpr, arg int, index ast.Expr) bool {
+	sig, is_sig := ASTCtxt.TypeInfo.TypeOf(call.Fun).(*types.Signature)
+	if !is_sig {
+		return false
+	}
+	for i := arg+1; i < sig.Params().Len(); i++ {
+		if _, is_iface := sig.Params().At(i).Type().Underlying().(*types.Interface); !is_iface {
+			continue
+		}
+		for len(call.Args) <= i {
+			call.Args = append(call.Args, MakeBasicLit(token.INT, "0"))
+		}
+		if lit, is_lit := call.Args[i].(*ast.BasicLit); !is_lit || lit.Value != "0" {
+			if iden, is_ident := call.Args[i].(*ast.Ident); !is_ident || iden.Name != "nil" {
+				return false
+			}
+		}
+		call.Args[i] = index
+		return true
+	}
+	return false
+}
+
+/// a concrete type that's been converted to a user interface.
+type IfaceImpl struct {
+	TypeID   int
+	Type     *types.Named  /// pointers stripped.
+	Storage  string        /// global enum struct or enum struct array holding the values, empty for handle types.
+	IsArray  bool
+}
+
+/// interface value as an enum struct: { int typeid; any value; }
+func MakeIfaceRepr(iface *types.Named) *types.Named {
+	fields := []*types.Var{
+		types.NewField(token.NoPos, iface.Obj().Pkg(), "typeid", types.Typ[types.Int], false),
+		types.NewField(token.NoPos, iface.Obj().Pkg(), "value", types.Universe.Lookup("any").Type(), false),
+	}
+	obj := types.NewTypeName(iface.Obj().Pos(), iface.Obj().Pkg(), iface.Obj().Name(), nil)
+	return types.NewNamed(obj, types.NewStruct(fields, nil), nil)
+}
+
+/// 'pos' places the literal in the file so it's treated as an enum struct.
+func MakeIfaceLit(iface *types.Named, typeid, value ast.Expr, pos token.Pos) *ast.CompositeLit {
+	lit := new(ast.CompositeLit)
+	type_name := ast.NewIdent(iface.Obj().Name())
+	type_name.NamePos = pos
+	lit.Type = type_name
+	id_kv := new(ast.KeyValueExpr)
+	id_kv.Key = ast.NewIdent("typeid")
+	id_kv.Value = typeid
+	val_kv := new(ast.KeyValueExpr)
+	val_kv.Key = ast.NewIdent("value")
+	val_kv.Value = value
+	lit.Elts = append(lit.Elts, id_kv, val_kv)
+	ASTCtxt.TypeInfo.Types[lit] = types.TypeAndValue{Type: ASTCtxt.Ifaces[iface]}
+	return lit
+}
+
+/// returns the user interface 'typ' is, if any.
+func GetIface(typ types.Type) *types.Named {
+	if typ==nil {
+		return nil
+	}
+	if named, is_named := types.Unalias(typ).(*types.Named); is_named && ASTCtxt.Ifaces[named] != nil {
+		return named
+	}
+	return nil
+}
+
+/// finds or assigns the type id of what 'expr' is stored as.
+func GetIfaceImpl(expr ast.Expr) (*IfaceImpl, ast.Expr) {
+	if unary, is_unary := expr.(*ast.UnaryExpr); is_unary && unary.Op==token.AND {
+		expr = unary.X
+	}
+	typ := ASTCtxt.TypeInfo.TypeOf(expr)
+	if ptr, is_ptr := typ.(*types.Pointer); is_ptr {
+		typ = ptr.Elem()
+	}
+	named, is_named := types.Unalias(typ).(*types.Named)
+	if !is_named {
+		PrintSrcGoErr(expr.Pos(), "only named types can be converted to interfaces.")
+		return nil, nil
+	}
+	
+	impl := new(IfaceImpl)
+	impl.Type = named
+	value := expr
+	if _, is_struct := named.Underlying().(*types.Struct); is_struct && !IsHandleType(named) {
+		/// enum structs can't be held by 'any', so keep where they're stored instead.
+		root := GetRootIdent(expr)
+		var obj types.Object
+		if root != nil {
+			obj = ASTCtxt.TypeInfo.Uses[root]
+		}
+		if obj==nil || obj.Pkg()==nil || obj.Parent() != obj.Pkg().Scope() {
+			PrintSrcGoErr(expr.Pos(), fmt.Sprintf("enum struct '%s' must be a global or an element of a global array to be converted to an interface.", named.Obj().Name()))
+			return nil, nil
+		}
+		switch x := expr.(type) {
+			case *ast.Ident:
+				value = MakeBasicLit(token.INT, "0")
+			case *ast.IndexExpr:
+				if _, is_ident := x.X.(*ast.Ident); !is_ident {
+					PrintSrcGoErr(expr.Pos(), "enum structs converted to interfaces can only index a global array.")
+					return nil, nil
+				}
+				impl.IsArray = true
+				value = x.Index
+			default:
+				PrintSrcGoErr(expr.Pos(), fmt.Sprintf("enum struct '%s' must be a global or an element of a global array to be converted to an interface.", named.Obj().Name()))
+				return nil, nil
+		}
+		impl.Storage = root.Name
+	}
+	
+	for _, old := range ASTCtxt.IfaceImpls {
+		if old.Type==impl.Type && old.Storage==impl.Storage {
+			return old, value
+		}
+	}
+	impl.TypeID = len(ASTCtxt.IfaceImpls) + 1
+	ASTCtxt.IfaceImpls = append(ASTCtxt.IfaceImpls, impl)
+	return impl, value
+}
+
+/// converts 'expr' to the interface value of 'dest', if 'dest' is a user interface.
+func MakeIfaceValue(expr ast.Expr, dest types.Type) ast.Expr {
+	iface := GetIface(dest)
+	if iface==nil || expr==nil {
+		return expr
+	}
+	/// Boss(h) => h
+	if call, is_call := expr.(*ast.CallExpr); is_call && len(call.Args)==1 && ASTCtxt.TypeInfo.Types[call.Fun].IsType() && GetIface(ASTCtxt.TypeInfo.TypeOf(call.Fun)) != nil {
+		expr = call.Args[0]
+	}
+	
+	typ := ASTCtxt.TypeInfo.TypeOf(expr)
+	if basic, is_basic := typ.(*types.Basic); is_basic && basic.Kind()==types.UntypedNil {
+		return MakeIfaceLit(iface, MakeBasicLit(token.INT, "0"), MakeBasicLit(token.INT, "0"), expr.Pos())
+	} else if src := GetIface(typ); src != nil {
+		if src==iface {
+			return expr
+		}
+		/// interfaces share type ids, so only the enum struct type changes.
+		switch expr.(type) {
+			case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr:
+				return MakeIfaceLit(iface, MakeSelector(expr, "typeid"), MakeSelector(expr, "value"), expr.Pos())
+		}
+		PrintSrcGoErr(expr.Pos(), fmt.Sprintf("can't convert '%s' to '%s', store it in a variable first.", src.Obj().Name(), iface.Obj().Name()))
+		return expr
+	}
+	
+	impl, value := GetIfaceImpl(expr)
+	if impl==nil {
+		return expr
+	}
+	return MakeIfaceLit(iface, MakeBasicLit(token.INT, fmt.Sprintf("%d", impl.TypeID)), value, expr.Pos())
+}
+
+/**
+ * User interfaces become enum structs holding a type id and the value, or where the value's stored for enum structs.
+ * Method calls on interfaces go through a generated dispatch function per interface method:
+ * 
+ * type Boss interface { OnRage(); OnJump() }
+ * var b Boss = Hale(h)                => Boss b; b.typeid = 1; b.value = h;
+ * b = &g_vaghs[client]                => b.typeid = 2; b.value = client;
+ * b.OnRage()                          => Boss_OnRage(b);
+ * 
+ * public void Boss_OnRage(Boss self) {
+ *     switch (self.typeid) {
+ *         case 1: { view_as<Hale>(self.value).OnRage(); }
+ *         case 2: { g_vaghs[view_as<int>(self.value)].OnRage(); }
+ *     }
+ * }
+ */
+func MutateInterfaces(file *ast.File) {
+	ASTCtxt.Ifaces = make(map[*types.Named]*types.Named)
+	ASTCtxt.IfaceImpls = nil
+	iface_specs := make([]*ast.TypeSpec, 0)
+	method_types := make(map[*types.Func]*ast.FuncType)
+	for _, decl := range file.Decls {
+		gen_decl, is_gen := decl.(*ast.GenDecl)
+		if !is_gen || gen_decl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gen_decl.Specs {
+			type_spec := spec.(*ast.TypeSpec)
+			iface_type, is_iface := type_spec.Type.(*ast.InterfaceType)
+			if !is_iface {
+				continue
+			}
+			named := ASTCtxt.TypeInfo.Defs[type_spec.Name].Type().(*types.Named)
+			if !named.Underlying().(*types.Interface).IsMethodSet() {
+				/// constraints like 'int | float' are only for generics.
+				continue
+			}
+			ASTCtxt.Ifaces[named] = MakeIfaceRepr(named)
+			iface_specs = append(iface_specs, type_spec)
+			for _, method := range iface_type.Methods.List {
+				for _, name := range method.Names {
+					method_types[ASTCtxt.TypeInfo.Defs[name].(*types.Func)] = method.Type.(*ast.FuncType)
+				}
+			}
+		}
+	}
+	if len(iface_specs)==0 {
+		return
+	}
+	
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
 				if d.Body != nil {
-					MutateBlock(d.Body, MutateFuncLit)
+					sig := ASTCtxt.TypeInfo.Defs[d.Name].Type().(*types.Signature)
+					MutateIfaceExprs(d.Body, sig.Results())
+				}
+			case *ast.GenDecl:
+				MutateIfaceExprs(d, nil)
+		}
+	}
+	
+	/// dispatchers go before the interface types are replaced so their params keep the interface types.
+	for _, type_spec := range iface_specs {
+		named := ASTCtxt.TypeInfo.Defs[type_spec.Name].Type().(*types.Named)
+		iface := named.Underlying().(*types.Interface)
+		for i := 0; i < iface.NumMethods(); i++ {
+			if fn_decl := MakeIfaceDispatch(named, iface.Method(i), method_types[iface.Method(i)]); fn_decl != nil {
+				file.Decls = append(file.Decls, fn_decl)
+				ASTCtxt.FuncMap[fn_decl.Name.Name] = fn_decl
+			}
+		}
+		
+		struc := new(ast.StructType)
+		struc.Fields = new(ast.FieldList)
+		for _, field := range [][2]string{ {"typeid", "int"}, {"value", "any"} } {
+			f := new(ast.Field)
+			f.Names = append(f.Names, ast.NewIdent(field[0]))
+			f.Type = ast.NewIdent(field[1])
+			struc.Fields.List = append(struc.Fields.List, f)
+		}
+		type_spec.Type = struc
+	}
+}
+
+/// converts values to interfaces wherever they're used as one and lowers interface method calls.
+func MutateIfaceExprs(node ast.Node, results *types.Tuple) {
+	ast.Inspect(node, func(n ast.Node) bool {
+		if n != nil {
+			switch x := n.(type) {
+				case *ast.AssignStmt:
+					if len(x.Lhs) != len(x.Rhs) {
+						break
+					}
+					for i := range x.Rhs {
+						if x.Tok==token.DEFINE {
+							x.Rhs[i] = MakeIfaceValue(x.Rhs[i], ASTCtxt.TypeInfo.TypeOf(x.Rhs[i]))
+						} else if x.Tok==token.ASSIGN {
+							x.Rhs[i] = MakeIfaceValue(x.Rhs[i], ASTCtxt.TypeInfo.TypeOf(x.Lhs[i]))
+						}
+					}
+				
+				case *ast.ValueSpec:
+					for i := range x.Values {
+						value := x.Values[i]
+						if x.Type != nil {
+							x.Values[i] = MakeIfaceValue(value, ASTCtxt.TypeInfo.TypeOf(x.Type))
+						} else {
+							x.Values[i] = MakeIfaceValue(value, ASTCtxt.TypeInfo.TypeOf(value))
+						}
+						ReplaceInitValue(value, x.Values[i])
+					}
+				
+				case *ast.ReturnStmt:
+					if results != nil && len(x.Results)==results.Len() {
+						for i := range x.Results {
+							x.Results[i] = MakeIfaceValue(x.Results[i], results.At(i).Type())
+						}
+					}
+				
+				case *ast.CompositeLit:
+					typ := ASTCtxt.TypeInfo.TypeOf(x)
+					if typ==nil {
+						break
+					}
+					switch t := typ.Underlying().(type) {
+						case *types.Array:
+							for i, elt := range x.Elts {
+								if kv, is_kv := elt.(*ast.KeyValueExpr); is_kv {
+									kv.Value = MakeIfaceValue(kv.Value, t.Elem())
+								} else {
+									x.Elts[i] = MakeIfaceValue(elt, t.Elem())
+								}
+							}
+						case *types.Struct:
+							for i, elt := range x.Elts {
+								if kv, is_kv := elt.(*ast.KeyValueExpr); is_kv {
+									if field := GetStructField(t, kv.Key.(*ast.Ident).Name); field != nil {
+										kv.Value = MakeIfaceValue(kv.Value, field.Type())
+									}
+								} else if i < t.NumFields() {
+									x.Elts[i] = MakeIfaceValue(elt, t.Field(i).Type())
+								}
+							}
+					}
+				
+				case *ast.BinaryExpr:
+					/// b == nil => b.typeid == 0
+					if x.Op==token.EQL || x.Op==token.NEQ {
+						if GetIface(ASTCtxt.TypeInfo.TypeOf(x.X)) != nil && IsNilIdent(x.Y) {
+							x.X, x.Y = MakeSelector(x.X, "typeid"), MakeBasicLit(token.INT, "0")
+						} else if GetIface(ASTCtxt.TypeInfo.TypeOf(x.Y)) != nil && IsNilIdent(x.X) {
+							x.X, x.Y = MakeBasicLit(token.INT, "0"), MakeSelector(x.Y, "typeid")
+						}
+					}
+				
+				case *ast.CallExpr:
+					sig, is_sig := ASTCtxt.TypeInfo.TypeOf(x.Fun).(*types.Signature)
+					if !is_sig {
+						break
+					}
+					for i := range x.Args {
+						if i < sig.Params().Len() {
+							x.Args[i] = MakeIfaceValue(x.Args[i], sig.Params().At(i).Type())
+						}
+					}
+					/// b.OnRage(args) => Boss_OnRage(b, args)
+					if sel, is_sel := x.Fun.(*ast.SelectorExpr); is_sel {
+						if iface := GetIface(ASTCtxt.TypeInfo.TypeOf(sel.X)); iface != nil {
+							x.Fun = ast.NewIdent(fmt.Sprintf("%s_%s", iface.Obj().Name(), sel.Sel.Name))
+							x.Args = InsertExpr(x.Args, 0, sel.X)
+						}
+					}
+			}
+		}
+		return true
+	})
+}
+
+/// global initializers are generated from InitOrder, so it has to see replaced values.
+func ReplaceInitValue(old, value ast.Expr) {
+	if old==value {
+		return
+	}
+	for _, initializer := range ASTCtxt.TypeInfo.InitOrder {
+		if initializer.Rhs==old {
+			initializer.Rhs = value
+		}
+	}
+}
+
+func IsNilIdent(expr ast.Expr) bool {
+	iden, is_ident := expr.(*ast.Ident)
+	return is_ident && iden.Name=="nil"
+}
+
+func GetStructField(struc *types.Struct, name string) *types.Var {
+	for i := 0; i < struc.NumFields(); i++ {
+		if struc.Field(i).Name()==name {
+			return struc.Field(i)
+		}
+	}
+	return nil
+}
+
+/// switches on the type id and forwards to the method of each type that implements the interface.
+func MakeIfaceDispatch(named *types.Named, method *types.Func, method_type *ast.FuncType) *ast.FuncDecl {
+	if method_type==nil {
+		PrintSrcGoErr(method.Pos(), fmt.Sprintf("interface method '%s' has to be declared in this file.", method.Name()))
+		return nil
+	}
+	iface := named.Underlying().(*types.Interface)
+	sig := method.Type().(*types.Signature)
+	
+	fn_decl := new(ast.FuncDecl)
+	fn_decl.Name = ast.NewIdent(fmt.Sprintf("%s_%s", named.Obj().Name(), method.Name()))
+	fn_decl.Type = new(ast.FuncType)
+	fn_decl.Type.Params = new(ast.FieldList)
+	add_param := func(name string, typ types.Type, typ_expr ast.Expr) *ast.Ident {
+		iden := ast.NewIdent(name)
+		ASTCtxt.TypeInfo.Defs[iden] = types.NewVar(token.NoPos, nil, name, typ)
+		field := new(ast.Field)
+		field.Names = append(field.Names, iden)
+		field.Type = typ_expr
+		fn_decl.Type.Params.List = append(fn_decl.Type.Params.List, field)
+		return iden
+	}
+	self := add_param("self", named, ast.NewIdent(named.Obj().Name()))
+	
+	param_types := make([]ast.Expr, 0)
+	for _, field := range method_type.Params.List {
+		param_types = append(param_types, field.Type)
+		for i := 1; i < len(field.Names); i++ {
+			param_types = append(param_types, field.Type)
+		}
+	}
+	args := make([]ast.Expr, 0)
+	for i := 0; i < sig.Params().Len(); i++ {
+		name := sig.Params().At(i).Name()
+		if name=="" || name=="_" {
+			name = fmt.Sprintf("arg%d", i)
+		}
+		args = append(args, add_param(name, sig.Params().At(i).Type(), param_types[i]))
+	}
+	
+	/// named results so a nil interface returns zero values.
+	if sig.Results().Len() > 0 {
+		fn_decl.Type.Results = new(ast.FieldList)
+		result_types := make([]ast.Expr, 0)
+		for _, field := range method_type.Results.List {
+			for i := 0; i==0 || i < len(field.Names); i++ {
+				result_types = append(result_types, field.Type)
+			}
+		}
+		for i := 0; i < sig.Results().Len(); i++ {
+			result := new(ast.Field)
+			name := ast.NewIdent(fmt.Sprintf("ret%d", i))
+			ASTCtxt.TypeInfo.Defs[name] = types.NewVar(token.NoPos, nil, name.Name, sig.Results().At(i).Type())
+			result.Names = append(result.Names, name)
+			result.Type = result_types[i]
+			fn_decl.Type.Results.List = append(fn_decl.Type.Results.List, result)
+		}
+	}
+	
+	switch_stmt := new(ast.SwitchStmt)
+	switch_stmt.Tag = MakeSelector(self, "typeid")
+	ASTCtxt.TypeInfo.Types[switch_stmt.Tag] = types.TypeAndValue{Type: types.Typ[types.Int]}
+	switch_stmt.Body = new(ast.BlockStmt)
+	for _, impl := range ASTCtxt.IfaceImpls {
+		if !types.Implements(impl.Type, iface) && !types.Implements(types.NewPointer(impl.Type), iface) {
+			continue
+		}
+		/// the value holds the handle, or the index of the enum struct.
+		value := MakeSelector(self, "value")
+		value.X.(*ast.Ident).NamePos = method.Pos()
+		var recv ast.Expr
+		if impl.Storage=="" {
+			recv = MakeCall(impl.Type.Obj().Name(), value)
+		} else if impl.IsArray {
+			recv = MakeIndex(MakeCall("int", value), ast.NewIdent(impl.Storage))
+		} else {
+			recv = ast.NewIdent(impl.Storage)
+		}
+		forward := MakeMethodCall(recv, method.Name(), args...)
+		
+		clause := new(ast.CaseClause)
+		typeid := MakeBasicLit(token.INT, fmt.Sprintf("%d", impl.TypeID))
+		ASTCtxt.TypeInfo.Types[typeid] = types.TypeAndValue{Type: types.Typ[types.UntypedInt], Value: constant.MakeInt64(int64(impl.TypeID))}
+		clause.List = append(clause.List, typeid)
+		if sig.Results().Len() > 0 {
+			ret := new(ast.ReturnStmt)
+			ret.Results = append(ret.Results, forward)
+			clause.Body = append(clause.Body, ret)
+		} else {
+			clause.Body = append(clause.Body, MakeExprStmt(forward))
+		}
+		switch_stmt.Body.List = append(switch_stmt.Body.List, clause)
+	}
+	
+	fn_decl.Body = new(ast.BlockStmt)
+	fn_decl.Body.List = append(fn_decl.Body.List, switch_stmt)
+	if sig.Results().Len() > 0 {
+		fn_decl.Body.List = append(fn_decl.Body.List, new(ast.ReturnStmt))
+	}
+	return fn_decl
+}
+
+/// whether a value fits in a single cell and can be passed as 'any' data directly.
+func IsCellType(typ types.Type) bool {
+	basic, is_basic := typ.Underlying().(*types.Basic)
+	return is_basic && basic.Info() & (types.IsInteger | types.IsBoolean | types.IsFloat) > 0 && !IsStringType(typ)
+}
+
+func IsFloatType(typ types.Type) bool {
+	basic, is_basic := typ.Underlying().(*types.Basic)
+	return is_basic && basic.Info() & types.IsFloat > 0
+}
+
+func IsIntegerType(typ types.Type) bool {
+	basic, is_basic := typ.Underlying().(*types.Basic)
+	return is_basic && basic.Info() & types.IsInteger > 0
+}
+
+func IsBoolType(typ types.Type) bool {
+	basic, is_basic := typ.Underlying().(*types.Basic)
+	return is_basic && basic.Info() & types.IsBoolean > 0
+}
+
+func IsNamedType(typ types.Type, name string) bool {
+	named, is_named := types.Unalias(typ).(*types.Named)
+	return is_named && named.Obj().Name()==name
+}
+
+/// 'Entity' is an alias of int, so it has to be checked before the underlying type is.
+func IsEntityType(typ types.Type) bool {
+	alias, is_alias := typ.(*types.Alias)
+	return is_alias && alias.Obj().Name()=="Entity"
+}
+
+/// whether 'typ' can be carried to the next frame, in a single cell or a DataPack.
+func IsPackableType(typ types.Type) bool {
+	if IsCellType(typ) || IsStringType(typ) {
+		return true
+	} else if array, is_array := typ.Underlying().(*types.Array); is_array {
+		return IsCellType(array.Elem())
+	}
+	return false
+}
+
+func IsGo2SPAfter(call *ast.CallExpr) bool {
+	if sel, is_sel := call.Fun.(*ast.SelectorExpr); is_sel {
+		pkg, is_ident := sel.X.(*ast.Ident)
+		return is_ident && pkg.Name=="go2sp" && sel.Sel.Name=="After"
+	}
+	return false
+}
+
+/** 'go f(x)' runs 'f' on the next frame and 'go2sp.After(delay, f)' after a delay:
+ * 
+ * go Respawn(client)             => RequestFrame(Respawn__frame, client);
+ * 
+ * //go2sp:delay 0.5
+ * go Announce(client, "hi")      => DataPack go_pack0 = CreateDataPack(); ... CreateTimer(0.5, Announce__timer, go_pack0, TIMER_FLAG_NO_MAPCHANGE | TIMER_DATA_HNDL_CLOSE);
+ * 
+ * function literals are hoisted and the local vars they capture are passed by value.
+ */
+func MutateGoStmts(file *ast.File) {
+	/// a '//go2sp:delay N' comment on the line above a statement.
+	ASTCtxt.Delays = make(map[ast.Stmt]string)
+	directives := make(map[int]*ast.CommentGroup)
+	for _, group := range file.Comments {
+		directives[ASTCtxt.FSet.Position(group.End()).Line] = group
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if stmt, is_stmt := n.(ast.Stmt); is_stmt {
+			if delay, found := GetDirective(directives[ASTCtxt.FSet.Position(stmt.Pos()).Line - 1], "delay"); found {
+				if _, is_go := stmt.(*ast.GoStmt); !is_go {
+					PrintSrcGoErr(stmt.Pos(), "'//go2sp:delay' can only be used on go statements.")
+				} else if _, err := strconv.ParseFloat(delay, 64); err != nil {
+					PrintSrcGoErr(stmt.Pos(), fmt.Sprintf("bad delay '%s' for go statement, expected seconds like '0.5'.", delay))
+				} else {
+					if !strings.ContainsAny(delay, ".eE") {
+						delay += ".0"
+					}
+					ASTCtxt.Delays[stmt] = delay
+				}
+			}
+		}
+		return true
+	})
+	
+	ASTCtxt.NewDecls = make([]ast.Decl, 0)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateGoStmt)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+	for _, decl := range ASTCtxt.NewDecls {
+		file.Decls = append(file.Decls, decl)
+		fn_decl := decl.(*ast.FuncDecl)
+		ASTCtxt.FuncMap[fn_decl.Name.Name] = fn_decl
+	}
+	ASTCtxt.NewDecls = nil
+	ASTCtxt.Delays = nil
+	ASTCtxt.Captures = nil
+}
+
+func MutateGoStmt(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateGoStmt)
+		
+		case *ast.ForStmt:
+			bm(n.Body, MutateGoStmt)
+		
+		case *ast.IfStmt:
+			bm(n.Body, MutateGoStmt)
+			if n.Else != nil {
+				MutateGoStmt(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			bm(n.Body, MutateGoStmt)
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateGoStmt(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateGoStmt)
+		
+		case *ast.GoStmt:
+			if delay, found := ASTCtxt.Delays[n]; found {
+				MutateGoCall(owner_list, s, n.Call.Fun, n.Call.Args, MakeBasicLit(token.FLOAT, delay))
+			} else {
+				MutateGoCall(owner_list, s, n.Call.Fun, n.Call.Args, nil)
+			}
+		
+		case *ast.ExprStmt:
+			/// go2sp.After(delay, f)
+			if call, is_call := n.X.(*ast.CallExpr); is_call && IsGo2SPAfter(call) && len(call.Args)==2 {
+				MutateGoCall(owner_list, s, call.Args[1], nil, call.Args[0])
+			}
+	}
+}
+
+/// replaces 's' with the statements packing 'args' for 'fn', scheduled by a timer when 'delay' is set.
+func MutateGoCall(owner_list *[]ast.Stmt, s ast.Stmt, fn ast.Expr, args []ast.Expr, delay ast.Expr) {
+	fn_name, is_ident := fn.(*ast.Ident)
+	if !is_ident {
+		PrintSrcGoErr(s.Pos(), "go statements can only call a function by name or a function literal.")
+		return
+	}
+	/// the function literal given to go2sp.After was hoisted by 'MutateFuncLit', pass along what it captures.
+	args = append(args, ASTCtxt.Captures[fn_name.Name]...)
+	delete(ASTCtxt.Captures, fn_name.Name)
+	sig, is_func := ASTCtxt.TypeInfo.TypeOf(fn_name).(*types.Signature)
+	if _, is_func_obj := ASTCtxt.TypeInfo.Uses[fn_name].(*types.Func); !is_func || !is_func_obj {
+		PrintSrcGoErr(s.Pos(), fmt.Sprintf("go statements can only call a declared function, '%s' isn't one.", fn_name.Name))
+		return
+	} else if sig.Variadic() {
+		PrintSrcGoErr(s.Pos(), fmt.Sprintf("go statements can't call variadic function '%s'.", fn_name.Name))
+		return
+	}
+	for i := 0; i < sig.Params().Len(); i++ {
+		if param := sig.Params().At(i); !IsPackableType(param.Type()) {
+			PrintSrcGoErr(s.Pos(), fmt.Sprintf("param '%s' of '%s' can't be carried to the next frame, only cells, strings and cell arrays can.", param.Name(), fn_name.Name))
+			return
+		}
+	}
+	
+	pkg := ASTCtxt.TypeInfo.Defs[ASTCtxt.CurrFunc.Name].Pkg()
+	packed := sig.Params().Len() > 1 || (sig.Params().Len()==1 && !IsCellType(sig.Params().At(0).Type()))
+	var pack_type types.Type
+	if packed {
+		if obj := pkg.Scope().Lookup("DataPack"); obj==nil {
+			PrintSrcGoErr(s.Pos(), fmt.Sprintf("go statement calling '%s' needs a DataPack for its args, import \"datapack\".", fn_name.Name))
+			return
+		} else {
+			pack_type = obj.Type()
+		}
+	}
+	
+	stmts := make([]ast.Stmt, 0)
+	var data ast.Expr = MakeBasicLit(token.INT, "0")
+	if packed {
+		pack := ast.NewIdent(fmt.Sprintf("go_pack%d", ASTCtxt.TmpVar))
+		ASTCtxt.TmpVar++
+		ASTCtxt.TypeInfo.Defs[pack] = types.NewVar(token.NoPos, pkg, pack.Name, pack_type)
+		pack_decl := MakeVarDecl([]*ast.Ident{pack}, nil, nil)
+		pack_decl.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Type = ast.NewIdent("DataPack")
+		pack_decl.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values = []ast.Expr{MakeCall("CreateDataPack")}
+		stmts = append(stmts, pack_decl)
+		for i, arg := range args {
+			typ := sig.Params().At(i).Type()
+			write := "WriteCell"
+			write_args := []ast.Expr{arg}
+			if IsStringType(typ) {
+				write = "WriteString"
+			} else if IsFloatType(typ) {
+				write = "WriteFloat"
+			} else if array, is_array := typ.Underlying().(*types.Array); is_array {
+				write = "WriteCellArray"
+				if IsFloatType(array.Elem()) {
+					write = "WriteFloatArray"
+				}
+				write_args = append(write_args, MakeBasicLit(token.INT, fmt.Sprintf("%d", array.Len())))
+			}
+			write_args = append(write_args, ast.NewIdent("false"))
+			stmts = append(stmts, MakeExprStmt(MakeMethodCall(ast.NewIdent(pack.Name), write, write_args...)))
+		}
+		data = ast.NewIdent(pack.Name)
+	} else if len(args)==1 {
+		data = args[0]
+	}
+	
+	var schedule *ast.CallExpr
+	tramp_name := fn_name.Name + "__frame"
+	if delay != nil {
+		tramp_name = fn_name.Name + "__timer"
+		var flags ast.Expr = ast.NewIdent("TIMER_FLAG_NO_MAPCHANGE")
+		if packed {
+			/// the timer closes the pack, even when a map change kills it before it fires.
+			flags = MakeBinaryExpr(flags, token.OR, ast.NewIdent("TIMER_DATA_HNDL_CLOSE"))
+		}
+		schedule = MakeCall("CreateTimer", delay, ast.NewIdent(tramp_name), data, flags)
+	} else {
+		schedule = MakeCall("RequestFrame", ast.NewIdent(tramp_name), data)
+	}
+	schedule.Lparen = s.Pos()
+	stmts = append(stmts, MakeExprStmt(schedule))
+	
+	if _, found := ASTCtxt.FuncMap[tramp_name]; !found {
+		tramp := MakeGoTrampoline(tramp_name, fn_name.Name, sig, packed, delay != nil, pkg, s.Pos())
+		ASTCtxt.FuncMap[tramp.Name.Name] = tramp
+		ASTCtxt.NewDecls = append(ASTCtxt.NewDecls, tramp)
+	}
+	
+	index := FindStmt(*owner_list, s)
+	(*owner_list)[index] = stmts[len(stmts)-1]
+	for i := len(stmts)-2; i >= 0; i-- {
+		*owner_list = InsertStmt(*owner_list, index, stmts[i])
+	}
+}
+
+/** public void f__frame(any data) { f(view_as<T>(data)); }
+ * 
+ * a frame trampoline deletes its pack after reading it, a timer's pack is closed by 'TIMER_DATA_HNDL_CLOSE'.
+ * public Action f__timer(Handle timer, any data) {
+ *     DataPack pack = view_as<DataPack>(data); pack.Reset();
+ *     int arg0 = pack.ReadCell(); char arg1[256]; pack.ReadString(arg1, sizeof(arg1));
+ *     f(arg0, arg1);
+ *     return Plugin_Stop;
+ * }
+ */
+func MakeGoTrampoline(name, fn_name string, sig *types.Signature, packed, timer bool, pkg *types.Package, pos token.Pos) *ast.FuncDecl {
+	/// the tolerated 'any' conversions are reported at the go statement.
+	at_pos := func(name string) *ast.Ident {
+		iden := ast.NewIdent(name)
+		iden.NamePos = pos
+		return iden
+	}
+	tramp := new(ast.FuncDecl)
+	tramp.Name = ast.NewIdent(name)
+	tramp.Type = new(ast.FuncType)
+	tramp.Type.Params = new(ast.FieldList)
+	add_param := func(name, type_name string) *ast.Ident {
+		iden := ast.NewIdent(name)
+		ASTCtxt.TypeInfo.Defs[iden] = types.NewVar(token.NoPos, pkg, name, pkg.Scope().Lookup(type_name).Type())
+		field := new(ast.Field)
+		field.Names = append(field.Names, iden)
+		field.Type = ast.NewIdent(type_name)
+		tramp.Type.Params.List = append(tramp.Type.Params.List, field)
+		return iden
+	}
+	if timer {
+		add_param("timer", "Timer")
+		tramp.Type.Results = new(ast.FieldList)
+		result := new(ast.Field)
+		result.Type = ast.NewIdent("Action")
+		tramp.Type.Results.List = append(tramp.Type.Results.List, result)
+	}
+	data := add_param("data", "any")
+	
+	tramp.Body = new(ast.BlockStmt)
+	args := make([]ast.Expr, 0)
+	if packed {
+		pack := ast.NewIdent("pack")
+		ASTCtxt.TypeInfo.Defs[pack] = types.NewVar(token.NoPos, pkg, pack.Name, pkg.Scope().Lookup("DataPack").Type())
+		pack_decl := MakeVarDecl([]*ast.Ident{pack}, nil, nil)
+		pack_decl.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Type = ast.NewIdent("DataPack")
+		pack_decl.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values = []ast.Expr{MakeCall("DataPack", at_pos(data.Name))}
+		tramp.Body.List = append(tramp.Body.List, pack_decl, MakeExprStmt(MakeMethodCall(ast.NewIdent("pack"), "Reset", ast.NewIdent("false"))))
+		
+		for i := 0; i < sig.Params().Len(); i++ {
+			typ := sig.Params().At(i).Type()
+			arg := ast.NewIdent(fmt.Sprintf("arg%d", i))
+			ASTCtxt.TypeInfo.Defs[arg] = types.NewVar(token.NoPos, pkg, arg.Name, typ)
+			arg_decl := MakeVarDecl([]*ast.Ident{arg}, nil, nil)
+			arg_spec := arg_decl.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+			arg_spec.Type = MakeTypeArgExpr(typ)
+			tramp.Body.List = append(tramp.Body.List, arg_decl)
+			if IsStringType(typ) {
+				read := MakeMethodCall(ast.NewIdent("pack"), "ReadString", at_pos(arg.Name), MakeCall("sizeof", ast.NewIdent(arg.Name)))
+				tramp.Body.List = append(tramp.Body.List, MakeExprStmt(read))
+			} else if array, is_array := typ.Underlying().(*types.Array); is_array {
+				read := "ReadCellArray"
+				if IsFloatType(array.Elem()) {
+					read = "ReadFloatArray"
+				}
+				tramp.Body.List = append(tramp.Body.List, MakeExprStmt(MakeMethodCall(ast.NewIdent("pack"), read, ast.NewIdent(arg.Name), MakeBasicLit(token.INT, fmt.Sprintf("%d", array.Len())))))
+			} else if IsFloatType(typ) {
+				arg_spec.Values = []ast.Expr{MakeMethodCall(ast.NewIdent("pack"), "ReadFloat")}
+			} else {
+				read := MakeMethodCall(ast.NewIdent("pack"), "ReadCell")
+				read.Fun.(*ast.SelectorExpr).X.(*ast.Ident).NamePos = pos
+				arg_spec.Values = []ast.Expr{MakeCall("", read)}
+				arg_spec.Values[0].(*ast.CallExpr).Fun = MakeTypeArgExpr(typ)
+			}
+			args = append(args, ast.NewIdent(arg.Name))
+		}
+		if !timer {
+			tramp.Body.List = append(tramp.Body.List, MakeExprStmt(MakeCall("__sp__", MakeBasicLit(token.STRING, `"delete pack;"`))))
+		}
+	} else if sig.Params().Len()==1 {
+		to_param := MakeCall("", at_pos(data.Name))
+		to_param.Fun = MakeTypeArgExpr(sig.Params().At(0).Type())
+		args = append(args, to_param)
+	}
+	
+	/// results are dropped, there's nobody to return them to.
+	tramp.Body.List = append(tramp.Body.List, MakeExprStmt(MakeCall(fn_name, args...)))
+	if timer {
+		ret := new(ast.ReturnStmt)
+		ret.Results = append(ret.Results, ast.NewIdent("Plugin_Stop"))
+		tramp.Body.List = append(tramp.Body.List, ret)
+	}
+	return tramp
+}
+
+/// a 'Sleep(seconds)' or 'WaitFrames(n)' call statement that suspends an async function.
+func GetSuspendCall(s ast.Stmt) *ast.CallExpr {
+	if expr_stmt, is_expr := s.(*ast.ExprStmt); is_expr {
+		if call, is_call := expr_stmt.X.(*ast.CallExpr); is_call && len(call.Args)==1 {
+			if iden, is_ident := call.Fun.(*ast.Ident); is_ident && (iden.Name=="Sleep" || iden.Name=="WaitFrames") && ASTCtxt.TypeInfo.Uses[iden]==types.Universe.Lookup(iden.Name) {
+				return call
+			}
+		}
+	}
+	return nil
+}
+
+func IsAsyncFunc(f *ast.FuncDecl) bool {
+	_, found := GetDirective(f.Doc, "async")
+	return found && f.Body != nil
+}
+
+/// async functions return the Task that cancels them, so they return 0 wherever they end.
+func MutateAsyncRets(file *ast.File) {
+	for _, decl := range file.Decls {
+		f, is_func := decl.(*ast.FuncDecl)
+		if !is_func || !IsAsyncFunc(f) {
+			continue
+		} else if f.Recv != nil || f.Type.TypeParams != nil {
+			PrintSrcGoErr(f.Pos(), fmt.Sprintf("async function '%s' can't be a method or generic.", f.Name.Name))
+			continue
+		}
+		
+		if f.Type.Results==nil || len(f.Type.Results.List)==0 {
+			result := new(ast.Field)
+			result.Type = ast.NewIdent("Task")
+			f.Type.Results = new(ast.FieldList)
+			f.Type.Results.List = append(f.Type.Results.List, result)
+		} else if task, is_ident := f.Type.Results.List[0].Type.(*ast.Ident); len(f.Type.Results.List) > 1 || len(f.Type.Results.List[0].Names) > 0 || !is_ident || task.Name != "Task" {
+			PrintSrcGoErr(f.Type.Results.Pos(), fmt.Sprintf("async function '%s' can only return an unnamed Task.", f.Name.Name))
+			continue
+		}
+		
+		ast.Inspect(f.Body, func(n ast.Node) bool {
+			switch x := n.(type) {
+				case *ast.FuncLit:
+					return false
+				case *ast.ReturnStmt:
+					if len(x.Results)==0 {
+						x.Results = append(x.Results, MakeBasicLit(token.INT, "0"))
+					}
+			}
+			return true
+		})
+		if len(f.Body.List)==0 {
+			f.Body.List = append(f.Body.List, new(ast.ReturnStmt))
+			f.Body.List[0].(*ast.ReturnStmt).Results = append(f.Body.List[0].(*ast.ReturnStmt).Results, MakeBasicLit(token.INT, "0"))
+		} else if _, is_ret := f.Body.List[len(f.Body.List)-1].(*ast.ReturnStmt); !is_ret {
+			ret := new(ast.ReturnStmt)
+			ret.Results = append(ret.Results, MakeBasicLit(token.INT, "0"))
+			f.Body.List = append(f.Body.List, ret)
+		}
+	}
+}
+
+/** '//go2sp:async' functions are split at each 'Sleep' or 'WaitFrames', and at the ifs and for-loops holding one, into a state machine:
+ * 
+ * //go2sp:async
+ * func Intro(client int) {              public int Intro(int client) {
+ *     EmitSoundToAll(snd)                   EmitSoundToAll(snd);
+ *     Sleep(2.0)                            int async_task = Intro__start(); ...
+ *     TeleportEntity(client, ...)           Intro__states[async_slot].client = client;
+ * }                                         Intro__states[async_slot].async_state = 1;
+ *                                           CreateTimer(2.0, Intro__wake, async_task, 0);
+ *                                           return async_task;
+ *                                       }
+ * 
+ * locals that live across a suspension are kept in a global array of enum structs, '//go2sp:async N' sets its size.
+ * each resumption runs the next part from 'Intro__resume' and the returned Task id can be given to 'CancelTask'.
+ * loops and branches become states that jump to each other inside 'Intro__run'.
+ * returns whether anything changed and needs type-checking again.
+ */
+func MutateAsyncFuncs(file *ast.File) bool {
+	ASTCtxt.NewDecls = make([]ast.Decl, 0)
+	suspends := make(map[*ast.CallExpr]bool)
+	asyncs := make([]*ast.FuncDecl, 0)
+	for _, decl := range file.Decls {
+		if f, is_func := decl.(*ast.FuncDecl); is_func && f.Body != nil && IsAsyncFunc(f) && f.Recv==nil && f.Type.TypeParams==nil {
+			CollectAsyncSuspends(f.Body.List, suspends)
+			asyncs = append(asyncs, f)
+		}
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if stmt, is_stmt := n.(ast.Stmt); is_stmt {
+			if call := GetSuspendCall(stmt); call != nil && !suspends[call] {
+				PrintSrcGoErr(call.Pos(), fmt.Sprintf("'%s' can only be called in the blocks, ifs and for-loops of a '//go2sp:async' function.", call.Fun.(*ast.Ident).Name))
+			}
+		}
+		return true
+	})
+	if len(asyncs)==0 {
+		return false
+	}
+	
+	serial := ast.NewIdent("go2sp_task_serial")
+	serial_spec := new(ast.ValueSpec)
+	serial_spec.Names = append(serial_spec.Names, serial)
+	serial_spec.Type = ast.NewIdent("int")
+	serial_decl := new(ast.GenDecl)
+	serial_decl.Tok = token.VAR
+	serial_decl.Specs = append(serial_decl.Specs, serial_spec)
+	ASTCtxt.NewDecls = append(ASTCtxt.NewDecls, serial_decl)
+	
+	/// func CancelTask(task Task) bool
+	cancel := new(ast.FuncDecl)
+	cancel.Name = ast.NewIdent("CancelTask")
+	cancel.Type = new(ast.FuncType)
+	cancel.Type.Params = MakeFieldList("task", ast.NewIdent("Task"))
+	cancel.Type.Results = MakeFieldList("", ast.NewIdent("bool"))
+	cancel.Body = new(ast.BlockStmt)
+	for func_index, f := range asyncs {
+		slots := MakeAsyncStateMachine(f, func_index, len(asyncs))
+		if slots==0 {
+			continue
+		}
+		/// if Intro__states[int(task) / F % N].async_task==task && task != 0 { Intro__states[...].async_task = 0; return true }
+		state_task := func() ast.Expr {
+			slot := MakeAsyncSlot(ast.NewIdent("task"), slots, len(asyncs))
+			return MakeSelector(MakeIndex(slot, ast.NewIdent(f.Name.Name + "__states")), "async_task")
+		}
+		if_stmt := new(ast.IfStmt)
+		if_stmt.Cond = MakeBinaryExpr(MakeBinaryExpr(state_task(), token.EQL, ast.NewIdent("task")), token.LAND, MakeBinaryExpr(ast.NewIdent("task"), token.NEQ, MakeBasicLit(token.INT, "0")))
+		if_stmt.Body = new(ast.BlockStmt)
+		ret := new(ast.ReturnStmt)
+		ret.Results = append(ret.Results, ast.NewIdent("true"))
+		if_stmt.Body.List = append(if_stmt.Body.List, MakeAssignTok(state_task(), token.ASSIGN, MakeBasicLit(token.INT, "0")), ret)
+		cancel.Body.List = append(cancel.Body.List, if_stmt)
+	}
+	ret := new(ast.ReturnStmt)
+	ret.Results = append(ret.Results, ast.NewIdent("false"))
+	cancel.Body.List = append(cancel.Body.List, ret)
+	ASTCtxt.NewDecls = append(ASTCtxt.NewDecls, cancel)
+	
+	for _, decl := range ASTCtxt.NewDecls {
+		file.Decls = append(file.Decls, decl)
+		if fn_decl, is_func := decl.(*ast.FuncDecl); is_func {
+			ASTCtxt.FuncMap[fn_decl.Name.Name] = fn_decl
+		}
+	}
+	ASTCtxt.NewDecls = nil
+	return true
+}
+
+/// (name type) or (type) when name is empty.
+func MakeFieldList(name string, typ ast.Expr) *ast.FieldList {
+	fields := new(ast.FieldList)
+	field := new(ast.Field)
+	if name != "" {
+		field.Names = append(field.Names, ast.NewIdent(name))
+	}
+	field.Type = typ
+	fields.List = append(fields.List, field)
+	return fields
+}
+
+/// zero value of a basic type, nil if there's no literal for it.
+func MakeZeroValue(typ types.Type) ast.Expr {
+	if basic, is_basic := typ.Underlying().(*types.Basic); is_basic {
+		switch {
+			case IsStringType(typ):
+				return MakeBasicLit(token.STRING, `""`)
+			case basic.Info() & types.IsBoolean > 0:
+				return ast.NewIdent("false")
+			case basic.Info() & types.IsFloat > 0:
+				return MakeBasicLit(token.FLOAT, "0.0")
+			case basic.Info() & types.IsInteger > 0:
+				return MakeBasicLit(token.INT, "0")
+		}
+	}
+	return nil
+}
+
+/** task ids are '(serial * N + slot) * F + func_index' for F async functions,
+ * so the ids of different functions never collide and the slot is 'int(task) / F % N'.
+ */
+func MakeAsyncSlot(task ast.Expr, slots, funcs int) ast.Expr {
+	id := MakeCall("int", task)
+	if funcs > 1 {
+		return MakeBinaryExpr(MakeBinaryExpr(id, token.QUO, MakeBasicLit(token.INT, strconv.Itoa(funcs))), token.REM, MakeBasicLit(token.INT, strconv.Itoa(slots)))
+	}
+	return MakeBinaryExpr(id, token.REM, MakeBasicLit(token.INT, strconv.Itoa(slots)))
+}
+
+/// a state of an async function that's jumped to, its jumps are numbered once every state is made.
+type AsyncLabel struct {
+	State  int
+	Alias  *AsyncLabel  /// set when the state is empty and skipped.
+	Lits   []*ast.BasicLit
+}
+
+/// the 'Sleep' and 'WaitFrames' calls in an async function's blocks, ifs and for-loops.
+func CollectAsyncSuspends(stmts []ast.Stmt, suspends map[*ast.CallExpr]bool) {
+	for _, stmt := range stmts {
+		if call := GetSuspendCall(stmt); call != nil {
+			suspends[call] = true
+			continue
+		}
+		switch n := stmt.(type) {
+			case *ast.BlockStmt:
+				CollectAsyncSuspends(n.List, suspends)
+			case *ast.IfStmt:
+				CollectAsyncSuspends(n.Body.List, suspends)
+				if n.Else != nil {
+					CollectAsyncSuspends([]ast.Stmt{n.Else}, suspends)
+				}
+			case *ast.ForStmt:
+				CollectAsyncSuspends(n.Body.List, suspends)
+		}
+	}
+}
+
+func HasSuspendCall(stmt ast.Stmt) bool {
+	found := false
+	ast.Inspect(stmt, func(n ast.Node) bool {
+		switch x := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case ast.Stmt:
+				if GetSuspendCall(x) != nil {
+					found = true
+				}
+		}
+		return !found
+	})
+	return found
+}
+
+/// replaces the 'break' and 'continue' that leave a split for-loop's body with 'jump'.
+func ReplaceAsyncBranches(list *[]ast.Stmt, in_switch bool, jump func(tok token.Token) []ast.Stmt) {
+	stmts := make([]ast.Stmt, 0, len(*list))
+	for _, stmt := range *list {
+		switch n := stmt.(type) {
+			case *ast.BranchStmt:
+				if n.Label==nil && (n.Tok==token.CONTINUE || (n.Tok==token.BREAK && !in_switch)) {
+					stmts = append(stmts, jump(n.Tok)...)
+					continue
+				}
+			case *ast.BlockStmt:
+				ReplaceAsyncBranches(&n.List, in_switch, jump)
+			case *ast.IfStmt:
+				ReplaceAsyncBranches(&n.Body.List, in_switch, jump)
+				if n.Else != nil {
+					else_list := []ast.Stmt{n.Else}
+					ReplaceAsyncBranches(&else_list, in_switch, jump)
+					n.Else = else_list[0]
+				}
+			case *ast.SwitchStmt:
+				ReplaceAsyncBranches(&n.Body.List, true, jump)
+			case *ast.TypeSwitchStmt:
+				ReplaceAsyncBranches(&n.Body.List, true, jump)
+			case *ast.SelectStmt:
+				ReplaceAsyncBranches(&n.Body.List, true, jump)
+			case *ast.CaseClause:
+				ReplaceAsyncBranches(&n.Body, in_switch, jump)
+			case *ast.CommClause:
+				ReplaceAsyncBranches(&n.Body, in_switch, jump)
+		}
+		stmts = append(stmts, stmt)
+	}
+	*list = stmts
+}
+
+/// splits an async function into its state machine decls, added to 'ASTCtxt.NewDecls', and returns the number of task slots.
+func MakeAsyncStateMachine(f *ast.FuncDecl, func_index, funcs int) int {
+	slots := 64
+	if arg, _ := GetDirective(f.Doc, "async"); arg != "" {
+		if n, err := strconv.Atoi(arg); err != nil || n <= 0 {
+			PrintSrcGoErr(f.Pos(), fmt.Sprintf("bad task count '%s' for async function '%s'.", arg, f.Name.Name))
+		} else {
+			slots = n
+		}
+	}
+	
+	name := f.Name.Name
+	states_name := name + "__states"
+	pos := f.Pos()
+	state_field := func(field string, pos token.Pos) ast.Expr {
+		slot := ast.NewIdent(states_name)
+		slot.NamePos = pos
+		sel := new(ast.SelectorExpr)
+		sel.X = MakeIndex(ast.NewIdent("async_slot"), slot)
+		sel.Sel = ast.NewIdent(field)
+		return sel
+	}
+	make_ret := func(results ...ast.Expr) *ast.ReturnStmt {
+		ret := new(ast.ReturnStmt)
+		ret.Results = results
+		return ret
+	}
+	make_block := func(stmts ...ast.Stmt) *ast.BlockStmt {
+		block := new(ast.BlockStmt)
+		block.List = stmts
+		return block
+	}
+	free_slot := func() ast.Stmt {
+		return MakeAssignTok(state_field("async_task", pos), token.ASSIGN, MakeBasicLit(token.INT, "0"))
+	}
+	
+	/** the body is split into states at each suspension and at the ifs and for-loops holding one.
+	 * state 0 is the function itself, it takes a task slot at 'entry' right before it first leaves.
+	 * the other states are the cases of 'Intro__run', which loops over them when they jump to each other.
+	 */
+	states := make([][]ast.Stmt, 1)
+	entry, jumps := -1, false
+	placed, skipped := make(map[int][]*AsyncLabel), make(map[int]bool)
+	labels := make([]*AsyncLabel, 0)
+	new_state := func() int {
+		states = append(states, make([]ast.Stmt, 0))
+		return len(states)-1
+	}
+	emit := func(state int, stmts ...ast.Stmt) {
+		states[state] = append(states[state], stmts...)
+	}
+	var terminates func(stmt ast.Stmt) bool
+	terminates = func(stmt ast.Stmt) bool {
+		switch n := stmt.(type) {
+			case *ast.ReturnStmt, *ast.BranchStmt:
+				return true
+			case *ast.BlockStmt:
+				return len(n.List) > 0 && terminates(n.List[len(n.List)-1])
+			case *ast.IfStmt:
+				return n.Else != nil && terminates(n.Body) && terminates(n.Else)
+		}
+		return false
+	}
+	ends := func(state int) bool {
+		return len(states[state]) > 0 && terminates(states[state][len(states[state])-1])
+	}
+	place := func(label *AsyncLabel) int {
+		label.State = new_state()
+		placed[label.State] = append(placed[label.State], label)
+		return label.State
+	}
+	resolve := func(label *AsyncLabel) *AsyncLabel {
+		for label.Alias != nil {
+			label = label.Alias
+		}
+		return label
+	}
+	/// moves to the next state, state 0 runs it right away and the others loop back into 'Intro__run'.
+	set_state := func(label *AsyncLabel) ast.Stmt {
+		lit := MakeBasicLit(token.INT, "0")
+		label.Lits = append(label.Lits, lit)
+		labels = append(labels, label)
+		return MakeAssignTok(state_field("async_state", pos), token.ASSIGN, lit)
+	}
+	jump := func(state int, label *AsyncLabel) []ast.Stmt {
+		if state==0 {
+			done := new(ast.IfStmt)
+			not_suspended := new(ast.UnaryExpr)
+			not_suspended.Op = token.NOT
+			not_suspended.X = MakeCall(name + "__run", ast.NewIdent("async_task"), ast.NewIdent("async_slot"))
+			done.Cond = not_suspended
+			done.Body = make_block(free_slot())
+			return []ast.Stmt{ set_state(label), done, make_ret(ast.NewIdent("async_task")) }
+		}
+		jumps = true
+		cont := new(ast.BranchStmt)
+		cont.Tok = token.CONTINUE
+		return []ast.Stmt{ set_state(label), cont }
+	}
+	jump_to := func(state int, label *AsyncLabel) {
+		if ends(state) {
+			return
+		} else if state > 0 && len(states[state])==0 && resolve(label).State != state {
+			/// an empty state is skipped, what jumps to it goes to 'label' instead.
+			for _, skip := range placed[state] {
+				skip.Alias = label
+			}
+			skipped[state] = true
+			return
+		}
+		emit(state, jump(state, label)...)
+	}
+	/// sets the next state and schedules its resumption.
+	suspend := func(state int, call *ast.CallExpr, next *AsyncLabel) []ast.Stmt {
+		stmts := []ast.Stmt{ set_state(next) }
+		var schedule *ast.CallExpr
+		if call.Fun.(*ast.Ident).Name=="Sleep" {
+			/// the timer isn't killed by map changes, so the task always gets to free its slot.
+			schedule = MakeCall("CreateTimer", call.Args[0], ast.NewIdent(name + "__wake"), ast.NewIdent("async_task"), MakeBasicLit(token.INT, "0"))
+		} else {
+			stmts = append(stmts, MakeAssignTok(state_field("async_wait", pos), token.ASSIGN, call.Args[0]))
+			schedule = MakeCall("RequestFrame", ast.NewIdent(name + "__tick"), ast.NewIdent("async_task"))
+		}
+		schedule.Lparen = call.Pos()
+		stmts = append(stmts, MakeExprStmt(schedule))
+		if state==0 {
+			return append(stmts, make_ret(ast.NewIdent("async_task")))
+		}
+		return append(stmts, make_ret(ast.NewIdent("true")))
+	}
+	/// user code, returning ends the task and frees its slot.
+	user_code := func(state int, stmt ast.Stmt, brk, cont *AsyncLabel) []ast.Stmt {
+		list := []ast.Stmt{stmt}
+		if brk != nil {
+			ReplaceAsyncBranches(&list, false, func(tok token.Token) []ast.Stmt {
+				if tok==token.BREAK {
+					return jump(state, brk)
+				}
+				return jump(state, cont)
+			})
+		}
+		if state > 0 {
+			for _, user_stmt := range list {
+				ast.Inspect(user_stmt, func(n ast.Node) bool {
+					switch x := n.(type) {
+						case *ast.FuncLit:
+							return false
+						case *ast.ReturnStmt:
+							x.Results = []ast.Expr{ast.NewIdent("false")}
+					}
+					return true
+				})
+			}
+		} else if entry != -1 {
+			InsertBeforeExits(&list, nil, free_slot)
+		}
+		return list
+	}
+	emit_user := func(state int, stmt ast.Stmt, brk, cont *AsyncLabel) {
+		emit(state, user_code(state, stmt, brk, cont)...)
+	}
+	
+	var split func(stmts []ast.Stmt, state int, brk, cont *AsyncLabel) int
+	split = func(stmts []ast.Stmt, state int, brk, cont *AsyncLabel) int {
+		for _, stmt := range stmts {
+			if !HasSuspendCall(stmt) {
+				emit_user(state, stmt, brk, cont)
+				continue
+			}
+			if state==0 && entry==-1 {
+				entry = len(states[0])
+			}
+			if call := GetSuspendCall(stmt); call != nil {
+				next := new(AsyncLabel)
+				emit(state, suspend(state, call, next)...)
+				state = place(next)
+				continue
+			}
+			switch n := stmt.(type) {
+				case *ast.BlockStmt:
+					state = split(n.List, state, brk, cont)
+				
+				case *ast.IfStmt:
+					/// if cond { state = then } else { else-code }, an else that suspends is split after the if.
+					if n.Init != nil {
+						emit_user(state, n.Init, brk, cont)
+					}
+					then, after := new(AsyncLabel), new(AsyncLabel)
+					branch := new(ast.IfStmt)
+					branch.If = n.If
+					branch.Cond = n.Cond
+					branch.Body = make_block(jump(state, then)...)
+					else_end := state
+					if n.Else != nil && !HasSuspendCall(n.Else) {
+						branch.Else = user_code(state, n.Else, brk, cont)[0]
+						emit(state, branch)
+					} else if n.Else != nil {
+						emit(state, branch)
+						else_end = split([]ast.Stmt{n.Else}, state, brk, cont)
+					} else {
+						emit(state, branch)
+					}
+					then_end := split(n.Body.List, place(then), brk, cont)
+					jump_to(then_end, after)
+					jump_to(else_end, after)
+					state = place(after)
+				
+				case *ast.ForStmt:
+					/// init; loop: if !cond { state = after } body... post: post; state = loop
+					if n.Init != nil {
+						emit_user(state, n.Init, brk, cont)
+					}
+					loop, post, after := new(AsyncLabel), new(AsyncLabel), new(AsyncLabel)
+					jump_to(state, loop)
+					loop_state := place(loop)
+					if n.Cond != nil {
+						exit := new(ast.IfStmt)
+						exit.If = n.For
+						not_cond := new(ast.UnaryExpr)
+						not_cond.Op = token.NOT
+						not_cond.X = MakeParenExpr(n.Cond)
+						exit.Cond = not_cond
+						exit.Body = make_block(jump(loop_state, after)...)
+						emit(loop_state, exit)
+					}
+					body_end := split(n.Body.List, loop_state, after, post)
+					jump_to(body_end, post)
+					post_state := place(post)
+					if n.Post != nil {
+						emit_user(post_state, n.Post, nil, nil)
+					}
+					jump_to(post_state, loop)
+					state = place(after)
+				
+				default:
+					/// only reached for suspensions that were already reported.
+					emit_user(state, stmt, brk, cont)
+			}
+		}
+		return state
+	}
+	split(f.Body.List, 0, nil, nil)
+	if entry==-1 {
+		return 0
+	}
+	/// a state nothing jumps to, like the one after an endless loop, can't run and is dropped.
+	targets := make(map[int]bool)
+	for _, label := range labels {
+		targets[resolve(label).State] = true
+	}
+	for state := 1; state < len(states); state++ {
+		if !targets[state] {
+			skipped[state] = true
+		}
+	}
+	numbers := make(map[int]int)
+	for state := 1; state < len(states); state++ {
+		if !skipped[state] {
+			numbers[state] = len(numbers) + 1
+		}
+	}
+	for _, label := range labels {
+		for _, lit := range label.Lits {
+			lit.Value = strconv.Itoa(numbers[resolve(label).State])
+		}
+	}
+	
+	/// locals used in more than one state live in the task's state, state 0 before and after 'entry' count as two.
+	first_part := make(map[*types.Var]int)
+	fields := make(map[*types.Var]string)
+	live_order := make([]*types.Var, 0)
+	field_names := map[string]bool{ "async_task": true, "async_state": true, "async_wait": true }
+	note_var := func(obj types.Object, part int) {
+		v, is_var := obj.(*types.Var)
+		if !is_var || v.IsField() || v.Pos() < f.Pos() || v.Pos() >= f.End() {
+			return
+		}
+		if first, seen := first_part[v]; !seen {
+			first_part[v] = part
+		} else if _, is_live := fields[v]; first != part && !is_live {
+			/// shadowed locals, like the 'i' of two loops, each get a field.
+			field := v.Name()
+			for n := 1; field_names[field]; n++ {
+				field = fmt.Sprintf("%s%d", v.Name(), n)
+			}
+			field_names[field] = true
+			fields[v] = field
+			live_order = append(live_order, v)
+		}
+	}
+	note_stmts := func(stmts []ast.Stmt, part int) {
+		for _, stmt := range stmts {
+			ast.Inspect(stmt, func(n ast.Node) bool {
+				if iden, is_ident := n.(*ast.Ident); is_ident {
+					if obj := ASTCtxt.TypeInfo.Uses[iden]; obj != nil {
+						note_var(obj, part)
+					} else if obj := ASTCtxt.TypeInfo.Defs[iden]; obj != nil {
+						note_var(obj, part)
+					}
+				}
+				return true
+			})
+		}
+	}
+	for _, field := range f.Type.Params.List {
+		for _, name := range field.Names {
+			note_var(ASTCtxt.TypeInfo.Defs[name], 0)
+		}
+	}
+	note_stmts(states[0][:entry], 0)
+	note_stmts(states[0][entry:], -1)
+	for state := 1; state < len(states); state++ {
+		note_stmts(states[state], state)
+	}
+	
+	/// type Intro__state struct { async_task Task; async_state, async_wait int; live locals... }
+	struc := new(ast.StructType)
+	struc.Fields = new(ast.FieldList)
+	add_field := func(field_name string, typ ast.Expr) {
+		field := new(ast.Field)
+		field.Names = append(field.Names, ast.NewIdent(field_name))
+		field.Type = typ
+		struc.Fields.List = append(struc.Fields.List, field)
+	}
+	add_field("async_task", ast.NewIdent("Task"))
+	add_field("async_state", ast.NewIdent("int"))
+	add_field("async_wait", ast.NewIdent("int"))
+	for _, v := range live_order {
+		add_field(fields[v], MakeTypeArgExpr(v.Type()))
+	}
+	type_spec := new(ast.TypeSpec)
+	type_spec.Name = ast.NewIdent(name + "__state")
+	type_spec.Type = struc
+	type_decl := new(ast.GenDecl)
+	type_decl.Tok = token.TYPE
+	type_decl.Specs = append(type_decl.Specs, type_spec)
+	
+	/// var Intro__states [N]Intro__state
+	states_spec := new(ast.ValueSpec)
+	states_spec.Names = append(states_spec.Names, ast.NewIdent(states_name))
+	states_spec.Type = Arrayify(ast.NewIdent(name + "__state"), MakeBasicLit(token.INT, strconv.Itoa(slots)))
+	states_decl := new(ast.GenDecl)
+	states_decl.Tok = token.VAR
+	states_decl.Specs = append(states_decl.Specs, states_spec)
+	ASTCtxt.NewDecls = append(ASTCtxt.NewDecls, type_decl, states_decl)
+	
+	/// var name type = value
+	make_local := func(local, type_name string, value ast.Expr) ast.Stmt {
+		decl := MakeVarDecl([]*ast.Ident{ast.NewIdent(local)}, nil, nil)
+		val_spec := decl.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+		val_spec.Type = ast.NewIdent(type_name)
+		val_spec.Values = append(val_spec.Values, value)
+		return decl
+	}
+	get_slot := func() ast.Stmt {
+		return make_local("async_slot", "int", MakeAsyncSlot(ast.NewIdent("async_task"), slots, funcs))
+	}
+	make_func := func(fn_name string, params, results *ast.FieldList) *ast.FuncDecl {
+		fn_decl := new(ast.FuncDecl)
+		fn_decl.Name = ast.NewIdent(fn_name)
+		fn_decl.Type = new(ast.FuncType)
+		fn_decl.Type.Params = params
+		fn_decl.Type.Results = results
+		fn_decl.Body = new(ast.BlockStmt)
+		ASTCtxt.NewDecls = append(ASTCtxt.NewDecls, fn_decl)
+		return fn_decl
+	}
+	
+	/// the function itself runs up to 'entry', then takes a task slot and saves what the rest needs.
+	start := make([]ast.Stmt, 0)
+	start = append(start, make_local("async_task", "Task", MakeCall(name + "__start")))
+	full := new(ast.IfStmt)
+	full.Cond = MakeBinaryExpr(ast.NewIdent("async_task"), token.EQL, MakeBasicLit(token.INT, "0"))
+	full.Body = new(ast.BlockStmt)
+	log_err := MakeCall("LogError", MakeBasicLit(token.STRING, fmt.Sprintf(`"too many running '%s' tasks, raise the count with '//go2sp:async N'."`, name)))
+	full.Body.List = append(full.Body.List, MakeExprStmt(log_err), make_ret(MakeBasicLit(token.INT, "0")))
+	start = append(start, full, get_slot())
+	for _, v := range live_order {
+		if first_part[v]==0 {
+			start = append(start, MakeAssignTok(state_field(fields[v], pos), token.ASSIGN, ast.NewIdent(v.Name())))
+		}
+	}
+	f.Body.List = append(append(states[0][:entry:entry], start...), MakeAsyncPart(states[0][entry:], fields, state_field)...)
+	
+	/// func Intro__start() Task { for async_slot := 0; async_slot < N; async_slot++ { if free { take it } }; return 0 }
+	take := make_func(name + "__start", new(ast.FieldList), MakeFieldList("", ast.NewIdent("Task")))
+	for_stmt := new(ast.ForStmt)
+	for_stmt.Init = MakeAssignTok(ast.NewIdent("async_slot"), token.DEFINE, MakeBasicLit(token.INT, "0"))
+	for_stmt.Cond = MakeBinaryExpr(ast.NewIdent("async_slot"), token.LSS, MakeBasicLit(token.INT, strconv.Itoa(slots)))
+	slot_inc := new(ast.IncDecStmt)
+	slot_inc.X = ast.NewIdent("async_slot")
+	slot_inc.Tok = token.INC
+	for_stmt.Post = slot_inc
+	for_stmt.Body = new(ast.BlockStmt)
+	free := new(ast.IfStmt)
+	free.Cond = MakeBinaryExpr(state_field("async_task", pos), token.EQL, MakeBasicLit(token.INT, "0"))
+	free.Body = new(ast.BlockStmt)
+	serial_inc := new(ast.IncDecStmt)
+	serial_inc.X = ast.NewIdent("go2sp_task_serial")
+	serial_inc.Tok = token.INC
+	var task_id ast.Expr = MakeBinaryExpr(MakeBinaryExpr(ast.NewIdent("go2sp_task_serial"), token.MUL, MakeBasicLit(token.INT, strconv.Itoa(slots))), token.ADD, ast.NewIdent("async_slot"))
+	if funcs > 1 {
+		task_id = MakeBinaryExpr(MakeParenExpr(task_id), token.MUL, MakeBasicLit(token.INT, strconv.Itoa(funcs)))
+		if func_index > 0 {
+			task_id = MakeBinaryExpr(task_id, token.ADD, MakeBasicLit(token.INT, strconv.Itoa(func_index)))
+		}
+	}
+	free.Body.List = append(free.Body.List, serial_inc, MakeAssignTok(state_field("async_task", pos), token.ASSIGN, task_id), make_ret(state_field("async_task", pos)))
+	for_stmt.Body.List = append(for_stmt.Body.List, free)
+	take.Body.List = append(take.Body.List, for_stmt, make_ret(MakeBasicLit(token.INT, "0")))
+	
+	/** func Intro__run(async_task Task, async_slot int) bool { switch state.async_state { ... } }, true when it's suspended again.
+	 * states that jump to each other need 'for { switch ... }', so every case ends in a return or a 'continue'.
+	 */
+	run_params := MakeFieldList("async_task", ast.NewIdent("Task"))
+	run_params.List = append(run_params.List, MakeFieldList("async_slot", ast.NewIdent("int")).List...)
+	run := make_func(name + "__run", run_params, MakeFieldList("", ast.NewIdent("bool")))
+	switch_stmt := new(ast.SwitchStmt)
+	switch_stmt.Tag = state_field("async_state", pos)
+	switch_stmt.Body = new(ast.BlockStmt)
+	for state := 1; state < len(states); state++ {
+		if skipped[state] {
+			continue
+		}
+		clause := new(ast.CaseClause)
+		clause.List = append(clause.List, MakeBasicLit(token.INT, strconv.Itoa(numbers[state])))
+		clause.Body = MakeAsyncPart(states[state], fields, state_field)
+		if jumps {
+			if !ends(state) {
+				clause.Body = append(clause.Body, make_ret(ast.NewIdent("false")))
+			}
+		} else if numbers[state]==len(numbers) && len(clause.Body) > 0 {
+			/// the return ending the function is the one after the switch.
+			if ret, is_ret := clause.Body[len(clause.Body)-1].(*ast.ReturnStmt); is_ret && len(ret.Results)==1 {
+				if iden, is_ident := ret.Results[0].(*ast.Ident); is_ident && iden.Name=="false" {
+					clause.Body = clause.Body[:len(clause.Body)-1]
+				}
+			}
+		}
+		switch_stmt.Body.List = append(switch_stmt.Body.List, clause)
+	}
+	if jumps {
+		dispatch := new(ast.ForStmt)
+		dispatch.Body = make_block(switch_stmt)
+		run.Body.List = append(run.Body.List, dispatch)
+	} else {
+		run.Body.List = append(run.Body.List, switch_stmt, make_ret(ast.NewIdent("false")))
+	}
+	
+	/// func Intro__resume(async_task Task) { if it wasn't cancelled, run the next part and free the slot when done. }
+	resume := make_func(name + "__resume", MakeFieldList("async_task", ast.NewIdent("Task")), nil)
+	cancelled := new(ast.IfStmt)
+	cancelled.Cond = MakeBinaryExpr(state_field("async_task", pos), token.NEQ, ast.NewIdent("async_task"))
+	cancelled.Body = new(ast.BlockStmt)
+	cancelled.Body.List = append(cancelled.Body.List, make_ret())
+	done := new(ast.IfStmt)
+	not_suspended := new(ast.UnaryExpr)
+	not_suspended.Op = token.NOT
+	not_suspended.X = MakeCall(name + "__run", ast.NewIdent("async_task"), ast.NewIdent("async_slot"))
+	done.Cond = not_suspended
+	done.Body = new(ast.BlockStmt)
+	done.Body.List = append(done.Body.List, free_slot())
+	resume.Body.List = append(resume.Body.List, get_slot(), cancelled, done)
+	
+	/// the tolerated 'any' conversions are reported at the async function.
+	data := func() *ast.Ident {
+		iden := ast.NewIdent("data")
+		iden.NamePos = pos
+		return iden
+	}
+	
+	/// func Intro__wake(timer Timer, data any) Action { Intro__resume(Task(data)); return Plugin_Stop }
+	wake_params := MakeFieldList("timer", ast.NewIdent("Timer"))
+	wake_params.List = append(wake_params.List, MakeFieldList("data", ast.NewIdent("any")).List...)
+	wake := make_func(name + "__wake", wake_params, MakeFieldList("", ast.NewIdent("Action")))
+	wake.Body.List = append(wake.Body.List, MakeExprStmt(MakeCall(name + "__resume", MakeCall("Task", data()))), make_ret(ast.NewIdent("Plugin_Stop")))
+	
+	/// func Intro__tick(data any) { counts down 'async_wait' frames, then resumes. }
+	tick := make_func(name + "__tick", MakeFieldList("data", ast.NewIdent("any")), nil)
+	waiting := new(ast.IfStmt)
+	waiting.Cond = MakeBinaryExpr(MakeBinaryExpr(state_field("async_task", pos), token.EQL, ast.NewIdent("async_task")), token.LAND, MakeBinaryExpr(state_field("async_wait", pos), token.GTR, MakeBasicLit(token.INT, "1")))
+	waiting.Body = new(ast.BlockStmt)
+	wait_dec := new(ast.IncDecStmt)
+	wait_dec.X = state_field("async_wait", pos)
+	wait_dec.Tok = token.DEC
+	waiting.Body.List = append(waiting.Body.List, wait_dec, MakeExprStmt(MakeCall("RequestFrame", ast.NewIdent(name + "__tick"), ast.NewIdent("data"))), make_ret())
+	tick.Body.List = append(tick.Body.List, make_local("async_task", "Task", MakeCall("Task", data())), get_slot(), waiting, MakeExprStmt(MakeCall(name + "__resume", ast.NewIdent("async_task"))))
+	return slots
+}
+
+/// a resumed part of an async function, its live locals are read and written through the task's state.
+func MakeAsyncPart(stmts []ast.Stmt, fields map[*types.Var]string, state_field func(string, token.Pos) ast.Expr) []ast.Stmt {
+	is_live := func(iden *ast.Ident) bool {
+		v, _ := ASTCtxt.TypeInfo.Defs[iden].(*types.Var)
+		return fields[v] != ""
+	}
+	declare := func(iden *ast.Ident) ast.Stmt {
+		decl := MakeVarDecl([]*ast.Ident{ast.NewIdent(iden.Name)}, nil, nil)
+		decl.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Type = MakeTypeArgExpr(ASTCtxt.TypeInfo.Defs[iden].Type())
+		return decl
+	}
+	part := make([]ast.Stmt, 0)
+	for _, stmt := range stmts {
+		switch n := stmt.(type) {
+			case *ast.AssignStmt:
+				/// 'hp := GetClientHealth(client)' assigns into the state, new locals next to it are declared first.
+				if n.Tok==token.DEFINE {
+					has_live := false
+					for _, lhs := range n.Lhs {
+						if iden, is_ident := lhs.(*ast.Ident); is_ident && is_live(iden) {
+							has_live = true
+						}
+					}
+					if has_live {
+						for _, lhs := range n.Lhs {
+							if iden, is_ident := lhs.(*ast.Ident); is_ident && !is_live(iden) && ASTCtxt.TypeInfo.Defs[iden] != nil {
+								part = append(part, declare(iden))
+							}
+						}
+						n.Tok = token.ASSIGN
+					}
+				}
+				part = append(part, n)
+			case *ast.DeclStmt:
+				gen_decl, is_gen := n.Decl.(*ast.GenDecl)
+				if !is_gen || gen_decl.Tok != token.VAR {
+					part = append(part, n)
+					continue
+				}
+				for _, spec := range gen_decl.Specs {
+					val_spec := spec.(*ast.ValueSpec)
+					has_live := false
+					for _, name := range val_spec.Names {
+						has_live = has_live || is_live(name)
+					}
+					if !has_live {
+						decl := MakeVarDecl(nil, nil, nil)
+						decl.Decl.(*ast.GenDecl).Specs[0] = val_spec
+						part = append(part, decl)
+						continue
+					}
+					assign := MakeAssign(false)
+					for _, name := range val_spec.Names {
+						if !is_live(name) {
+							part = append(part, declare(name))
+						} else if len(val_spec.Values)==0 {
+							/// the state is reused by later tasks, so it needs zeroing.
+							if zero := MakeZeroValue(ASTCtxt.TypeInfo.Defs[name].Type()); zero != nil {
+								part = append(part, MakeAssignTok(name, token.ASSIGN, zero))
+							} else {
+								zero := ast.NewIdent(fmt.Sprintf("async_zero%d", ASTCtxt.TmpVar))
+								ASTCtxt.TmpVar++
+								ASTCtxt.TypeInfo.Defs[zero] = types.NewVar(token.NoPos, nil, zero.Name, ASTCtxt.TypeInfo.Defs[name].Type())
+								part = append(part, declare(zero), MakeAssignTok(name, token.ASSIGN, ast.NewIdent(zero.Name)))
+							}
+						}
+						assign.Lhs = append(assign.Lhs, name)
+					}
+					if len(val_spec.Values) > 0 {
+						assign.Rhs = val_spec.Values
+						part = append(part, assign)
+					}
+				}
+			default:
+				part = append(part, n)
+		}
+	}
+	
+	for i := range part {
+		ReplaceExprs(reflect.ValueOf(&part[i]).Elem(), func(e ast.Expr) ast.Expr {
+			if iden, is_ident := e.(*ast.Ident); is_ident {
+				obj := ASTCtxt.TypeInfo.Uses[iden]
+				if obj==nil {
+					obj = ASTCtxt.TypeInfo.Defs[iden]
+				}
+				if v, is_var := obj.(*types.Var); is_var && fields[v] != "" {
+					return state_field(fields[v], iden.Pos())
+				}
+			}
+			return e
+		})
+	}
+	return part
+}
+
+/// 'errors.New' or 'fmt.Errorf', by name.
+func GetErrorCtor(expr ast.Expr) (*ast.CallExpr, string) {
+	if call, is_call := expr.(*ast.CallExpr); is_call {
+		if sel, is_sel := call.Fun.(*ast.SelectorExpr); is_sel {
+			if obj := ASTCtxt.TypeInfo.Uses[sel.Sel]; obj != nil && obj.Pkg() != nil {
+				switch path := obj.Pkg().Path(); {
+					case path=="errors" && obj.Name()=="New", path=="fmt" && obj.Name()=="Errorf":
+						return call, obj.Name()
+				}
+			}
+		}
+	}
+	return nil, ""
+}
+
+func IsErrorType(typ types.Type) bool {
+	return typ != nil && types.Identical(typ, types.Universe.Lookup("error").Type())
+}
+
+/// a local error var, which are lowered to a string buffer and an '<name>_ok' flag.
+func IsErrorVar(expr ast.Expr) bool {
+	if iden, is_ident := expr.(*ast.Ident); is_ident {
+		obj, is_var := ASTCtxt.TypeInfo.ObjectOf(iden).(*types.Var)
+		return is_var && IsErrorType(obj.Type()) && ASTCtxt.ErrVars[obj]
+	}
+	return false
+}
+
+/// a call to a function whose last result is an error.
+func GetErrorFuncCall(expr ast.Expr) (*ast.CallExpr, *types.Signature) {
+	if call, is_call := expr.(*ast.CallExpr); is_call {
+		var obj types.Object
+		switch fn := call.Fun.(type) {
+			case *ast.Ident:
+				obj = ASTCtxt.TypeInfo.Uses[fn]
+			case *ast.SelectorExpr:
+				obj = ASTCtxt.TypeInfo.Uses[fn.Sel]
+		}
+		if fn, is_func := obj.(*types.Func); is_func && ASTCtxt.ErrFuncs[fn] {
+			return call, fn.Type().(*types.Signature)
+		}
+	}
+	return nil, nil
+}
+
+/// format string and args for 'panic(x)' or an error value 'x'.
+func MakeErrorFormat(x ast.Expr) []ast.Expr {
+	if call, ctor := GetErrorCtor(x); ctor=="New" {
+		return MakeErrorFormat(call.Args[0])
+	} else if call, is_call := x.(*ast.CallExpr); ctor=="Errorf" || (is_call && GetFmtFuncName(call)=="Sprintf") {
+		if tv, found := ASTCtxt.TypeInfo.Types[call.Args[0]]; found && tv.Value != nil && tv.Value.Kind()==constant.String && !call.Ellipsis.IsValid() {
+			format := MakeSMFormat(constant.StringVal(tv.Value), call.Args[1:], call.Pos())
+			return append([]ast.Expr{ MakeBasicLit(token.STRING, strconv.Quote(format)) }, call.Args[1:]...)
+		}
+		return call.Args
+	}
+	
+	tv := ASTCtxt.TypeInfo.Types[x]
+	if tv.Value != nil && tv.Value.Kind()==constant.String && !strings.Contains(constant.StringVal(tv.Value), "%") {
+		return []ast.Expr{x}
+	} else if IsErrorVar(x) {
+		return []ast.Expr{ MakeBasicLit(token.STRING, `"%s"`), x }
+	} else if spec := GetSMFmtSpec(tv.Type); spec != 0 {
+		return []ast.Expr{ MakeBasicLit(token.STRING, strconv.Quote("%" + string(spec))), x }
+	}
+	PrintSrcGoErr(x.Pos(), fmt.Sprintf("can't make an error message from type '%v'.", tv.Type))
+	return []ast.Expr{x}
+}
+
+/// assigns an error value into the error var named 'name'.
+func MakeErrorAssign(name string, value ast.Expr) []ast.Stmt {
+	flag := ast.NewIdent(name + "_ok")
+	if IsNilIdent(value) {
+		return []ast.Stmt{ MakeAssignTok(flag, token.ASSIGN, ast.NewIdent("true")) }
+	} else if call, ctor := GetErrorCtor(value); ctor=="New" {
+		return []ast.Stmt{ MakeAssignTok(ast.NewIdent(name), token.ASSIGN, call.Args[0]), MakeAssignTok(flag, token.ASSIGN, ast.NewIdent("false")) }
+	} else if ctor=="Errorf" {
+		sprintf := MakeCall("", call.Args...)
+		sprintf.Fun = MakeSelector(ast.NewIdent("fmt"), "Sprintf")
+		sprintf.Ellipsis = call.Ellipsis
+		return []ast.Stmt{ MakeAssignTok(ast.NewIdent(name), token.ASSIGN, sprintf), MakeAssignTok(flag, token.ASSIGN, ast.NewIdent("false")) }
+	} else if IsErrorVar(value) {
+		other := value.(*ast.Ident)
+		return []ast.Stmt{ MakeAssignTok(ast.NewIdent(name), token.ASSIGN, ast.NewIdent(other.Name)), MakeAssignTok(flag, token.ASSIGN, ast.NewIdent(other.Name + "_ok")) }
+	} else if call, sig := GetErrorFuncCall(value); call != nil && sig.Results().Len()==1 {
+		call.Args = append(call.Args, MakeReference(ast.NewIdent(name)), MakeCall("sizeof", ast.NewIdent(name)))
+		return []ast.Stmt{ MakeAssignTok(flag, token.ASSIGN, call) }
+	}
+	PrintSrcGoErr(value.Pos(), "error vars can only be set to nil, another error var, 'errors.New', 'fmt.Errorf' or an error result.")
+	return nil
+}
+
+/// var name string; var name_ok bool
+func MakeErrorVarDecls(name string, ok bool) []ast.Stmt {
+	buf := MakeVarDecl([]*ast.Ident{ast.NewIdent(name)}, nil, nil)
+	buf.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Type = ast.NewIdent("string")
+	flag := MakeVarDecl([]*ast.Ident{ast.NewIdent(name + "_ok")}, nil, nil)
+	flag.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Type = ast.NewIdent("bool")
+	if ok {
+		flag.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values = []ast.Expr{ast.NewIdent("true")}
+	}
+	return []ast.Stmt{buf, flag}
+}
+
+/** Errors are lowered to what SourceMod natives do, a bool result with a message buffer:
+ * 
+ * func Parse(s string) (int, error)      => bool Parse(const char[] s, char[] Parse_err, int Parse_errlen, int& Parse_param1)
+ * return 0, errors.New("bad")            => strcopy(Parse_err, Parse_errlen, "bad"); return false;
+ * n, err := Parse(s)                     => char err[256]; bool err_ok; err_ok = Parse(s, err, sizeof(err), n);
+ * if err != nil { panic(err) }           => if (!err_ok) { ThrowError("%s", err); }
+ * 
+ * 'panic' is 'ThrowNativeError' in natives, 'SetFailState' in 'main'/'OnPluginStart' and 'ThrowError' anywhere else.
+ * returns whether anything changed and needs type-checking again.
+ */
+func MutateErrors(file *ast.File) bool {
+	ASTCtxt.ErrFuncs = make(map[*types.Func]bool)
+	ASTCtxt.ErrVars = make(map[*types.Var]bool)
+	changed := false
+	for _, decl := range file.Decls {
+		f, is_func := decl.(*ast.FuncDecl)
+		if !is_func || f.Type.Results==nil || len(f.Type.Results.List)==0 {
+			continue
+		}
+		last := f.Type.Results.List[len(f.Type.Results.List)-1]
+		if !IsErrorType(ASTCtxt.TypeInfo.TypeOf(last.Type)) {
+			continue
+		} else if len(last.Names) > 0 {
+			PrintSrcGoErr(last.Pos(), fmt.Sprintf("'%s' can't have a named error result.", f.Name.Name))
+			continue
+		}
+		if fn, is_func := ASTCtxt.TypeInfo.Defs[f.Name].(*types.Func); is_func {
+			ASTCtxt.ErrFuncs[fn] = true
+		}
+	}
+	
+	/// error vars are locals declared in function bodies.
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch x := n.(type) {
+			case *ast.FuncDecl:
+				if x.Body != nil {
+					ast.Inspect(x.Body, func(n ast.Node) bool {
+						if iden, is_ident := n.(*ast.Ident); is_ident {
+							if v, is_var := ASTCtxt.TypeInfo.Defs[iden].(*types.Var); is_var && IsErrorType(v.Type()) {
+								ASTCtxt.ErrVars[v] = true
+							}
+						}
+						return true
+					})
+				}
+				return false
+		}
+		return true
+	})
+	
+	panic_obj := types.Universe.Lookup("panic")
+	for _, decl := range file.Decls {
+		f, is_func := decl.(*ast.FuncDecl)
+		if !is_func || f.Body==nil {
+			continue
+		}
+		uses_errors := false
+		ast.Inspect(f.Body, func(n ast.Node) bool {
+			if iden, is_ident := n.(*ast.Ident); is_ident {
+				obj := ASTCtxt.TypeInfo.ObjectOf(iden)
+				if v, is_var := obj.(*types.Var); (is_var && ASTCtxt.ErrVars[v]) || obj==panic_obj {
+					uses_errors = true
+				} else if fn, is_fn := obj.(*types.Func); is_fn && ASTCtxt.ErrFuncs[fn] {
+					uses_errors = true
+				}
+			}
+			return true
+		})
+		fn, _ := ASTCtxt.TypeInfo.Defs[f.Name].(*types.Func)
+		if !uses_errors && !ASTCtxt.ErrFuncs[fn] {
+			continue
+		}
+		changed = true
+		ASTCtxt.CurrFunc = f
+		MutateBlock(f.Body, MutateErrorStmts)
+		
+		/// err != nil => !err_ok, err.Error() => err
+		ReplaceExprs(reflect.ValueOf(f.Body).Elem(), func(e ast.Expr) ast.Expr {
+			switch x := e.(type) {
+				case *ast.BinaryExpr:
+					if x.Op==token.EQL || x.Op==token.NEQ {
+						err := x.X
+						if IsNilIdent(err) {
+							err = x.Y
+						}
+						if IsErrorVar(err) && (IsNilIdent(x.X) || IsNilIdent(x.Y)) {
+							flag := ast.NewIdent(err.(*ast.Ident).Name + "_ok")
+							if x.Op==token.EQL {
+								return flag
+							}
+							failed := new(ast.UnaryExpr)
+							failed.Op = token.NOT
+							failed.X = flag
+							return failed
+						}
+					}
+				case *ast.CallExpr:
+					if sel, is_sel := x.Fun.(*ast.SelectorExpr); is_sel && sel.Sel.Name=="Error" && len(x.Args)==0 && IsErrorVar(sel.X) {
+						return ast.NewIdent(sel.X.(*ast.Ident).Name)
+					}
+			}
+			return e
+		})
+		ASTCtxt.CurrFunc = nil
+	}
+	
+	/// func Parse(s string) (int, error) => func Parse(s string, Parse_err *string, Parse_errlen int) (bool, int)
+	for _, decl := range file.Decls {
+		f, is_func := decl.(*ast.FuncDecl)
+		if !is_func {
+			continue
+		} else if fn, _ := ASTCtxt.TypeInfo.Defs[f.Name].(*types.Func); !ASTCtxt.ErrFuncs[fn] {
+			continue
+		}
+		changed = true
+		results := f.Type.Results.List[:len(f.Type.Results.List)-1]
+		ok_result := new(ast.Field)
+		ok_result.Type = ast.NewIdent("bool")
+		f.Type.Results.List = append([]*ast.Field{ok_result}, results...)
+		
+		err_param := new(ast.Field)
+		err_param.Names = append(err_param.Names, ast.NewIdent(f.Name.Name + "_err"))
+		err_param.Type = PtrizeExpr(ast.NewIdent("string"))
+		errlen_param := new(ast.Field)
+		errlen_param.Names = append(errlen_param.Names, ast.NewIdent(f.Name.Name + "_errlen"))
+		errlen_param.Type = ast.NewIdent("int")
+		f.Type.Params.List = append(f.Type.Params.List, err_param, errlen_param)
+	}
+	
+	/// whatever's left can't be lowered.
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch x := n.(type) {
+			case *ast.CallExpr:
+				if _, ctor := GetErrorCtor(x); ctor != "" {
+					PrintSrcGoErr(x.Pos(), fmt.Sprintf("'%s' can only be returned or assigned to an error var.", GetFuncName(x.Fun)))
+				} else if call, sig := GetErrorFuncCall(x); call != nil && len(call.Args) != sig.Params().Len() + 2 {
+					PrintSrcGoErr(x.Pos(), fmt.Sprintf("the error from '%s' has to be assigned to a var or returned.", GetFuncName(x.Fun)))
+				}
+			case *ast.Field:
+				if IsErrorType(ASTCtxt.TypeInfo.TypeOf(x.Type)) {
+					PrintSrcGoErr(x.Pos(), "errors can only be a function's last result or a local var.")
+				}
+			case *ast.ValueSpec:
+				if x.Type != nil && IsErrorType(ASTCtxt.TypeInfo.TypeOf(x.Type)) {
+					PrintSrcGoErr(x.Pos(), "errors can only be a function's last result or a local var.")
+				}
+		}
+		return true
+	})
+	ASTCtxt.ErrFuncs = nil
+	ASTCtxt.ErrVars = nil
+	return changed
+}
+
+func MutateErrorStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateErrorStmts)
+		
+		case *ast.ForStmt:
+			bm(n.Body, MutateErrorStmts)
+		
+		case *ast.IfStmt:
+			/// 'if v, err := f(); err != nil' keeps its error var in a block around the if.
+			if n.Init != nil {
+				init_list := []ast.Stmt{n.Init}
+				MutateErrorStmts(&init_list, 0, n.Init, bm)
+				n.Init = init_list[len(init_list)-1]
+				if len(init_list) > 1 {
+					scope := new(ast.BlockStmt)
+					scope.List = append(init_list[:len(init_list)-1], n)
+					(*owner_list)[FindStmt(*owner_list, s)] = scope
+				}
+			}
+			bm(n.Body, MutateErrorStmts)
+			if n.Else != nil {
+				MutateErrorStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			bm(n.Body, MutateErrorStmts)
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateErrorStmts(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateErrorStmts)
+		
+		case *ast.ExprStmt:
+			call, is_call := n.X.(*ast.CallExpr)
+			if !is_call {
+				return
+			}
+			if iden, is_ident := call.Fun.(*ast.Ident); is_ident && ASTCtxt.TypeInfo.Uses[iden]==types.Universe.Lookup("panic") {
+				MutatePanic(owner_list, n, call)
+			} else if err_call, _ := GetErrorFuncCall(call); err_call != nil {
+				/// f() => var go_err# string; f(&go_err#, sizeof(go_err#))
+				scratch := ast.NewIdent(fmt.Sprintf("go_err%d", ASTCtxt.TmpVar))
+				ASTCtxt.TmpVar++
+				*owner_list = InsertStmt(*owner_list, FindStmt(*owner_list, s), MakeErrorVarDecls(scratch.Name, false)[0])
+				call.Args = append(call.Args, MakeReference(scratch), MakeCall("sizeof", ast.NewIdent(scratch.Name)))
+			}
+		
+		case *ast.DeclStmt:
+			/// var err error = value => var err string; var err_ok bool = true; ...
+			gen_decl, is_gen := n.Decl.(*ast.GenDecl)
+			if !is_gen || gen_decl.Tok != token.VAR || len(gen_decl.Specs) != 1 {
+				return
+			}
+			val_spec := gen_decl.Specs[0].(*ast.ValueSpec)
+			if len(val_spec.Names) != 1 || !IsErrorVar(val_spec.Names[0]) {
+				return
+			}
+			name := val_spec.Names[0].Name
+			stmts := MakeErrorVarDecls(name, true)
+			if len(val_spec.Values)==1 {
+				stmts = append(stmts, MakeErrorAssign(name, val_spec.Values[0])...)
+			}
+			MutateErrorList(owner_list, s, stmts)
+		
+		case *ast.AssignStmt:
+			if len(n.Rhs) != 1 {
+				return
+			}
+			err, is_ident := n.Lhs[len(n.Lhs)-1].(*ast.Ident)
+			if !is_ident {
+				return
+			}
+			call, sig := GetErrorFuncCall(n.Rhs[0])
+			if call != nil && sig.Results().Len()==len(n.Lhs) && len(n.Lhs) > 1 {
+				/// v, err := f() => var err string; var err_ok bool; err_ok, v = f(&err, sizeof(err))
+				stmts := make([]ast.Stmt, 0)
+				buf, flag := err.Name, err.Name + "_ok"
+				if err.Name=="_" {
+					buf, flag = fmt.Sprintf("go_err%d", ASTCtxt.TmpVar), "_"
+					ASTCtxt.TmpVar++
+					stmts = append(stmts, MakeErrorVarDecls(buf, false)[0])
+				}
+				has_new := false
+				for _, lhs := range n.Lhs[:len(n.Lhs)-1] {
+					if iden, is_ident := lhs.(*ast.Ident); is_ident && ASTCtxt.TypeInfo.Defs[iden] != nil {
+						has_new = true
+					}
+				}
+				/// a new 'err_ok' is declared by the ':=' itself unless nothing else is new.
+				if err.Name != "_" && ASTCtxt.TypeInfo.Defs[err] != nil {
+					decls := MakeErrorVarDecls(buf, false)
+					if has_new {
+						decls = decls[:1]
+					}
+					stmts = append(stmts, decls...)
+				}
+				if !has_new {
+					n.Tok = token.ASSIGN
+				}
+				n.Lhs = append([]ast.Expr{ast.NewIdent(flag)}, n.Lhs[:len(n.Lhs)-1]...)
+				call.Args = append(call.Args, MakeReference(ast.NewIdent(buf)), MakeCall("sizeof", ast.NewIdent(buf)))
+				MutateErrorList(owner_list, s, append(stmts, n))
+			} else if len(n.Lhs)==1 && IsErrorVar(err) {
+				/// err = value
+				stmts := make([]ast.Stmt, 0)
+				if n.Tok==token.DEFINE {
+					stmts = append(stmts, MakeErrorVarDecls(err.Name, false)...)
+				}
+				MutateErrorList(owner_list, s, append(stmts, MakeErrorAssign(err.Name, n.Rhs[0])...))
+			}
+		
+		case *ast.ReturnStmt:
+			fn, _ := ASTCtxt.TypeInfo.Defs[ASTCtxt.CurrFunc.Name].(*types.Func)
+			if !ASTCtxt.ErrFuncs[fn] || len(n.Results)==0 {
+				return
+			}
+			err_buf, err_len := PtrizeExpr(ast.NewIdent(ASTCtxt.CurrFunc.Name.Name + "_err")), ast.NewIdent(ASTCtxt.CurrFunc.Name.Name + "_errlen")
+			if call, sig := GetErrorFuncCall(n.Results[0]); call != nil && len(n.Results)==1 && sig.Results().Len() > 1 {
+				/// return g() forwards our error buffer.
+				call.Args = append(call.Args, ast.NewIdent(err_buf.X.(*ast.Ident).Name), err_len)
+				return
+			}
+			values := n.Results[:len(n.Results)-1]
+			err := n.Results[len(n.Results)-1]
+			stmts := make([]ast.Stmt, 0)
+			var ok ast.Expr
+			if IsNilIdent(err) {
+				ok = ast.NewIdent("true")
+			} else if call, ctor := GetErrorCtor(err); ctor=="New" {
+				stmts = append(stmts, MakeExprStmt(MakeCall("strcopy", err_buf, err_len, call.Args[0])))
+				ok = ast.NewIdent("false")
+			} else if ctor=="Errorf" {
+				stmts = append(stmts, MakeExprStmt(MakeCall("Format", append([]ast.Expr{err_buf, err_len}, MakeErrorFormat(call)...)...)))
+				ok = ast.NewIdent("false")
+			} else if IsErrorVar(err) {
+				stmts = append(stmts, MakeExprStmt(MakeCall("strcopy", err_buf, err_len, err)))
+				ok = ast.NewIdent(err.(*ast.Ident).Name + "_ok")
+			} else if call, sig := GetErrorFuncCall(err); call != nil && sig.Results().Len()==1 {
+				call.Args = append(call.Args, ast.NewIdent(err_buf.X.(*ast.Ident).Name), err_len)
+				ok = call
+			} else {
+				PrintSrcGoErr(err.Pos(), "only nil, an error var, 'errors.New', 'fmt.Errorf' or an error result can be returned as an error.")
+				return
+			}
+			n.Results = append([]ast.Expr{ok}, values...)
+			MutateErrorList(owner_list, s, append(stmts, n))
+	}
+}
+
+/// replaces 's' with 'stmts'.
+func MutateErrorList(owner_list *[]ast.Stmt, s ast.Stmt, stmts []ast.Stmt) {
+	index := FindStmt(*owner_list, s)
+	if index == -1 || len(stmts)==0 {
+		return
+	}
+	(*owner_list)[index] = stmts[len(stmts)-1]
+	for i := len(stmts)-2; i >= 0; i-- {
+		*owner_list = InsertStmt(*owner_list, index, stmts[i])
+	}
+}
+
+/// panic(x) => return ThrowNativeError(SP_ERROR_NATIVE, ...) | SetFailState(...) | ThrowError(...)
+func MutatePanic(owner_list *[]ast.Stmt, s *ast.ExprStmt, call *ast.CallExpr) {
+	f := ASTCtxt.CurrFunc
+	format := MakeErrorFormat(call.Args[0])
+	fn_type := ASTCtxt.TypeInfo.Defs[f.Name].Type()
+	if IsNativeImpl(f) {
+		ret := new(ast.ReturnStmt)
+		ret.Results = append(ret.Results, MakeCall("ThrowNativeError", append([]ast.Expr{ast.NewIdent("SP_ERROR_NATIVE")}, format...)...))
+		MutateErrorList(owner_list, s, []ast.Stmt{ret})
+		return
+	}
+	
+	if f.Recv==nil && (f.Name.Name=="main" || f.Name.Name=="OnPluginStart") {
+		s.X = MakeCall("SetFailState", format...)
+	} else {
+		s.X = MakeCall("ThrowError", format...)
+	}
+	/// 'panic' ended the function, SourcePawn still needs a return after it.
+	sig := fn_type.(*types.Signature)
+	if sig.Results().Len() > 0 && owner_list==&f.Body.List && FindStmt(f.Body.List, s)==len(f.Body.List)-1 {
+		ret := new(ast.ReturnStmt)
+		for i := 0; i < sig.Results().Len(); i++ {
+			zero := MakeZeroValue(sig.Results().At(i).Type())
+			if zero==nil {
+				tmp := ast.NewIdent(fmt.Sprintf("go_zero%d", ASTCtxt.TmpVar))
+				ASTCtxt.TmpVar++
+				decl := MakeVarDecl([]*ast.Ident{tmp}, nil, nil)
+				decl.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Type = MakeTypeArgExpr(sig.Results().At(i).Type())
+				f.Body.List = append(f.Body.List, decl)
+				zero = ast.NewIdent(tmp.Name)
+			}
+			ret.Results = append(ret.Results, zero)
+		}
+		f.Body.List = append(f.Body.List, ret)
+	}
+}
+
+
+/// var name T = value
+func MakeLocalVar(name string, typ types.Type, value ast.Expr) *ast.DeclStmt {
+	decl := MakeVarDecl([]*ast.Ident{ast.NewIdent(name)}, nil, nil)
+	val_spec := decl.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	val_spec.Type = MakeTypeArgExpr(typ)
+	if value != nil {
+		val_spec.Values = append(val_spec.Values, value)
+	}
+	return decl
+}
+
+/// expressions that can be copied into each place they're used without changing what they do.
+func IsPureExpr(expr ast.Expr) bool {
+	switch x := expr.(type) {
+		case *ast.Ident, *ast.BasicLit:
+			return true
+		case *ast.ParenExpr:
+			return IsPureExpr(x.X)
+		case *ast.SelectorExpr:
+			if sel := ASTCtxt.TypeInfo.Selections[x]; sel != nil && sel.Kind() != types.FieldVal {
+				return false
+			}
+			return IsPureExpr(x.X)
+		case *ast.IndexExpr:
+			return IsPureExpr(x.X) && IsPureExpr(x.Index)
+		case *ast.StarExpr:
+			return IsPureExpr(x.X)
+		case *ast.UnaryExpr:
+			return x.Op != token.ARROW && IsPureExpr(x.X)
+		case *ast.BinaryExpr:
+			return IsPureExpr(x.X) && IsPureExpr(x.Y)
+		case *ast.CallExpr:
+			return IsTypeConversion(x) && IsPureExpr(x.Args[0])
+	}
+	return false
+}
+
+func HasReturn(n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n.(type) {
+			case *ast.ReturnStmt:
+				found = true
+			case *ast.FuncLit:
+				return false
+		}
+		return !found
+	})
+	return found
+}
+
+/// why a function's calls can't be replaced by its body, "" if they can.
+func CanInline(f *ast.FuncDecl) string {
+	fn, _ := ASTCtxt.TypeInfo.Defs[f.Name].(*types.Func)
+	if f.Body==nil || fn==nil {
+		return "it has no body"
+	} else if f.Type.TypeParams != nil {
+		return "it's generic"
+	} else if fn.Type().(*types.Signature).Variadic() {
+		return "it's variadic"
+	} else if _, found := GetDirective(f.Doc, "async"); found {
+		return "it's async"
+	}
+	reason := ""
+	ast.Inspect(f.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+			case *ast.DeferStmt, *ast.GoStmt, *ast.LabeledStmt, *ast.FuncLit, *ast.SelectStmt:
+				reason = "it has defers, go statements, labels, closures or selects"
+			case *ast.BranchStmt:
+				if x.Tok==token.GOTO {
+					reason = "it has gotos"
+				}
+			case *ast.Ident:
+				if ASTCtxt.TypeInfo.Uses[x]==fn {
+					reason = "it's recursive"
+				}
+		}
+		return reason==""
+	})
+	if _, ok := MakeInlineFlow(f.Body.List, nil); reason=="" && !ok {
+		reason = "it returns from inside a loop or switch"
+	}
+	return reason
+}
+
+/// one statement returning an expression without calls, like 'return x * x'.
+func IsTinyLeafFunc(f *ast.FuncDecl) bool {
+	if f.Body==nil || len(f.Body.List) != 1 {
+		return false
+	}
+	ret, is_ret := f.Body.List[0].(*ast.ReturnStmt)
+	if !is_ret || len(ret.Results) != 1 {
+		return false
+	}
+	nodes, leaf := 0, true
+	ast.Inspect(ret, func(n ast.Node) bool {
+		if call, is_call := n.(*ast.CallExpr); is_call && !IsTypeConversion(call) {
+			leaf = false
+		}
+		if n != nil {
+			nodes++
+		}
+		return leaf
+	})
+	return leaf && nodes <= 16
+}
+
+/// the inlinable function a call goes to.
+func GetInlineCall(expr ast.Expr) (*ast.CallExpr, *ast.FuncDecl) {
+	call, is_call := expr.(*ast.CallExpr)
+	if !is_call || call.Ellipsis.IsValid() {
+		return nil, nil
+	}
+	var obj types.Object
+	switch fn := call.Fun.(type) {
+		case *ast.Ident:
+			obj = ASTCtxt.TypeInfo.Uses[fn]
+		case *ast.SelectorExpr:
+			if sel := ASTCtxt.TypeInfo.Selections[fn]; sel != nil && sel.Kind() != types.MethodVal {
+				return nil, nil
+			}
+			obj = ASTCtxt.TypeInfo.Uses[fn.Sel]
+	}
+	if fn, is_func := obj.(*types.Func); is_func && ASTCtxt.Inlines[fn] != nil {
+		f := ASTCtxt.Inlines[fn]
+		if len(call.Args) != fn.Type().(*types.Signature).Params().Len() || f==ASTCtxt.CurrFunc {
+			return nil, nil
+		}
+		return call, f
+	}
+	return nil, nil
+}
+
+/// renames the locals of an inlined body so they can't clash with the caller's: x => x_inl3
+func MakeInlineNames(f *ast.FuncDecl, site uint) map[types.Object]string {
+	names := make(map[types.Object]string)
+	taken := make(map[string]bool)
+	add := func(obj types.Object) {
+		if _, is_var := obj.(*types.Var); !is_var || names[obj] != "" || obj.Name()=="_" {
+			return
+		}
+		name := fmt.Sprintf("%s_inl%d", obj.Name(), site)
+		for i := 1; taken[name]; i++ {
+			name = fmt.Sprintf("%s_inl%d_%d", obj.Name(), site, i)
+		}
+		taken[name] = true
+		names[obj] = name
+	}
+	if f.Type.Results != nil {
+		for _, field := range f.Type.Results.List {
+			for _, name := range field.Names {
+				add(ASTCtxt.TypeInfo.Defs[name])
+			}
+		}
+	}
+	ast.Inspect(f.Body, func(n ast.Node) bool {
+		if iden, is_ident := n.(*ast.Ident); is_ident && ASTCtxt.TypeInfo.Defs[iden] != nil {
+			add(ASTCtxt.TypeInfo.Defs[iden])
+		}
+		return true
+	})
+	return names
+}
+
+/** params are replaced by their args where that's the same as passing them:
+ * arrays, strings and pointers are references in SourcePawn, other args are replaced
+ * when they're constants or vars, fields and elements the body never changes, the rest are copied into locals.
+ */
+func BindInlineArgs(call *ast.CallExpr, f *ast.FuncDecl, names map[types.Object]string, site uint, allow_copies bool) (map[types.Object]ast.Expr, []ast.Stmt, bool) {
+	sig := ASTCtxt.TypeInfo.Defs[f.Name].Type().(*types.Signature)
+	assigned, mutated := make(map[types.Object]bool), make(map[types.Object]bool)
+	mark := func(lhs ast.Expr) {
+		if root := GetRootIdent(lhs); root != nil {
+			obj := ASTCtxt.TypeInfo.ObjectOf(root)
+			mutated[obj] = true
+			if _, is_ident := lhs.(*ast.Ident); is_ident {
+				assigned[obj] = true
+			}
+		}
+	}
+	ast.Inspect(f.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+			case *ast.AssignStmt:
+				for _, lhs := range x.Lhs {
+					mark(lhs)
+				}
+			case *ast.IncDecStmt:
+				mark(x.X)
+			case *ast.RangeStmt:
+				if x.Tok==token.ASSIGN {
+					mark(x.Key)
+					if x.Value != nil {
+						mark(x.Value)
+					}
+				}
+			case *ast.UnaryExpr:
+				if x.Op==token.AND {
+					mark(x.X)
+				}
+		}
+		return true
+	})
+	
+	subst := make(map[types.Object]ast.Expr)
+	copies := make([]ast.Stmt, 0)
+	bind := func(param *types.Var, arg ast.Expr) bool {
+		if param.Name()=="" || param.Name()=="_" {
+			return IsPureExpr(arg)
+		}
+		if _, is_binary := arg.(*ast.BinaryExpr); is_binary {
+			arg = MakeParenExpr(arg)
+		}
+		switch typ := param.Type().Underlying().(type) {
+			case *types.Array, *types.Pointer, *types.Slice:
+				subst[param] = arg
+				return IsPureExpr(arg) && !assigned[param]
+			case *types.Basic:
+				if typ.Info() & types.IsString > 0 {
+					subst[param] = arg
+					return IsPureExpr(arg) && !assigned[param]
+				}
+		}
+		is_var := false
+		switch arg.(type) {
+			case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr:
+				is_var = IsPureExpr(arg)
+		}
+		if tv := ASTCtxt.TypeInfo.Types[arg]; !mutated[param] && (is_var || tv.Value != nil) {
+			subst[param] = arg
+			return true
+		} else if !allow_copies {
+			return false
+		}
+		names[param] = fmt.Sprintf("%s_inl%d", param.Name(), site)
+		copies = append(copies, MakeLocalVar(names[param], param.Type(), arg))
+		return true
+	}
+	
+	if sig.Recv() != nil {
+		recv := call.Fun.(*ast.SelectorExpr).X
+		_, recv_ptr := sig.Recv().Type().Underlying().(*types.Pointer)
+		_, arg_ptr := ASTCtxt.TypeInfo.TypeOf(recv).Underlying().(*types.Pointer)
+		if recv_ptr && !arg_ptr {
+			recv = MakeReference(recv)
+		} else if !recv_ptr && arg_ptr {
+			deref := new(ast.StarExpr)
+			deref.X = recv
+			recv = deref
+		}
+		if !IsPureExpr(recv) || !bind(sig.Recv(), recv) {
+			return nil, nil, false
+		}
+	}
+	for i, arg := range call.Args {
+		if !bind(sig.Params().At(i), arg) {
+			return nil, nil, false
+		}
+	}
+	return subst, copies, true
+}
+
+/// copies the body of 'f' with its locals renamed and its params replaced.
+func CloneInlineBody(f *ast.FuncDecl, subst map[types.Object]ast.Expr, names map[types.Object]string) *ast.BlockStmt {
+	/// the body's own idents are renamed while it's copied so the copy keeps the new names.
+	renamed := make(map[*ast.Ident]string)
+	ast.Inspect(f.Body, func(n ast.Node) bool {
+		if iden, is_ident := n.(*ast.Ident); is_ident {
+			if name, found := names[ASTCtxt.TypeInfo.ObjectOf(iden)]; found {
+				renamed[iden] = iden.Name
+				iden.Name = name
+			}
+		}
+		return true
+	})
+	body := CloneAST(f.Body, subst).(*ast.BlockStmt)
+	for iden, name := range renamed {
+		iden.Name = name
+	}
+	
+	/// *(&x) => x, (&x).f => x.f
+	ReplaceExprs(reflect.ValueOf(body).Elem(), func(e ast.Expr) ast.Expr {
+		switch x := e.(type) {
+			case *ast.StarExpr:
+				if ref, is_ref := x.X.(*ast.UnaryExpr); is_ref && ref.Op==token.AND {
+					return ref.X
+				}
+			case *ast.SelectorExpr:
+				if ref, is_ref := x.X.(*ast.UnaryExpr); is_ref && ref.Op==token.AND {
+					x.X = ref.X
+				}
+		}
+		return e
+	})
+	return body
+}
+
+/// the package-level names a body uses, a caller's locals can't hide them.
+func IsInlineShadowed(f *ast.FuncDecl) bool {
+	caller := make(map[string]bool)
+	ast.Inspect(ASTCtxt.CurrFunc, func(n ast.Node) bool {
+		if iden, is_ident := n.(*ast.Ident); is_ident {
+			if obj := ASTCtxt.TypeInfo.Defs[iden]; obj != nil && obj.Parent() != nil && obj.Parent() != obj.Pkg().Scope() {
+				caller[iden.Name] = true
+			}
+		}
+		return true
+	})
+	shadowed := false
+	ast.Inspect(f.Body, func(n ast.Node) bool {
+		if iden, is_ident := n.(*ast.Ident); is_ident && caller[iden.Name] {
+			if obj := ASTCtxt.TypeInfo.Uses[iden]; obj != nil && obj.Pkg() != nil && obj.Parent()==obj.Pkg().Scope() {
+				shadowed = true
+			}
+		}
+		return !shadowed
+	})
+	return shadowed
+}
+
+/** returns become assignments to 'results', code after an 'if' that returns moves into its branches:
+ * 
+ * if a > b {          if a > b {
+ *     return a    =>      go_ret0 = a
+ * }                   } else {
+ * return b                go_ret0 = b
+ *                     }
+ * 
+ * a nil 'results' drops the returned values, keeping calls.
+ */
+func MakeInlineFlow(stmts []ast.Stmt, results []string) ([]ast.Stmt, bool) {
+	flow := make([]ast.Stmt, 0, len(stmts))
+	for i, stmt := range stmts {
+		if !HasReturn(stmt) {
+			flow = append(flow, stmt)
+			continue
+		}
+		rest := stmts[i+1:]
+		switch s := stmt.(type) {
+			case *ast.ReturnStmt:
+				return append(flow, MakeInlineReturn(s, results)...), true
+			
+			case *ast.BlockStmt:
+				inner, ok := MakeInlineFlow(append(append([]ast.Stmt{}, s.List...), rest...), results)
+				return append(flow, inner...), ok
+			
+			case *ast.IfStmt:
+				branch := new(ast.IfStmt)
+				*branch = *s
+				body, body_ok := MakeInlineFlow(append(append([]ast.Stmt{}, s.Body.List...), rest...), results)
+				branch.Body = new(ast.BlockStmt)
+				branch.Body.List = body
+				
+				/// both branches run what's after the if, so the else gets its own copy.
+				rest_copy := make([]ast.Stmt, 0, len(rest))
+				for _, r := range rest {
+					rest_copy = append(rest_copy, CloneAST(r, nil).(ast.Stmt))
+				}
+				var else_list []ast.Stmt
+				switch e := s.Else.(type) {
+					case *ast.BlockStmt:
+						else_list = append(append([]ast.Stmt{}, e.List...), rest_copy...)
+					case *ast.IfStmt:
+						else_list = append([]ast.Stmt{e}, rest_copy...)
+					default:
+						else_list = rest_copy
+				}
+				else_flow, else_ok := MakeInlineFlow(else_list, results)
+				branch.Else = nil
+				if len(else_flow)==1 {
+					if else_if, is_if := else_flow[0].(*ast.IfStmt); is_if {
+						branch.Else = else_if
+					}
+				}
+				if len(else_flow) > 0 && branch.Else==nil {
+					else_block := new(ast.BlockStmt)
+					else_block.List = else_flow
+					branch.Else = else_block
+				}
+				return append(flow, branch), body_ok && else_ok
+		}
+		return nil, false
+	}
+	return flow, true
+}
+
+func MakeInlineReturn(ret *ast.ReturnStmt, results []string) []ast.Stmt {
+	stmts := make([]ast.Stmt, 0)
+	if len(ret.Results)==0 {
+		return stmts
+	} else if results==nil {
+		for _, value := range ret.Results {
+			if call, is_call := value.(*ast.CallExpr); is_call && !IsTypeConversion(call) {
+				stmts = append(stmts, MakeExprStmt(call))
+			}
+		}
+		return stmts
+	}
+	assign := MakeAssign(false)
+	for i, name := range results {
+		if iden, is_ident := ret.Results[0].(*ast.Ident); is_ident && len(ret.Results)==len(results) && ret.Results[i]==iden && iden.Name==name {
+			continue
+		}
+		assign.Lhs = append(assign.Lhs, ast.NewIdent(name))
+	}
+	if len(assign.Lhs)==0 {
+		return stmts
+	}
+	assign.Rhs = ret.Results
+	return append(stmts, assign)
+}
+
+/** '//go2sp:inline' functions, and with '-O' tiny leaf functions, have their calls replaced by their bodies:
+ * 
+ * //go2sp:inline
+ * func Sq(x float) float {
+ *     return x * x
+ * }
+ * d := Sq(dx) + Sq(dy)              => d := (dx * dx) + (dy * dy)
+ * 
+ * longer bodies go before the statement with the call, their results in 'go_ret#' vars.
+ * this runs before 'MergeRetVals', so multiple results never become by-ref params.
+ * returns whether anything was inlined and needs type-checking again.
+ */
+func MutateInlines(file *ast.File, auto bool) bool {
+	ASTCtxt.Inlines = make(map[*types.Func]*ast.FuncDecl)
+	if ASTCtxt.InlineErrs==nil {
+		ASTCtxt.InlineErrs = make(map[*ast.FuncDecl]bool)
+	}
+	for _, decl := range file.Decls {
+		f, is_func := decl.(*ast.FuncDecl)
+		if !is_func {
+			continue
+		}
+		_, forced := GetDirective(f.Doc, "inline")
+		if !forced && (!auto || !IsTinyLeafFunc(f)) {
+			continue
+		}
+		if reason := CanInline(f); reason != "" {
+			/// this runs until nothing's left to inline, only say it once.
+			if forced && !ASTCtxt.InlineErrs[f] {
+				ASTCtxt.InlineErrs[f] = true
+				PrintSrcGoErr(f.Pos(), fmt.Sprintf("'%s' can't be inlined, %s.", f.Name.Name, reason))
+			}
+			continue
+		}
+		ASTCtxt.Inlines[ASTCtxt.TypeInfo.Defs[f.Name].(*types.Func)] = f
+	}
+	
+	/// functions inlining each other would never finish, they stay calls.
+	for fn, f := range ASTCtxt.Inlines {
+		seen := make(map[*types.Func]bool)
+		var reaches func(f *ast.FuncDecl) bool
+		reaches = func(f *ast.FuncDecl) bool {
+			found := false
+			ast.Inspect(f.Body, func(n ast.Node) bool {
+				if iden, is_ident := n.(*ast.Ident); is_ident {
+					if callee, is_func := ASTCtxt.TypeInfo.Uses[iden].(*types.Func); is_func && ASTCtxt.Inlines[callee] != nil {
+						if callee==fn {
+							found = true
+						} else if !seen[callee] {
+							seen[callee] = true
+							found = reaches(ASTCtxt.Inlines[callee])
+						}
+					}
+				}
+				return !found
+			})
+			return found
+		}
+		if reaches(f) {
+			delete(ASTCtxt.Inlines, fn)
+		}
+	}
+	
+	changed := false
+	for _, decl := range file.Decls {
+		f, is_func := decl.(*ast.FuncDecl)
+		if !is_func || f.Body==nil || len(ASTCtxt.Inlines)==0 {
+			continue
+		}
+		ASTCtxt.CurrFunc = f
+		
+		/// calls that are whole statements are inlined as statements.
+		stmt_calls := make(map[ast.Expr]bool)
+		ast.Inspect(f.Body, func(n ast.Node) bool {
+			if expr_stmt, is_expr := n.(*ast.ExprStmt); is_expr {
+				stmt_calls[expr_stmt.X] = true
+			}
+			return true
+		})
+		ReplaceExprs(reflect.ValueOf(f.Body).Elem(), func(e ast.Expr) ast.Expr {
+			call, callee := GetInlineCall(e)
+			if call==nil || stmt_calls[e] || len(callee.Body.List) != 1 {
+				return e
+			}
+			ret, is_ret := callee.Body.List[0].(*ast.ReturnStmt)
+			if !is_ret || len(ret.Results) != 1 || IsInlineShadowed(callee) {
+				return e
+			}
+			names := MakeInlineNames(callee, ASTCtxt.TmpVar)
+			subst, _, ok := BindInlineArgs(call, callee, names, ASTCtxt.TmpVar, false)
+			if !ok {
+				return e
+			}
+			ASTCtxt.TmpVar++
+			changed = true
+			value := CloneInlineBody(callee, subst, names).List[0].(*ast.ReturnStmt).Results[0]
+			if _, is_binary := value.(*ast.BinaryExpr); is_binary {
+				return MakeParenExpr(value)
+			}
+			return value
+		})
+		
+		ASTCtxt.Inlined = false
+		MutateBlock(f.Body, MutateInlineStmts)
+		changed = changed || ASTCtxt.Inlined
+		ASTCtxt.CurrFunc = nil
+	}
+	ASTCtxt.Inlines = nil
+	return changed
+}
+
+func MutateInlineStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	var call *ast.CallExpr
+	var callee *ast.FuncDecl
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateInlineStmts)
+			return
+		case *ast.IfStmt:
+			bm(n.Body, MutateInlineStmts)
+			if n.Else != nil {
+				MutateInlineStmts(owner_list, index, n.Else, bm)
+			}
+			return
+		case *ast.ForStmt:
+			bm(n.Body, MutateInlineStmts)
+			return
+		case *ast.RangeStmt:
+			bm(n.Body, MutateInlineStmts)
+			return
+		case *ast.SwitchStmt:
+			bm(n.Body, MutateInlineStmts)
+			return
+		case *ast.TypeSwitchStmt:
+			bm(n.Body, MutateInlineStmts)
+			return
+		case *ast.CaseClause:
+			for i, stmt := range append([]ast.Stmt{}, n.Body...) {
+				MutateInlineStmts(&n.Body, i, stmt, bm)
+			}
+			return
+		case *ast.ExprStmt:
+			call, callee = GetInlineCall(n.X)
+		case *ast.AssignStmt:
+			if len(n.Rhs)==1 {
+				call, callee = GetInlineCall(n.Rhs[0])
+			}
+		case *ast.DeclStmt:
+			if gen_decl, is_gen := n.Decl.(*ast.GenDecl); is_gen && len(gen_decl.Specs)==1 {
+				if val_spec, is_val := gen_decl.Specs[0].(*ast.ValueSpec); is_val && len(val_spec.Values)==1 {
+					call, callee = GetInlineCall(val_spec.Values[0])
+				}
+			}
+		case *ast.ReturnStmt:
+			if len(n.Results)==1 {
+				call, callee = GetInlineCall(n.Results[0])
+			}
+	}
+	if call==nil || IsInlineShadowed(callee) {
+		return
+	}
+	
+	site := ASTCtxt.TmpVar
+	names := MakeInlineNames(callee, site)
+	subst, copies, ok := BindInlineArgs(call, callee, names, site, true)
+	if !ok {
+		return
+	}
+	ASTCtxt.TmpVar++
+	ASTCtxt.Inlined = true
+	body := CloneInlineBody(callee, subst, names)
+	sig := ASTCtxt.TypeInfo.Defs[callee.Name].Type().(*types.Signature)
+	
+	/// 'a, b := f()' returns straight into 'a' and 'b' when the args don't use them.
+	stmts := copies
+	var results []string
+	if assign, is_assign := s.(*ast.AssignStmt); is_assign && (assign.Tok==token.DEFINE || assign.Tok==token.ASSIGN) && len(assign.Lhs)==sig.Results().Len() && (callee.Type.Results==nil || len(callee.Type.Results.List[0].Names)==0) {
+		lhs_names := make(map[string]bool)
+		for _, lhs := range assign.Lhs {
+			if iden, is_ident := lhs.(*ast.Ident); is_ident && iden.Name != "_" && !lhs_names[iden.Name] {
+				lhs_names[iden.Name] = true
+				results = append(results, iden.Name)
+			}
+		}
+		ast.Inspect(call, func(n ast.Node) bool {
+			if iden, is_ident := n.(*ast.Ident); is_ident && lhs_names[iden.Name] {
+				results = nil
+			}
+			return results != nil
+		})
+		if len(results)==len(assign.Lhs) {
+			for _, lhs := range assign.Lhs {
+				if obj := ASTCtxt.TypeInfo.Defs[lhs.(*ast.Ident)]; obj != nil {
+					stmts = append(stmts, MakeLocalVar(obj.Name(), obj.Type(), nil))
+				}
+			}
+			flow, _ := MakeInlineFlow(body.List, results)
+			stmts = append(stmts, flow...)
+			idx := FindStmt(*owner_list, s)
+			*owner_list = append((*owner_list)[:idx], (*owner_list)[idx+1:]...)
+			for i := len(stmts)-1; i >= 0; i-- {
+				*owner_list = InsertStmt(*owner_list, idx, stmts[i])
+			}
+			return
+		}
+		results = nil
+	}
+	
+	/// named results are locals of the body, the rest get 'go_ret#' vars.
+	values := make([]ast.Expr, 0)
+	for i := 0; i < sig.Results().Len(); i++ {
+		res := sig.Results().At(i)
+		name, named := names[res]
+		if !named {
+			name = fmt.Sprintf("go_ret%d", ASTCtxt.TmpVar)
+			ASTCtxt.TmpVar++
+		}
+		if _, discard := s.(*ast.ExprStmt); !discard || named {
+			stmts = append(stmts, MakeLocalVar(name, res.Type(), nil))
+		}
+		results = append(results, name)
+		values = append(values, ast.NewIdent(name))
+	}
+	
+	idx := FindStmt(*owner_list, s)
+	switch n := s.(type) {
+		case *ast.ExprStmt:
+			flow, _ := MakeInlineFlow(body.List, nil)
+			stmts = append(stmts, flow...)
+			if len(stmts)==0 {
+				*owner_list = append((*owner_list)[:idx], (*owner_list)[idx+1:]...)
+				return
+			}
+			(*owner_list)[idx] = stmts[len(stmts)-1]
+			stmts = stmts[:len(stmts)-1]
+		case *ast.AssignStmt:
+			n.Rhs = values
+		case *ast.DeclStmt:
+			n.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Values = values
+		case *ast.ReturnStmt:
+			n.Results = values
+	}
+	if _, is_expr := s.(*ast.ExprStmt); !is_expr {
+		flow, _ := MakeInlineFlow(body.List, results)
+		stmts = append(stmts, flow...)
+	}
+	for i := len(stmts)-1; i >= 0; i-- {
+		*owner_list = InsertStmt(*owner_list, idx, stmts[i])
+	}
+}
+
+/// SourceMod methodmaps declared as structs in the includes that are Handles underneath.
+var HandleTypes = map[string]bool{
+	"ArrayList": true, "ArrayStack": true, "StringMap": true, "StringMapSnapshot": true,
+	"DataPack": true, "ConVar": true, "KeyValues": true, "Menu": true, "Panel": true,
+	"Event": true, "File": true, "DirectoryListing": true, "Database": true, "DBResultSet": true,
+	"GlobalForward": true, "PrivateForward": true, "SMCParser": true, "BfRead": true, "BfWrite": true,
+	"Protobuf": true, "Cookie": true, "CommandIterator": true, "FrameIterator": true, "TF2Item": true,
+	"Profiler": true,
+}
+
+func IsHandleType(typ types.Type) bool {
+	if ptr, is_ptr := typ.(*types.Pointer); is_ptr {
+		typ = ptr.Elem()
+	}
+	named, is_named := types.Unalias(typ).(*types.Named)
+	if !is_named {
+		return false
+	} else if basic, is_basic := named.Underlying().(*types.Basic); is_basic && basic.Kind()==types.Uintptr {
+		return true
+	} else if HandleTypes[named.Obj().Name()] {
+		return true
+	}
+	/// 'type Hale StringMap' shares the struct its Handle type is declared with.
+	if struc, is_struct := named.Underlying().(*types.Struct); is_struct && named.Obj().Pkg() != nil {
+		for name := range HandleTypes {
+			if handle := named.Obj().Pkg().Scope().Lookup(name); handle != nil && handle.Type().Underlying()==struc {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/// "file.go:12" for runtime errors, generated code uses its function's position.
+func MakeDebugWhere(pos token.Pos) *ast.BasicLit {
+	if !pos.IsValid() && ASTCtxt.CurrFunc != nil {
+		pos = ASTCtxt.CurrFunc.Pos()
+	}
+	where := ASTCtxt.FSet.Position(pos)
+	return MakeBasicLit(token.STRING, strconv.Quote(fmt.Sprintf("%s:%d", filepath.Base(where.Filename), where.Line)))
+}
+
+/// func name(params) result { if fail { ThrowError(msg...) }; return ret }
+func MakeDebugCheck(name string, params *ast.FieldList, result string, fail ast.Expr, ret string, msg ...ast.Expr) *ast.FuncDecl {
+	check := new(ast.FuncDecl)
+	check.Name = ast.NewIdent(name)
+	check.Type = new(ast.FuncType)
+	check.Type.Params = params
+	check.Type.Results = MakeFieldList("", ast.NewIdent(result))
+	check.Body = new(ast.BlockStmt)
+	if_stmt := new(ast.IfStmt)
+	if_stmt.Cond = fail
+	if_stmt.Body = new(ast.BlockStmt)
+	if_stmt.Body.List = append(if_stmt.Body.List, MakeExprStmt(MakeCall("ThrowError", msg...)))
+	ret_stmt := new(ast.ReturnStmt)
+	ret_stmt.Results = append(ret_stmt.Results, ast.NewIdent(ret))
+	check.Body.List = append(check.Body.List, if_stmt, ret_stmt)
+	return check
+}
+
+/** '--debug-runtime' checks what SourceMod would only report without a Go line:
+ * 
+ * arr[i]            => arr[go2sp_check_index(i, 10, "plugin.go:12")]
+ * pack.Reset(false) => DataPack(go2sp_check_handle(pack, "plugin.go:13")).Reset(false)
+ * GetEntProp(e, ...) => GetEntProp(go2sp_check_entity(e, "plugin.go:14"), ...)
+ * 
+ * and 'trace' logs entering and leaving each function with 'LogMessage'.
+ */
+func MutateDebugRuntime(file *ast.File, trace bool) {
+	main_file := ASTCtxt.FSet.File(file.Pos())
+	used := make(map[string]bool)
+	for _, decl := range file.Decls {
+		f, is_func := decl.(*ast.FuncDecl)
+		if !is_func || f.Body==nil {
+			continue
+		}
+		ASTCtxt.CurrFunc = f
+		
+		/// 'sizeof' needs its array as it is.
+		in_sizeof := make(map[ast.Node]bool)
+		ast.Inspect(f.Body, func(n ast.Node) bool {
+			if call, is_call := n.(*ast.CallExpr); is_call && GetFuncName(call.Fun)=="sizeof" {
+				ast.Inspect(call, func(n ast.Node) bool {
+					in_sizeof[n] = true
+					return true
+				})
+				return false
+			}
+			return true
+		})
+		
+		ReplaceExprs(reflect.ValueOf(f.Body).Elem(), func(e ast.Expr) ast.Expr {
+			switch x := e.(type) {
+				case *ast.IndexExpr:
+					if in_sizeof[x] || ASTCtxt.TypeInfo.Types[x.Index].Value != nil {
+						break
+					}
+					typ := ASTCtxt.TypeInfo.TypeOf(x.X)
+					if ptr, is_ptr := typ.(*types.Pointer); is_ptr {
+						typ = ptr.Elem()
+					}
+					if typ==nil {
+						break
+					} else if arr, is_arr := typ.Underlying().(*types.Array); is_arr {
+						x.Index = MakeCall("go2sp_check_index", x.Index, MakeBasicLit(token.INT, strconv.FormatInt(arr.Len(), 10)), MakeDebugWhere(x.Lbrack))
+						used["index"] = true
+					}
+				
+				case *ast.SelectorExpr:
+					sel := ASTCtxt.TypeInfo.Selections[x]
+					if sel==nil || sel.Kind()==types.MethodExpr || !IsHandleType(sel.Recv()) {
+						break
+					}
+					recv := sel.Recv()
+					if ptr, is_ptr := recv.(*types.Pointer); is_ptr {
+						recv = ptr.Elem()
+					}
+					x.X = MakeCall(types.Unalias(recv).(*types.Named).Obj().Name(), MakeCall("go2sp_check_handle", x.X, MakeDebugWhere(x.Sel.Pos())))
+					used["handle"] = true
+				
+				case *ast.CallExpr:
+					/// entity args of natives, 'Is*' and 'Find*' natives take invalid ones on purpose.
+					var obj types.Object
+					switch fn := x.Fun.(type) {
+						case *ast.Ident:
+							obj = ASTCtxt.TypeInfo.Uses[fn]
+						case *ast.SelectorExpr:
+							obj = ASTCtxt.TypeInfo.Uses[fn.Sel]
+					}
+					fn, is_func := obj.(*types.Func)
+					if !is_func || ASTCtxt.FSet.File(fn.Pos())==main_file || strings.HasPrefix(fn.Name(), "Is") || strings.HasPrefix(fn.Name(), "Find") {
+						break
+					}
+					params := fn.Type().(*types.Signature).Params()
+					for i, arg := range x.Args {
+						if i >= params.Len() {
+							break
+						}
+						if IsEntityType(params.At(i).Type()) && ASTCtxt.TypeInfo.Types[arg].Value==nil {
+							x.Args[i] = MakeCall("go2sp_check_entity", arg, MakeDebugWhere(arg.Pos()))
+							used["entity"] = true
+						}
+					}
+			}
+			return e
+		})
+		
+		/// trampolines and other generated functions have no position to log.
+		if trace && f.Pos().IsValid() {
+			name := f.Name.Name
+			/// functions other passes generate have no object until the next re-check, they're never methods.
+			var sig *types.Signature
+			if obj := ASTCtxt.TypeInfo.Defs[f.Name]; obj != nil {
+				sig, _ = obj.Type().(*types.Signature)
+			}
+			if sig != nil && sig.Recv() != nil {
+				recv := sig.Recv().Type()
+				if ptr, is_ptr := recv.(*types.Pointer); is_ptr {
+					recv = ptr.Elem()
+				}
+				if named, is_named := types.Unalias(recv).(*types.Named); is_named {
+					name = named.Obj().Name() + "." + name
+				}
+			}
+			/// cell results are saved first so "leaving" is logged after they're evaluated.
+			ret_type := GetCellResultType(f)
+			log := func(pos token.Pos, event string) ast.Stmt {
+				where := MakeDebugWhere(pos)
+				return MakeExprStmt(MakeCall("LogMessage", MakeBasicLit(token.STRING, strconv.Quote(fmt.Sprintf("%s: %s %s", where.Value[1:len(where.Value)-1], event, name)))))
+			}
+			ast.Inspect(f.Body, func(n ast.Node) bool {
+				var list *[]ast.Stmt
+				switch x := n.(type) {
+					case *ast.BlockStmt:
+						list = &x.List
+					case *ast.CaseClause:
+						list = &x.Body
+					case *ast.FuncLit:
+						return false
+					default:
+						return true
+				}
+				traced := make([]ast.Stmt, 0, len(*list))
+				for _, stmt := range *list {
+					if ret, is_ret := stmt.(*ast.ReturnStmt); is_ret {
+						if decl := SaveRetResult(ret, ret_type, "go_trace_ret"); decl != nil {
+							traced = append(traced, decl)
+						}
+						traced = append(traced, log(ret.Pos(), "leaving"))
+					}
+					traced = append(traced, stmt)
+				}
+				*list = traced
+				return true
+			})
+			f.Body.List = InsertStmt(f.Body.List, 0, log(f.Pos(), "entering"))
+			if _, ends := f.Body.List[len(f.Body.List)-1].(*ast.ReturnStmt); !ends {
+				f.Body.List = append(f.Body.List, log(f.Body.Rbrace, "leaving"))
+			}
+		}
+		ASTCtxt.CurrFunc = nil
+	}
+	
+	where := MakeFieldList("where", ast.NewIdent("string"))
+	if used["index"] {
+		/// func go2sp_check_index(index, size int, where string) int
+		params := MakeFieldList("", ast.NewIdent("int"))
+		params.List[0].Names = []*ast.Ident{ast.NewIdent("index"), ast.NewIdent("size")}
+		params.List = append(params.List, where.List...)
+		fail := MakeBinaryExpr(MakeBinaryExpr(ast.NewIdent("index"), token.LSS, MakeBasicLit(token.INT, "0")), token.LOR, MakeBinaryExpr(ast.NewIdent("index"), token.GEQ, ast.NewIdent("size")))
+		file.Decls = append(file.Decls, MakeDebugCheck("go2sp_check_index", params, "int", fail, "index", MakeBasicLit(token.STRING, `"%s: index %d is out of bounds [0, %d)."`), ast.NewIdent("where"), ast.NewIdent("index"), ast.NewIdent("size")))
+	}
+	if used["handle"] {
+		/// func go2sp_check_handle(handle any, where string) any
+		params := MakeFieldList("handle", ast.NewIdent("any"))
+		params.List = append(params.List, MakeFieldList("where", ast.NewIdent("string")).List...)
+		fail := MakeBinaryExpr(MakeCall("Handle", ast.NewIdent("handle")), token.EQL, ast.NewIdent("INVALID_HANDLE"))
+		file.Decls = append(file.Decls, MakeDebugCheck("go2sp_check_handle", params, "any", fail, "handle", MakeBasicLit(token.STRING, `"%s: invalid handle."`), ast.NewIdent("where")))
+	}
+	if used["entity"] {
+		/// func go2sp_check_entity(entity int, where string) int
+		params := MakeFieldList("entity", ast.NewIdent("int"))
+		params.List = append(params.List, MakeFieldList("where", ast.NewIdent("string")).List...)
+		fail := new(ast.UnaryExpr)
+		fail.Op = token.NOT
+		fail.X = MakeCall("IsValidEntity", ast.NewIdent("entity"))
+		file.Decls = append(file.Decls, MakeDebugCheck("go2sp_check_entity", params, "int", fail, "entity", MakeBasicLit(token.STRING, `"%s: invalid entity %d."`), ast.NewIdent("where"), ast.NewIdent("entity")))
+	}
+}
+
+/// adds 'import "path"' for passes that call into an include the plugin didn't import.
+func AddImport(file *ast.File, path string) {
+	for _, imp := range file.Imports {
+		if imp.Path.Value==strconv.Quote(path) {
+			return
+		}
+	}
+	imp := new(ast.ImportSpec)
+	imp.Path = MakeBasicLit(token.STRING, strconv.Quote(path))
+	file.Imports = append(file.Imports, imp)
+	for _, decl := range file.Decls {
+		if gen_decl, is_gen := decl.(*ast.GenDecl); is_gen && gen_decl.Tok==token.IMPORT {
+			gen_decl.Specs = append(gen_decl.Specs, imp)
+			return
+		}
+	}
+	import_decl := new(ast.GenDecl)
+	import_decl.Tok = token.IMPORT
+	import_decl.Specs = append(import_decl.Specs, imp)
+	file.Decls = append([]ast.Decl{import_decl}, file.Decls...)
+}
+
+/// '--profile' profiles every function, otherwise only '//go2sp:profile' ones are.
+func GetProfiledFuncs(file *ast.File, all bool) []*ast.FuncDecl {
+	profiled := make([]*ast.FuncDecl, 0)
+	for _, decl := range file.Decls {
+		f, is_func := decl.(*ast.FuncDecl)
+		if !is_func || f.Body==nil || strings.HasPrefix(f.Name.Name, "go2sp_") {
+			continue
+		}
+		if _, found := GetDirective(f.Doc, "profile"); found || all {
+			profiled = append(profiled, f)
+		}
+	}
+	return profiled
+}
+
+/// profiling needs the profiler include, it has to be imported before type-checking.
+func AddProfilerImport(file *ast.File, all bool) {
+	if len(GetProfiledFuncs(file, all)) > 0 {
+		AddImport(file, "profiler")
+	}
+}
+
+/** '--profile' and '//go2sp:profile' time functions with a Profiler each:
+ * 
+ * func Think(client int) int {       public int Think(int client)
+ *     return GetClientHealth(client) {
+ * }                                      go2sp_prof_enter(0);
+ *                                        int go_prof_ret = GetClientHealth(client);
+ *                                        go2sp_prof_leave(0);
+ *                                        return go_prof_ret;
+ *                                    }
+ * 
+ * this runs after 'MutateRets', so the by-ref outs are set before the profiler stops.
+ * the 'sm_go2sp_prof' admin command prints the functions sorted by their total time.
+ */
+func MutateProfiling(file *ast.File, all bool) {
+	profiled := GetProfiledFuncs(file, all)
+	if len(profiled)==0 {
+		return
+	}
+	names := new(ast.CompositeLit)
+	names.Type = Arrayify(ast.NewIdent("string"), MakeBasicLit(token.INT, strconv.Itoa(len(profiled))))
+	for id, f := range profiled {
+		ASTCtxt.CurrFunc = f
+		name := f.Name.Name
+		if f.Recv != nil {
+			if recv := ASTCtxt.TypeInfo.TypeOf(f.Recv.List[0].Type); recv != nil {
+				if ptr, is_ptr := recv.(*types.Pointer); is_ptr {
+					recv = ptr.Elem()
+				}
+				if named, is_named := types.Unalias(recv).(*types.Named); is_named {
+					name = named.Obj().Name() + "." + name
+				}
+			}
+		}
+		names.Elts = append(names.Elts, MakeBasicLit(token.STRING, strconv.Quote(name)))
+		
+		prof_call := func(fn string) ast.Stmt {
+			return MakeExprStmt(MakeCall(fn, MakeBasicLit(token.INT, strconv.Itoa(id))))
+		}
+		/// cell results are saved before stopping so the profiler covers them too.
+		ret_type := GetCellResultType(f)
+		ast.Inspect(f.Body, func(n ast.Node) bool {
+			var list *[]ast.Stmt
+			switch x := n.(type) {
+				case *ast.BlockStmt:
+					list = &x.List
+				case *ast.CaseClause:
+					list = &x.Body
+				case *ast.FuncLit:
+					return false
+				default:
+					return true
+			}
+			profiled_list := make([]ast.Stmt, 0, len(*list))
+			for _, stmt := range *list {
+				if ret, is_ret := stmt.(*ast.ReturnStmt); is_ret {
+					if decl := SaveRetResult(ret, ret_type, "go_prof_ret"); decl != nil {
+						profiled_list = append(profiled_list, decl)
+					}
+					profiled_list = append(profiled_list, prof_call("go2sp_prof_leave"))
+				}
+				profiled_list = append(profiled_list, stmt)
+			}
+			*list = profiled_list
+			return true
+		})
+		f.Body.List = InsertStmt(f.Body.List, 0, prof_call("go2sp_prof_enter"))
+		if _, ends := f.Body.List[len(f.Body.List)-1].(*ast.ReturnStmt); !ends {
+			f.Body.List = append(f.Body.List, prof_call("go2sp_prof_leave"))
+		}
+		ASTCtxt.CurrFunc = nil
+	}
+	file.Decls = append(file.Decls, MakeProfilerDecls(len(profiled), names)...)
+	
+	/// the profilers are made and the command registered first thing when the plugin starts.
+	AddPluginStartStmt(file, MakeExprStmt(MakeCall("go2sp_prof_init")))
+}
+
+/// the result type of a function returning a single cell, nil otherwise.
+func GetCellResultType(f *ast.FuncDecl) ast.Expr {
+	if f.Type.Results != nil && len(f.Type.Results.List) > 0 {
+		if typ := ASTCtxt.TypeInfo.TypeOf(f.Type.Results.List[0].Type); typ != nil && IsCellType(typ) {
+			return f.Type.Results.List[0].Type
+		}
+	}
+	return nil
+}
+
+/// 'return x' => 'var tmp T = x; return tmp', so code put before the return runs after 'x' is evaluated.
+func SaveRetResult(ret *ast.ReturnStmt, ret_type ast.Expr, prefix string) ast.Stmt {
+	if len(ret.Results) != 1 || ret_type==nil || IsPureExpr(ret.Results[0]) {
+		return nil
+	}
+	tmp := fmt.Sprintf("%s%d", prefix, ASTCtxt.TmpVar)
+	ASTCtxt.TmpVar++
+	decl := MakeVarDecl([]*ast.Ident{ast.NewIdent(tmp)}, nil, nil)
+	val_spec := decl.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+	val_spec.Type = CloneAST(ret_type, nil).(ast.Expr)
+	val_spec.Values = append(val_spec.Values, ret.Results[0])
+	ret.Results[0] = ast.NewIdent(tmp)
+	return decl
+}
+
+/// puts 'stmt' at the top of 'main'/'OnPluginStart', making an 'OnPluginStart' if there's neither.
+func AddPluginStartStmt(file *ast.File, stmt ast.Stmt) {
+	for _, decl := range file.Decls {
+		if f, is_func := decl.(*ast.FuncDecl); is_func && f.Recv==nil && f.Body != nil && (f.Name.Name=="main" || f.Name.Name=="OnPluginStart") {
+			f.Body.List = InsertStmt(f.Body.List, 0, stmt)
+			return
+		}
+	}
+	plugin_start := new(ast.FuncDecl)
+	plugin_start.Name = ast.NewIdent("OnPluginStart")
+	plugin_start.Type = new(ast.FuncType)
+	plugin_start.Type.Params = new(ast.FieldList)
+	plugin_start.Body = new(ast.BlockStmt)
+	plugin_start.Body.List = append(plugin_start.Body.List, stmt)
+	file.Decls = append(file.Decls, plugin_start)
+}
+
+/// the profiler tables, enter/leave, init and the 'sm_go2sp_prof' command.
+func MakeProfilerDecls(count int, names *ast.CompositeLit) []ast.Decl {
+	decls := make([]ast.Decl, 0)
+	size := func() ast.Expr {
+		return MakeBasicLit(token.INT, strconv.Itoa(count))
+	}
+	table := func(name string, elem ast.Expr, value ast.Expr) {
+		spec := new(ast.ValueSpec)
+		spec.Names = append(spec.Names, ast.NewIdent(name))
+		spec.Type = Arrayify(elem, size())
+		if value != nil {
+			spec.Values = append(spec.Values, value)
+		}
+		gen_decl := new(ast.GenDecl)
+		gen_decl.Tok = token.VAR
+		gen_decl.Specs = append(gen_decl.Specs, spec)
+		decls = append(decls, gen_decl)
+	}
+	table("go2sp_prof", ast.NewIdent("Handle"), nil)
+	table("go2sp_prof_depth", ast.NewIdent("int"), nil)
+	table("go2sp_prof_calls", ast.NewIdent("int"), nil)
+	table("go2sp_prof_time", ast.NewIdent("float"), nil)
+	table("go2sp_prof_names", ast.NewIdent("string"), names)
+	
+	make_func := func(name string, params, results *ast.FieldList, body ...ast.Stmt) {
+		fn := new(ast.FuncDecl)
+		fn.Name = ast.NewIdent(name)
+		fn.Type = new(ast.FuncType)
+		fn.Type.Params = params
+		fn.Type.Results = results
+		fn.Body = new(ast.BlockStmt)
+		fn.Body.List = body
+		decls = append(decls, fn)
+	}
+	at := func(table string, index string) ast.Expr {
+		return MakeIndex(ast.NewIdent(index), ast.NewIdent(table))
+	}
+	inc_dec := func(x ast.Expr, tok token.Token) ast.Stmt {
+		stmt := new(ast.IncDecStmt)
+		stmt.X = x
+		stmt.Tok = tok
+		return stmt
+	}
+	make_if := func(cond ast.Expr, body ...ast.Stmt) *ast.IfStmt {
+		if_stmt := new(ast.IfStmt)
+		if_stmt.Cond = cond
+		if_stmt.Body = new(ast.BlockStmt)
+		if_stmt.Body.List = body
+		return if_stmt
+	}
+	make_for := func(index string, start ast.Expr, cond ast.Expr, post ast.Stmt, body ...ast.Stmt) *ast.ForStmt {
+		for_stmt := new(ast.ForStmt)
+		for_stmt.Init = MakeAssignTok(ast.NewIdent(index), token.ASSIGN, start)
+		for_stmt.Cond = cond
+		for_stmt.Post = post
+		for_stmt.Body = new(ast.BlockStmt)
+		for_stmt.Body.List = body
+		return for_stmt
+	}
+	local := func(name string, typ ast.Expr) ast.Stmt {
+		decl := MakeVarDecl([]*ast.Ident{ast.NewIdent(name)}, nil, nil)
+		decl.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Type = typ
+		return decl
+	}
+	
+	/// recursive calls only count the outermost one's time.
+	make_func("go2sp_prof_enter", MakeFieldList("id", ast.NewIdent("int")), nil,
+		inc_dec(at("go2sp_prof_calls", "id"), token.INC),
+		inc_dec(at("go2sp_prof_depth", "id"), token.INC),
+		make_if(MakeBinaryExpr(at("go2sp_prof_depth", "id"), token.EQL, MakeBasicLit(token.INT, "1")),
+			MakeExprStmt(MakeCall("StartProfiling", at("go2sp_prof", "id")))))
+	
+	make_func("go2sp_prof_leave", MakeFieldList("id", ast.NewIdent("int")), nil,
+		inc_dec(at("go2sp_prof_depth", "id"), token.DEC),
+		make_if(MakeBinaryExpr(at("go2sp_prof_depth", "id"), token.EQL, MakeBasicLit(token.INT, "0")),
+			MakeExprStmt(MakeCall("StopProfiling", at("go2sp_prof", "id"))),
+			MakeAssignTok(at("go2sp_prof_time", "id"), token.ADD_ASSIGN, MakeCall("GetProfilerTime", at("go2sp_prof", "id")))))
+	
+	make_func("go2sp_prof_init", new(ast.FieldList), nil,
+		local("i", ast.NewIdent("int")),
+		make_for("i", MakeBasicLit(token.INT, "0"), MakeBinaryExpr(ast.NewIdent("i"), token.LSS, size()), inc_dec(ast.NewIdent("i"), token.INC),
+			MakeAssignTok(at("go2sp_prof", "i"), token.ASSIGN, MakeCall("CreateProfiler"))),
+		MakeExprStmt(MakeCall("RegAdminCmd", MakeBasicLit(token.STRING, `"sm_go2sp_prof"`), ast.NewIdent("go2sp_prof_cmd"), ast.NewIdent("ADMFLAG_ROOT"), MakeBasicLit(token.STRING, `"prints the time spent in each profiled function."`), MakeBasicLit(token.STRING, `""`), MakeBasicLit(token.INT, "0"))))
+	
+	/// insertion sort by total time, slowest first.
+	cmd_params := MakeFieldList("", ast.NewIdent("int"))
+	cmd_params.List[0].Names = []*ast.Ident{ast.NewIdent("client"), ast.NewIdent("args")}
+	plugin_handled := new(ast.ReturnStmt)
+	plugin_handled.Results = append(plugin_handled.Results, ast.NewIdent("Plugin_Handled"))
+	time_of := func(index ast.Expr) ast.Expr {
+		return MakeIndex(index, ast.NewIdent("go2sp_prof_time"))
+	}
+	j := ast.NewIdent("j")
+	shift_cond := MakeBinaryExpr(MakeBinaryExpr(ast.NewIdent("j"), token.GEQ, MakeBasicLit(token.INT, "0")), token.LAND, MakeBinaryExpr(time_of(at("order", "j")), token.LSS, time_of(ast.NewIdent("id"))))
+	shift := new(ast.ForStmt)
+	shift.Cond = shift_cond
+	shift.Body = new(ast.BlockStmt)
+	shift.Body.List = append(shift.Body.List,
+		MakeAssignTok(MakeIndex(MakeBinaryExpr(ast.NewIdent("j"), token.ADD, MakeBasicLit(token.INT, "1")), ast.NewIdent("order")), token.ASSIGN, at("order", "j")),
+		inc_dec(j, token.DEC))
+	make_func("go2sp_prof_cmd", cmd_params, MakeFieldList("", ast.NewIdent("Action")),
+		local("order", Arrayify(ast.NewIdent("int"), size())),
+		local("i", ast.NewIdent("int")),
+		local("j", ast.NewIdent("int")),
+		local("id", ast.NewIdent("int")),
+		make_for("i", MakeBasicLit(token.INT, "0"), MakeBinaryExpr(ast.NewIdent("i"), token.LSS, size()), inc_dec(ast.NewIdent("i"), token.INC),
+			MakeAssignTok(at("order", "i"), token.ASSIGN, ast.NewIdent("i"))),
+		make_for("i", MakeBasicLit(token.INT, "1"), MakeBinaryExpr(ast.NewIdent("i"), token.LSS, size()), inc_dec(ast.NewIdent("i"), token.INC),
+			MakeAssignTok(ast.NewIdent("id"), token.ASSIGN, at("order", "i")),
+			MakeAssignTok(ast.NewIdent("j"), token.ASSIGN, MakeBinaryExpr(ast.NewIdent("i"), token.SUB, MakeBasicLit(token.INT, "1"))),
+			shift,
+			MakeAssignTok(MakeIndex(MakeBinaryExpr(ast.NewIdent("j"), token.ADD, MakeBasicLit(token.INT, "1")), ast.NewIdent("order")), token.ASSIGN, ast.NewIdent("id"))),
+		MakeExprStmt(MakeCall("ReplyToCommand", ast.NewIdent("client"), MakeBasicLit(token.STRING, `"%-32s %10s %14s %14s"`), MakeBasicLit(token.STRING, `"function"`), MakeBasicLit(token.STRING, `"calls"`), MakeBasicLit(token.STRING, `"total (s)"`), MakeBasicLit(token.STRING, `"average (ms)"`))),
+		make_for("i", MakeBasicLit(token.INT, "0"), MakeBinaryExpr(ast.NewIdent("i"), token.LSS, size()), inc_dec(ast.NewIdent("i"), token.INC),
+			MakeAssignTok(ast.NewIdent("id"), token.ASSIGN, at("order", "i")),
+			make_if(MakeBinaryExpr(at("go2sp_prof_calls", "id"), token.GTR, MakeBasicLit(token.INT, "0")),
+				MakeExprStmt(MakeCall("ReplyToCommand", ast.NewIdent("client"), MakeBasicLit(token.STRING, `"%-32s %10d %14.6f %14.6f"`), at("go2sp_prof_names", "id"), at("go2sp_prof_calls", "id"), at("go2sp_prof_time", "id"),
+					MakeBinaryExpr(MakeBinaryExpr(at("go2sp_prof_time", "id"), token.MUL, MakeBasicLit(token.FLOAT, "1000.0")), token.QUO, MakeCall("float", at("go2sp_prof_calls", "id"))))))),
+		plugin_handled)
+	return decls
+}
+
+/// splits "sm_slay admin=ADMFLAG_SLAY desc=\"slays a player.\"" into the command name and its options.
+func ParseCommandDirective(text string) (string, map[string]string) {
+	fields := make([]string, 0)
+	var field strings.Builder
+	quoted := false
+	for _, c := range text {
+		switch {
+			case c=='"':
+				quoted = !quoted
+				field.WriteRune(c)
+			case unicode.IsSpace(c) && !quoted:
+				if field.Len() > 0 {
+					fields = append(fields, field.String())
+					field.Reset()
+				}
+			default:
+				field.WriteRune(c)
+		}
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	
+	opts := make(map[string]string)
+	if len(fields)==0 {
+		return "", opts
+	}
+	for _, opt := range fields[1:] {
+		key, value, _ := strings.Cut(opt, "=")
+		if unquoted, err := strconv.Unquote(value); err==nil {
+			value = unquoted
+		}
+		opts[key] = value
+	}
+	return fields[0], opts
+}
+
+/** '//go2sp:command' registers a function with typed params as a console command:
+ * 
+ * //go2sp:command sm_slay admin=ADMFLAG_SLAY
+ * func Slay(client Entity, target Entity, damage int)
+ * 
+ * the first param is the client using the command, the rest are parsed from the command's args.
+ * int and float params go through 'StringToInt'/'StringToFloat', bools are a non-zero number, "true" or "yes", strings are copied as they are,
+ * an 'Entity' param is a target pattern given to 'ProcessTargetString' and the function is called for each target.
+ * 
+ * public Action go2sp_cmd_Slay(int client, int args)
+ * {
+ *     if (args < 2) { ReplyToCommand(client, "[SM] Usage: sm_slay <target> <damage>"); return Plugin_Handled; }
+ *     ...
+ *     for (i = 0; i < target_count; i++) { Slay(client, target_list[i], arg_damage); }
+ *     return Plugin_Handled;
+ * }
+ * 
+ * 'admin=' uses 'RegAdminCmd' instead of 'RegConsoleCmd', 'desc=' sets the description and 'filter=' the target filter flags.
+ */
+func MutateCommands(file *ast.File) {
+	regs := make([]ast.Stmt, 0)
+	for i := 0; i < len(file.Decls); i++ {
+		f, is_func := file.Decls[i].(*ast.FuncDecl)
+		if !is_func || f.Recv != nil {
+			continue
+		}
+		text, found := GetDirective(f.Doc, "command")
+		if !found {
+			continue
+		}
+		cmd, opts := ParseCommandDirective(text)
+		if cmd=="" {
+			PrintSrcGoErr(f.Pos(), fmt.Sprintf("'//go2sp:command' on '%s' needs a command name.", f.Name.Name))
+			continue
+		}
+		wrapper, ok := MakeCommandWrapper(f, cmd, opts)
+		if !ok {
+			continue
+		}
+		file.Decls = append(file.Decls, wrapper)
+		
+		var reg *ast.CallExpr
+		desc := MakeBasicLit(token.STRING, strconv.Quote(opts["desc"]))
+		if admin, has_admin := opts["admin"]; has_admin {
+			flags, err := parser.ParseExpr(admin)
+			if err != nil {
+				PrintSrcGoErr(f.Pos(), fmt.Sprintf("bad admin flags '%s' for command '%s'.", admin, cmd))
+				continue
+			}
+			reg = MakeCall("RegAdminCmd", MakeBasicLit(token.STRING, strconv.Quote(cmd)), ast.NewIdent(wrapper.Name.Name), flags, desc, MakeBasicLit(token.STRING, `""`), MakeBasicLit(token.INT, "0"))
+		} else {
+			reg = MakeCall("RegConsoleCmd", MakeBasicLit(token.STRING, strconv.Quote(cmd)), ast.NewIdent(wrapper.Name.Name), desc, MakeBasicLit(token.INT, "0"))
+		}
+		regs = append(regs, MakeExprStmt(reg))
+	}
+	
+	/// the commands are registered in the order they're declared in.
+	for i := len(regs) - 1; i >= 0; i-- {
+		AddPluginStartStmt(file, regs[i])
+	}
+}
+
+/// makes the 'ConCmd' that parses the args for 'f', false if 'f' can't be a command.
+func MakeCommandWrapper(f *ast.FuncDecl, cmd string, opts map[string]string) (*ast.FuncDecl, bool) {
+	obj, _ := ASTCtxt.TypeInfo.Defs[f.Name].(*types.Func)
+	if obj==nil {
+		return nil, false
+	}
+	sig := obj.Type().(*types.Signature)
+	returns_action := false
+	switch {
+		case sig.Results().Len()==1 && IsNamedType(sig.Results().At(0).Type(), "Action"):
+			returns_action = true
+		case sig.Results().Len() > 0:
+			PrintSrcGoErr(f.Pos(), fmt.Sprintf("command function '%s' can only return 'Action' or nothing.", f.Name.Name))
+			return nil, false
+	}
+	if sig.Params().Len()==0 || !IsIntegerType(sig.Params().At(0).Type()) {
+		PrintSrcGoErr(f.Pos(), fmt.Sprintf("command function '%s' needs the client as its first param.", f.Name.Name))
+		return nil, false
+	}
+	
+	wrapper := new(ast.FuncDecl)
+	wrapper.Name = ast.NewIdent("go2sp_cmd_" + f.Name.Name)
+	wrapper.Type = new(ast.FuncType)
+	wrapper.Type.Params = MakeFieldList("", ast.NewIdent("int"))
+	wrapper.Type.Params.List[0].Names = []*ast.Ident{ast.NewIdent("client"), ast.NewIdent("args")}
+	wrapper.Type.Results = MakeFieldList("", ast.NewIdent("Action"))
+	wrapper.Body = new(ast.BlockStmt)
+	
+	local := func(name string, typ ast.Expr, value ast.Expr) ast.Stmt {
+		decl := MakeVarDecl([]*ast.Ident{ast.NewIdent(name)}, nil, nil)
+		val_spec := decl.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec)
+		val_spec.Type = typ
+		if value != nil {
+			val_spec.Values = append(val_spec.Values, value)
+		}
+		return decl
+	}
+	handled := func() ast.Stmt {
+		ret := new(ast.ReturnStmt)
+		ret.Results = append(ret.Results, ast.NewIdent("Plugin_Handled"))
+		return ret
+	}
+	get_arg := func(n int, buffer string) ast.Stmt {
+		return MakeExprStmt(MakeCall("GetCmdArg", MakeBasicLit(token.INT, strconv.Itoa(n)), ast.NewIdent(buffer), MakeCall("sizeof", ast.NewIdent(buffer))))
+	}
+	
+	usage := "[SM] Usage: " + cmd
+	call := MakeCall(f.Name.Name, ast.NewIdent("client"))
+	parse := make([]ast.Stmt, 0)
+	target := ""
+	for n := 1; n < sig.Params().Len(); n++ {
+		param := sig.Params().At(n)
+		usage += " <" + param.Name() + ">"
+		arg := "arg_" + param.Name()
+		typ := param.Type()
+		switch {
+			case IsStringType(typ):
+				parse = append(parse, local(arg, ast.NewIdent("string"), nil), get_arg(n, arg))
+				call.Args = append(call.Args, ast.NewIdent(arg))
+			
+			case IsEntityType(typ):
+				if target != "" {
+					PrintSrcGoErr(f.Pos(), fmt.Sprintf("command function '%s' can only have one target param.", f.Name.Name))
+					return nil, false
+				}
+				target = arg
+				parse = append(parse, local(arg, ast.NewIdent("string"), nil), get_arg(n, arg))
+				call.Args = append(call.Args, MakeIndex(ast.NewIdent("target_i"), ast.NewIdent("target_list")))
+			
+			case IsFloatType(typ):
+				parse = append(parse, get_arg(n, "cmd_arg"), local(arg, ast.NewIdent("float"), MakeCall("StringToFloat", ast.NewIdent("cmd_arg"))))
+				call.Args = append(call.Args, ast.NewIdent(arg))
+			
+			case IsBoolType(typ):
+				/// a non-zero number, "true" or "yes" in any case.
+				is_word := func(word string) ast.Expr {
+					return MakeCall("StrEqual", ast.NewIdent("cmd_arg"), MakeBasicLit(token.STRING, strconv.Quote(word)), ast.NewIdent("false"))
+				}
+				is_true := MakeBinaryExpr(MakeCall("StringToInt", ast.NewIdent("cmd_arg"), MakeBasicLit(token.INT, "10")), token.NEQ, MakeBasicLit(token.INT, "0"))
+				is_true = MakeBinaryExpr(MakeBinaryExpr(is_true, token.LOR, is_word("true")), token.LOR, is_word("yes"))
+				parse = append(parse, get_arg(n, "cmd_arg"), local(arg, ast.NewIdent("bool"), is_true))
+				call.Args = append(call.Args, ast.NewIdent(arg))
+			
+			case IsIntegerType(typ):
+				parse = append(parse, get_arg(n, "cmd_arg"), local(arg, ast.NewIdent("int"), MakeCall("StringToInt", ast.NewIdent("cmd_arg"), MakeBasicLit(token.INT, "10"))))
+				call.Args = append(call.Args, ast.NewIdent(arg))
+			
+			default:
+				PrintSrcGoErr(param.Pos(), fmt.Sprintf("command param '%s' of type '%s' can't be parsed from a command arg.", param.Name(), types.TypeString(typ, nil)))
+				return nil, false
+		}
+	}
+	
+	if sig.Params().Len() > 1 {
+		too_few := new(ast.IfStmt)
+		too_few.Cond = MakeBinaryExpr(ast.NewIdent("args"), token.LSS, MakeBasicLit(token.INT, strconv.Itoa(sig.Params().Len() - 1)))
+		too_few.Body = new(ast.BlockStmt)
+		too_few.Body.List = append(too_few.Body.List, MakeExprStmt(MakeCall("ReplyToCommand", ast.NewIdent("client"), MakeBasicLit(token.STRING, strconv.Quote(usage)))), handled())
+		wrapper.Body.List = append(wrapper.Body.List, too_few, local("cmd_arg", ast.NewIdent("string"), nil))
+	}
+	wrapper.Body.List = append(wrapper.Body.List, parse...)
+	
+	if target=="" {
+		if returns_action {
+			ret := new(ast.ReturnStmt)
+			ret.Results = append(ret.Results, call)
+			wrapper.Body.List = append(wrapper.Body.List, ret)
+		} else {
+			wrapper.Body.List = append(wrapper.Body.List, MakeExprStmt(call), handled())
+		}
+		return wrapper, true
+	}
+	
+	/// target patterns like '@all' or '#3' resolve to every client they match.
+	filter := ast.Expr(MakeBasicLit(token.INT, "0"))
+	if opts["filter"] != "" {
+		if flags, err := parser.ParseExpr(opts["filter"]); err==nil {
+			filter = flags
+		} else {
+			PrintSrcGoErr(f.Pos(), fmt.Sprintf("bad target filter '%s' for command '%s'.", opts["filter"], cmd))
+			return nil, false
+		}
+	}
+	wrapper.Body.List = append(wrapper.Body.List,
+		local("target_name", ast.NewIdent("string"), nil),
+		local("target_list", Arrayify(ast.NewIdent("int"), ast.NewIdent("MAXPLAYERS")), nil),
+		local("target_ml", ast.NewIdent("bool"), nil),
+		local("target_count", ast.NewIdent("int"), MakeCall("ProcessTargetString", ast.NewIdent(target), ast.NewIdent("client"), ast.NewIdent("target_list"), ast.NewIdent("MAXPLAYERS"), filter, ast.NewIdent("target_name"), MakeCall("sizeof", ast.NewIdent("target_name")), MakeReference(ast.NewIdent("target_ml")))))
+	
+	no_targets := new(ast.IfStmt)
+	no_targets.Cond = MakeBinaryExpr(ast.NewIdent("target_count"), token.LEQ, MakeBasicLit(token.INT, "0"))
+	no_targets.Body = new(ast.BlockStmt)
+	no_targets.Body.List = append(no_targets.Body.List, MakeExprStmt(MakeCall("ReplyToTargetError", ast.NewIdent("client"), ast.NewIdent("target_count"))), handled())
+	
+	each_target := new(ast.ForStmt)
+	each_target.Init = MakeAssignTok(ast.NewIdent("target_i"), token.ASSIGN, MakeBasicLit(token.INT, "0"))
+	each_target.Cond = MakeBinaryExpr(ast.NewIdent("target_i"), token.LSS, ast.NewIdent("target_count"))
+	inc := new(ast.IncDecStmt)
+	inc.X = ast.NewIdent("target_i")
+	inc.Tok = token.INC
+	each_target.Post = inc
+	each_target.Body = new(ast.BlockStmt)
+	each_target.Body.List = append(each_target.Body.List, MakeExprStmt(call))
+	wrapper.Body.List = append(wrapper.Body.List, no_targets, local("target_i", ast.NewIdent("int"), nil), each_target, handled())
+	return wrapper, true
+}
+
+/// a struct whose fields all have 'cvar' tags binds each field to a ConVar.
+func IsConVarStruct(st *ast.StructType) bool {
+	if st.Fields==nil || len(st.Fields.List)==0 {
+		return false
+	}
+	for _, field := range st.Fields.List {
+		if GetConVarTag(field, "cvar")=="" {
+			return false
+		}
+	}
+	return true
+}
+
+func GetConVarTag(field *ast.Field, key string) string {
+	if field.Tag==nil {
+		return ""
+	}
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	return reflect.StructTag(tag).Get(key)
+}
+
+/// "0" => 0.0, SourcePawn needs the float args of 'CreateConVar' to be float literals.
+func MakeFloatLit(value string) (*ast.BasicLit, bool) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, false
+	}
+	lit := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.ContainsAny(lit, ".e") {
+		lit += ".0"
+	}
+	return MakeBasicLit(token.FLOAT, lit), true
+}
+
+/** binds structs with 'cvar' tagged fields to ConVars with typed caches kept current by a change hook:
+ * 
+ * type Config struct {
+ *     Enabled bool `cvar:"projindic_enabled" default:"1" desc:"Enable the plugin" min:"0" max:"1"`
+ * }
+ * var cfg Config
+ * 
+ * becomes:
+ * 
+ * bool cfg_Enabled;
+ * ConVar cfg_Enabled_cvar;
+ * 
+ * public void OnPluginStart() {
+ *     cfg_Enabled_cvar = CreateConVar("projindic_enabled", "1", "Enable the plugin", FCVAR_NONE, true, 0.0, true, 1.0);
+ *     cfg_Enabled_cvar.AddChangeHook(go2sp_cvar_changed);
+ *     cfg_Enabled = cfg_Enabled_cvar.BoolValue;
+ *     AutoExecConfig(true, "", "sourcemod");
+ * }
+ * 
+ * 'cfg.Enabled' reads the cache and 'cfg.Enabled = x' sets the ConVar with 'SetBool'.
+ * 'flags:' sets the ConVar flags, 'FCVAR_NONE' otherwise.
+ */
+func MutateConVars(file *ast.File) bool {
+	cvar_structs := make(map[types.Type]*ast.StructType)
+	for i := 0; i < len(file.Decls); i++ {
+		gen_decl, is_gen := file.Decls[i].(*ast.GenDecl)
+		if !is_gen || gen_decl.Tok != token.TYPE {
+			continue
+		}
+		for n := 0; n < len(gen_decl.Specs); n++ {
+			type_spec := gen_decl.Specs[n].(*ast.TypeSpec)
+			if st, is_struct := type_spec.Type.(*ast.StructType); is_struct && IsConVarStruct(st) {
+				if obj := ASTCtxt.TypeInfo.Defs[type_spec.Name]; obj != nil {
+					cvar_structs[obj.Type()] = st
+				}
+				gen_decl.Specs = append(gen_decl.Specs[:n], gen_decl.Specs[n+1:]...)
+				n--
+			}
+		}
+		if len(gen_decl.Specs)==0 {
+			file.Decls = append(file.Decls[:i], file.Decls[i+1:]...)
+			i--
+		}
+	}
+	if len(cvar_structs)==0 {
+		return false
+	}
+	
+	type ConVarField struct {
+		cache, handle string
+		field *ast.Field
+		typ   types.Type
+	}
+	bound := make(map[*types.Var]map[string]*ConVarField)
+	fields := make([]*ConVarField, 0)
+	for i := 0; i < len(file.Decls); i++ {
+		gen_decl, is_gen := file.Decls[i].(*ast.GenDecl)
+		if !is_gen || gen_decl.Tok != token.VAR {
+			continue
+		}
+		for n := 0; n < len(gen_decl.Specs); n++ {
+			val_spec := gen_decl.Specs[n].(*ast.ValueSpec)
+			st := cvar_structs[ASTCtxt.TypeInfo.TypeOf(val_spec.Names[0])]
+			if st==nil {
+				continue
+			}
+			if len(val_spec.Values) > 0 {
+				PrintSrcGoErr(val_spec.Pos(), "ConVar structs can't be initialized, use the 'default' tags.")
+			}
+			for _, name := range val_spec.Names {
+				v := ASTCtxt.TypeInfo.Defs[name].(*types.Var)
+				bound[v] = make(map[string]*ConVarField)
+				for _, field := range st.Fields.List {
+					for _, field_name := range field.Names {
+						cvar_field := &ConVarField{ cache: name.Name + "_" + field_name.Name, handle: name.Name + "_" + field_name.Name + "_cvar", field: field, typ: ASTCtxt.TypeInfo.TypeOf(field.Type) }
+						bound[v][field_name.Name] = cvar_field
+						fields = append(fields, cvar_field)
+					}
+				}
+			}
+			gen_decl.Specs = append(gen_decl.Specs[:n], gen_decl.Specs[n+1:]...)
+			n--
+		}
+		if len(gen_decl.Specs)==0 {
+			file.Decls = append(file.Decls[:i], file.Decls[i+1:]...)
+			i--
+		}
+	}
+	
+	get_field := func(expr ast.Expr) *ConVarField {
+		sel, is_sel := expr.(*ast.SelectorExpr)
+		if !is_sel {
+			return nil
+		}
+		iden, is_ident := sel.X.(*ast.Ident)
+		if !is_ident {
+			return nil
+		}
+		if v, is_var := ASTCtxt.TypeInfo.Uses[iden].(*types.Var); is_var && bound[v] != nil {
+			return bound[v][sel.Sel.Name]
+		}
+		return nil
+	}
+	setter := func(cvar_field *ConVarField) string {
+		switch {
+			case IsStringType(cvar_field.typ):
+				return "SetString"
+			case IsBoolType(cvar_field.typ):
+				return "SetBool"
+			case IsFloatType(cvar_field.typ):
+				return "SetFloat"
+		}
+		return "SetInt"
+	}
+	
+	/// writes go to the ConVar, the change hook updates the cache.
+	ast.Inspect(file, func(n ast.Node) bool {
+		var list []ast.Stmt
+		switch x := n.(type) {
+			case *ast.BlockStmt:
+				list = x.List
+			case *ast.CaseClause:
+				list = x.Body
+			case *ast.CommClause:
+				list = x.Body
+			default:
+				return true
+		}
+		for i, stmt := range list {
+			var cvar_field *ConVarField
+			var value ast.Expr
+			switch s := stmt.(type) {
+				case *ast.AssignStmt:
+					for _, lhs := range s.Lhs {
+						if get_field(lhs) != nil && len(s.Lhs) > 1 {
+							PrintSrcGoErr(lhs.Pos(), "ConVar fields have to be set on their own.")
+						}
+					}
+					if len(s.Lhs) != 1 {
+						continue
+					}
+					if cvar_field = get_field(s.Lhs[0]); cvar_field==nil {
+						continue
+					}
+					value = s.Rhs[0]
+					if s.Tok != token.ASSIGN {
+						op := map[token.Token]token.Token{
+							token.ADD_ASSIGN: token.ADD, token.SUB_ASSIGN: token.SUB, token.MUL_ASSIGN: token.MUL,
+							token.QUO_ASSIGN: token.QUO, token.REM_ASSIGN: token.REM, token.AND_ASSIGN: token.AND,
+							token.OR_ASSIGN: token.OR, token.XOR_ASSIGN: token.XOR, token.SHL_ASSIGN: token.SHL,
+							token.SHR_ASSIGN: token.SHR, token.AND_NOT_ASSIGN: token.AND_NOT,
+						}[s.Tok]
+						value = MakeBinaryExpr(ast.NewIdent(cvar_field.cache), op, value)
+					}
+				case *ast.IncDecStmt:
+					if cvar_field = get_field(s.X); cvar_field==nil {
+						continue
+					}
+					op := token.ADD
+					if s.Tok==token.DEC {
+						op = token.SUB
+					}
+					value = MakeBinaryExpr(ast.NewIdent(cvar_field.cache), op, MakeBasicLit(token.INT, "1"))
+				default:
+					continue
+			}
+			list[i] = MakeExprStmt(MakeMethodCall(ast.NewIdent(cvar_field.handle), setter(cvar_field), value, ast.NewIdent("false"), ast.NewIdent("false")))
+		}
+		return true
+	})
+	
+	/// reads use the cache.
+	for _, decl := range file.Decls {
+		ReplaceExprs(reflect.ValueOf(decl), func(expr ast.Expr) ast.Expr {
+			if cvar_field := get_field(expr); cvar_field != nil {
+				return ast.NewIdent(cvar_field.cache)
+			}
+			return expr
+		})
+	}
+	ast.Inspect(file, func(n ast.Node) bool {
+		if iden, is_ident := n.(*ast.Ident); is_ident {
+			if v, is_var := ASTCtxt.TypeInfo.Uses[iden].(*types.Var); is_var && bound[v] != nil {
+				PrintSrcGoErr(iden.Pos(), fmt.Sprintf("ConVar struct '%s' can only be used through its fields.", iden.Name))
+			}
+		}
+		return true
+	})
+	
+	/// the caches are refreshed when their ConVar is created and changed.
+	refresh := func(cvar_field *ConVarField) ast.Stmt {
+		handle := ast.NewIdent(cvar_field.handle)
+		switch {
+			case IsStringType(cvar_field.typ):
+				return MakeExprStmt(MakeMethodCall(handle, "GetString", ast.NewIdent(cvar_field.cache), MakeCall("sizeof", ast.NewIdent(cvar_field.cache))))
+			case IsBoolType(cvar_field.typ):
+				return MakeAssignTok(ast.NewIdent(cvar_field.cache), token.ASSIGN, MakeSelector(handle, "BoolValue"))
+			case IsFloatType(cvar_field.typ):
+				return MakeAssignTok(ast.NewIdent(cvar_field.cache), token.ASSIGN, MakeSelector(handle, "FloatValue"))
+		}
+		return MakeAssignTok(ast.NewIdent(cvar_field.cache), token.ASSIGN, MakeSelector(handle, "IntValue"))
+	}
+	
+	globals := new(ast.GenDecl)
+	globals.Tok = token.VAR
+	hook := new(ast.FuncDecl)
+	hook.Name = ast.NewIdent("go2sp_cvar_changed")
+	hook.Type = new(ast.FuncType)
+	hook.Type.Params = MakeFieldList("convar", ast.NewIdent("ConVar"))
+	values := MakeFieldList("", ast.NewIdent("string"))
+	values.List[0].Names = []*ast.Ident{ast.NewIdent("old_value"), ast.NewIdent("new_value")}
+	hook.Type.Params.List = append(hook.Type.Params.List, values.List[0])
+	hook.Body = new(ast.BlockStmt)
+	
+	creates := make([]ast.Stmt, 0)
+	caches := make([]ast.Stmt, 0)
+	for _, cvar_field := range fields {
+		if !IsStringType(cvar_field.typ) && !IsCellType(cvar_field.typ) {
+			PrintSrcGoErr(cvar_field.field.Pos(), fmt.Sprintf("ConVar field '%s' has to be a bool, int, float or string.", cvar_field.cache))
+			continue
+		}
+		cache := new(ast.ValueSpec)
+		cache.Names = append(cache.Names, ast.NewIdent(cvar_field.cache))
+		cache.Type = CloneAST(cvar_field.field.Type, nil).(ast.Expr)
+		handle := new(ast.ValueSpec)
+		handle.Names = append(handle.Names, ast.NewIdent(cvar_field.handle))
+		handle.Type = ast.NewIdent("ConVar")
+		globals.Specs = append(globals.Specs, cache, handle)
+		
+		field := cvar_field.field
+		default_value := GetConVarTag(field, "default")
+		if default_value=="" && !IsStringType(cvar_field.typ) {
+			default_value = "0"
+		}
+		flags := ast.Expr(ast.NewIdent("FCVAR_NONE"))
+		if tag := GetConVarTag(field, "flags"); tag != "" {
+			if expr, err := parser.ParseExpr(tag); err==nil {
+				flags = expr
+			} else {
+				PrintSrcGoErr(field.Pos(), fmt.Sprintf("bad ConVar flags '%s'.", tag))
+			}
+		}
+		create := MakeCall("CreateConVar", MakeBasicLit(token.STRING, strconv.Quote(GetConVarTag(field, "cvar"))), MakeBasicLit(token.STRING, strconv.Quote(default_value)), MakeBasicLit(token.STRING, strconv.Quote(GetConVarTag(field, "desc"))), flags)
+		for _, bound := range []string{ "min", "max" } {
+			tag := GetConVarTag(field, bound)
+			if tag=="" {
+				create.Args = append(create.Args, ast.NewIdent("false"), MakeBasicLit(token.FLOAT, "0.0"))
+			} else if lit, ok := MakeFloatLit(tag); ok {
+				create.Args = append(create.Args, ast.NewIdent("true"), lit)
+			} else {
+				PrintSrcGoErr(field.Pos(), fmt.Sprintf("bad ConVar %s bound '%s'.", bound, tag))
+			}
+		}
+		creates = append(creates, MakeAssignTok(ast.NewIdent(cvar_field.handle), token.ASSIGN, create), MakeExprStmt(MakeMethodCall(ast.NewIdent(cvar_field.handle), "AddChangeHook", ast.NewIdent(hook.Name.Name))))
+		caches = append(caches, refresh(cvar_field))
+		
+		changed := new(ast.IfStmt)
+		changed.Cond = MakeBinaryExpr(ast.NewIdent("convar"), token.EQL, ast.NewIdent(cvar_field.handle))
+		changed.Body = new(ast.BlockStmt)
+		changed.Body.List = append(changed.Body.List, refresh(cvar_field))
+		hook.Body.List = append(hook.Body.List, changed)
+	}
+	file.Decls = append(file.Decls, globals, hook)
+	
+	start := append(creates, caches...)
+	start = append(start, MakeExprStmt(MakeCall("AutoExecConfig", ast.NewIdent("true"), MakeBasicLit(token.STRING, `""`), MakeBasicLit(token.STRING, `"sourcemod"`))))
+	for i := len(start) - 1; i >= 0; i-- {
+		AddPluginStartStmt(file, start[i])
+	}
+	return true
+}
+func MergeRetVals(file *ast.File) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n != nil {
+			switch f := n.(type) {
+				case *ast.FuncDecl:
+					var named []*ast.Ident
+					f.Type.Params.List, named = MutateRetTypes(&f.Type.Results, f.Type.Params, f.Name.Name)
+					DeclareNamedRets(f, named)
+				case *ast.TypeSpec:
+					if t, is_func_type := f.Type.(*ast.FuncType); is_func_type {
+						t.Params.List, _ = MutateRetTypes(&t.Results, t.Params, f.Name.Name)
+					}
+			}
+		}
+		return true
+	})
+}
+
+func ChangeRecvrNames(file *ast.File) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		if n != nil {
+			switch f := n.(type) {
+				case *ast.FuncDecl:
+					if f.Recv != nil && f.Recv.List[0].Names != nil && len(f.Recv.List[0].Names) > 0 {
+						recvr := f.Recv.List[0].Names[0].Name
+						/// renamed too so the final type-check still resolves the receiver.
+						f.Recv.List[0].Names[0].Name = "this"
+						ast.Inspect(f.Body, func(n ast.Node) bool {
+							if n != nil {
+								switch i := n.(type) {
+									case *ast.Ident:
+										if recvr==i.Name {
+											i.Name = "this"
+										}
+								}
+							}
+							return true
+						})
+					}
+			}
+		}
+		return true
+	})
+}
+
+func MakeFuncPtrArgCall(arg ast.Expr, by_ref bool, pretyp types.Type) *ast.ExprStmt {
+	var typ types.Type
+	if typ = ASTCtxt.TypeInfo.TypeOf(arg); typ == nil && pretyp != nil {
+		typ = pretyp
+	}
+	switch t := typ.(type) {
+		case *types.Array:
+			switch t.Elem().String() {
+				case "char":
+					Call_PushStringEx := new(ast.CallExpr)
+					Call_PushStringEx.Fun = ast.NewIdent("Call_PushStringEx")
+					Call_PushStringEx.Args = append(Call_PushStringEx.Args, arg)
+					
+					Call_PushStringEx.Args = append(Call_PushStringEx.Args, MakeBasicLit(token.INT, fmt.Sprintf("%d", t.Len())))
+					
+					Call_PushStringEx.Args = append(Call_PushStringEx.Args, MakeBasicLit(token.INT, "1"))
+					
+					Call_PushStringEx.Args = append(Call_PushStringEx.Args, MakeBasicLit(token.INT, "2"))
+					
+					Call_PushString_stmt := new(ast.ExprStmt)
+					Call_PushString_stmt.X = Call_PushStringEx
+					return Call_PushString_stmt
+				default:
+					Call_PushArrayEx := new(ast.CallExpr)
+					Call_PushArrayEx.Fun = ast.NewIdent("Call_PushArrayEx")
+					Call_PushArrayEx.Args = append(Call_PushArrayEx.Args, arg)
+					
+					Call_PushArrayEx.Args = append(Call_PushArrayEx.Args, MakeBasicLit(token.INT, fmt.Sprintf("%d", t.Len())))
+					
+					Call_PushArrayEx.Args = append(Call_PushArrayEx.Args, MakeBasicLit(token.INT, "1"))
+					
+					Call_PushArrayEx_stmt := new(ast.ExprStmt)
+					Call_PushArrayEx_stmt.X = Call_PushArrayEx
+					return Call_PushArrayEx_stmt
+			}
+		case *types.Pointer:
+			switch t.Elem().String() {
+				case "float":
+					Call_PushFloatRef := new(ast.CallExpr)
+					Call_PushFloatRef.Fun = ast.NewIdent("Call_PushFloatRef")
+					if by_ref {
+						Call_PushFloatRef.Args = append(Call_PushFloatRef.Args, MakeReference(arg))
+					} else {
+						Call_PushFloatRef.Args = append(Call_PushFloatRef.Args, arg)
+					}
+					
+					Call_PushFloatRef_stmt := new(ast.ExprStmt)
+					Call_PushFloatRef_stmt.X = Call_PushFloatRef
+					return Call_PushFloatRef_stmt
+				default:
+					Call_PushCellRef := new(ast.CallExpr)
+					Call_PushCellRef.Fun = ast.NewIdent("Call_PushCellRef")
+					if by_ref {
+						Call_PushCellRef.Args = append(Call_PushCellRef.Args, MakeReference(arg))
+					} else {
+						Call_PushCellRef.Args = append(Call_PushCellRef.Args, arg)
+					}
+					
+					Call_PushCellRef_stmt := new(ast.ExprStmt)
+					Call_PushCellRef_stmt.X = Call_PushCellRef
+					return Call_PushCellRef_stmt
+			}
+		case *types.Basic:
+			switch t.Name() {
+				case "string":
+					Call_PushString := new(ast.CallExpr)
+					Call_PushString.Fun = ast.NewIdent("Call_PushString")
+					Call_PushString.Args = append(Call_PushString.Args, arg)
+					
+					Call_PushString_stmt := new(ast.ExprStmt)
+					Call_PushString_stmt.X = Call_PushString
+					return Call_PushString_stmt
+				case "float", "float32":
+					Call_PushFloat := new(ast.CallExpr)
+					if by_ref {
+						Call_PushFloat.Fun = ast.NewIdent("Call_PushFloatRef")
+						Call_PushFloat.Args = append(Call_PushFloat.Args, MakeReference(arg))
+					} else {
+						Call_PushFloat.Fun = ast.NewIdent("Call_PushFloat")
+						Call_PushFloat.Args = append(Call_PushFloat.Args, arg)
+					}
+					Call_PushFloat_stmt := new(ast.ExprStmt)
+					Call_PushFloat_stmt.X = Call_PushFloat
+					return Call_PushFloat_stmt
+				default:
+					Call_PushCell := new(ast.CallExpr)
+					if by_ref {
+						Call_PushCell.Fun = ast.NewIdent("Call_PushCellRef")
+						Call_PushCell.Args = append(Call_PushCell.Args, MakeReference(arg))
+					} else {
+						Call_PushCell.Fun = ast.NewIdent("Call_PushCell")
+						Call_PushCell.Args = append(Call_PushCell.Args, arg)
+					}
+					Call_PushCell_stmt := new(ast.ExprStmt)
+					Call_PushCell_stmt.X = Call_PushCell
+					return Call_PushCell_stmt
+			}
+	}
+	return nil
+}
+
+func ExpandFuncPtrCalls(x *ast.CallExpr, retvals []ast.Expr, retTypes []types.Type) []ast.Stmt {
+	new_stmts := make([]ast.Stmt, 0)
+	Call_StartFunction := new(ast.CallExpr)
+	Call_StartFunction.Fun = ast.NewIdent("Call_StartFunction")
+	Call_StartFunction.Args = append(Call_StartFunction.Args, ast.NewIdent("nil"))
+	Call_StartFunction.Args = append(Call_StartFunction.Args, x.Fun)
+	
+	Call_StartFunction_stmt := new(ast.ExprStmt)
+	Call_StartFunction_stmt.X = Call_StartFunction
+	new_stmts = append(new_stmts, Call_StartFunction_stmt)
+	
+	for _, arg := range x.Args {
+		if func_call := MakeFuncPtrArgCall(arg, false, nil); func_call != nil {
+			new_stmts = append(new_stmts, func_call)
+		}
+	}
+	
+	if retvals != nil && len(retvals) > 0 {
+		rets := len(retvals)
+		for i:=1; i<rets; i++ {
+			_, is_ptr := retvals[i].(*ast.StarExpr)
+			func_call := MakeFuncPtrArgCall(retvals[i], !is_ptr, nil)
+			if func_call != nil {
+				new_stmts = append(new_stmts, func_call)
+			} else if retTypes != nil {
+				func_call = MakeFuncPtrArgCall(retvals[i], !is_ptr, retTypes[i])
+				new_stmts = append(new_stmts, func_call)
+			}
+		}
+		Call_Finish := new(ast.CallExpr)
+		Call_Finish.Fun = ast.NewIdent("Call_Finish")
+		
+		var retval_type ast.Expr
+		if field, _ := FindParam(ASTCtxt.CurrFunc, retvals[0].(*ast.Ident).Name); field != nil {
+			retval_type = field.Type
+		}
+		
+		if _, is_ptr := retval_type.(*ast.StarExpr); is_ptr {
+			Call_Finish.Args = append(Call_Finish.Args, retvals[0])
+		} else {
+			Call_Finish.Args = append(Call_Finish.Args, MakeReference(retvals[0]))
+		}
+		
+		Call_Finish_stmt := new(ast.ExprStmt)
+		Call_Finish_stmt.X = Call_Finish
+		new_stmts = append(new_stmts, Call_Finish_stmt)
+	} else {
+		Call_Finish := new(ast.CallExpr)
+		Call_Finish.Fun = ast.NewIdent("Call_Finish")
+		Call_Finish.Args = nil
+		
+		Call_Finish_stmt := new(ast.ExprStmt)
+		Call_Finish_stmt.X = Call_Finish
+		new_stmts = append(new_stmts, Call_Finish_stmt)
+	}
+	return new_stmts
+}
+
+
+type (
+	StmtMutator  func(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator)
+	BlockMutator func(b *ast.BlockStmt, mutator StmtMutator)
+)
+
+func MutateRets(file *ast.File) {
+	/** Case Studies of Returning statements to transform:
+	 * 
+	 * return m3() /// func m3() (type, type, type)
+	 * 
+	 * return int, float, m1() /// func m1() type
+	 */
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateRetStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+func MutateStmtInits(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateStmtInitStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+func MutateForInits(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateForInitStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+func MutateStructLits(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateStructLitStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+func MutateSwitches(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateSwitchStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+func MutateAssignDefs(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateAssignDefStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+func MutateAssigns(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateStrLens(d.Body)
+					MutateBlock(d.Body, MutateAssignStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+func MutateRanges(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateRangeStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+				ASTCtxt.RangeIter = 0
+		}
+	}
+}
+
+func MutateFmtCalls(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateFmtCallStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+func MutateVariadics(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					if varargs, _ := GetVariadicParam(d.Type); varargs != nil {
+						MutateBlock(d.Body, MutateVariadicStmts)
+						/// anything left over isn't something SourcePawn can do with varargs.
+						varargs_obj := ASTCtxt.TypeInfo.Defs[varargs]
+						ast.Inspect(d.Body, func(n ast.Node) bool {
+							if iden, is_ident := n.(*ast.Ident); is_ident && varargs_obj != nil && ASTCtxt.TypeInfo.Uses[iden]==varargs_obj {
+								PrintSrcGoErr(iden.Pos(), "Variadic params can only be forwarded, as '" + iden.Name + "...', to format functions.")
+							}
+							return true
+						})
+					}
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+func MutateNoRetCalls(file *ast.File) {
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateNoRetCallStmts)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+}
+
+/// a native's implementation, its signature is a 'NativeCall'.
+func IsNativeImpl(f *ast.FuncDecl) bool {
+	obj := ASTCtxt.TypeInfo.Defs[f.Name]
+	if obj==nil || f.Recv != nil {
+		return false
+	}
+	native := obj.Pkg().Scope().Lookup("NativeCall")
+	return native != nil && types.Identical(obj.Type(), native.Type().Underlying())
+}
+
+/// a literal for a constant value of a basic type, nil if it can't be made.
+func MakeConstLit(tv types.TypeAndValue) ast.Expr {
+	if tv.Value==nil || tv.Type==nil {
+		return nil
+	}
+	/// named ints are enums, their literals would lose the tag.
+	basic, is_basic := tv.Type.Underlying().(*types.Basic)
+	if _, is_plain := tv.Type.(*types.Basic); !is_basic || (!is_plain && basic.Info() & types.IsFloat==0) {
+		return nil
+	}
+	var lit ast.Expr
+	switch {
+		case basic.Info() & types.IsBoolean > 0:
+			lit = ast.NewIdent(tv.Value.ExactString())
+		case basic.Info() & types.IsString > 0:
+			lit = MakeBasicLit(token.STRING, strconv.Quote(constant.StringVal(tv.Value)))
+		case basic.Info() & types.IsFloat > 0:
+			f, _ := constant.Float64Val(constant.ToFloat(tv.Value))
+			value := strconv.FormatFloat(f, 'f', -1, 64)
+			if !strings.ContainsAny(value, ".eEIN") {
+				value += ".0"
+			}
+			lit = MakeBasicLit(token.FLOAT, value)
+		case basic.Info() & types.IsInteger > 0:
+			lit = MakeBasicLit(token.INT, tv.Value.ExactString())
+		default:
+			return nil
+	}
+	ASTCtxt.TypeInfo.Types[lit] = tv
+	return lit
+}
+
+/// (ArcTangent2(x, y) + FLOAT_PI) * (180.0 / FLOAT_PI) => (ArcTangent2(x, y) + FLOAT_PI) * 57.29577951308232
+func FoldConstants(file *ast.File) {
+	fold := func(e ast.Expr) ast.Expr {
+		switch e.(type) {
+			case *ast.BinaryExpr, *ast.UnaryExpr, *ast.ParenExpr, *ast.CallExpr:
+				if lit := MakeConstLit(ASTCtxt.TypeInfo.Types[e]); lit != nil {
+					return lit
+				}
+		}
+		return e
+	}
+	for _, decl := range file.Decls {
+		gen_decl, is_gen := decl.(*ast.GenDecl)
+		if !is_gen || gen_decl.Tok != token.CONST {
+			ReplaceExprs(reflect.ValueOf(decl).Elem(), fold)
+			continue
+		}
+		/// consts keep their names, values that implicit 'iota' specs after them repeat are kept as written.
+		for i, spec := range gen_decl.Specs {
+			val_spec := spec.(*ast.ValueSpec)
+			if i+1 < len(gen_decl.Specs) && len(gen_decl.Specs[i+1].(*ast.ValueSpec).Values)==0 {
+				continue
+			}
+			for n, value := range val_spec.Values {
+				val_spec.Values[n] = fold(value)
+			}
+		}
+	}
+}
+
+/// x & ~(~y) => x & y, !(!x) => x
+func SimplifyNegations(file *ast.File) {
+	ReplaceExprs(reflect.ValueOf(file).Elem(), func(e ast.Expr) ast.Expr {
+		outer, is_unary := e.(*ast.UnaryExpr)
+		if !is_unary {
+			return e
+		}
+		x := outer.X
+		for paren, is_paren := x.(*ast.ParenExpr); is_paren; paren, is_paren = x.(*ast.ParenExpr) {
+			x = paren.X
+		}
+		inner, is_unary := x.(*ast.UnaryExpr)
+		if !is_unary || inner.Op != outer.Op {
+			return e
+		}
+		switch outer.Op {
+			case token.XOR, token.NOT, token.SUB:
+				switch inner.X.(type) {
+					case *ast.Ident, *ast.BasicLit, *ast.CallExpr, *ast.SelectorExpr, *ast.IndexExpr, *ast.ParenExpr:
+						return inner.X
+				}
+				return MakeParenExpr(inner.X)
+		}
+		return e
+	})
+}
+
+/// drops code after a 'return', 'break', 'continue' or 'goto' and ifs with constant conditions.
+func RemoveDeadCode(file *ast.File) {
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch x := n.(type) {
+			case *ast.BlockStmt:
+				x.List = RemoveDeadStmts(x.List)
+			case *ast.CaseClause:
+				x.Body = RemoveDeadStmts(x.Body)
+		}
+		return true
+	})
+}
+
+func RemoveDeadStmts(stmts []ast.Stmt) []ast.Stmt {
+	live := make([]ast.Stmt, 0, len(stmts))
+	for i, stmt := range stmts {
+		if if_stmt, is_if := stmt.(*ast.IfStmt); is_if && if_stmt.Init==nil {
+			if tv := ASTCtxt.TypeInfo.Types[if_stmt.Cond]; tv.Value != nil && tv.Value.Kind()==constant.Bool {
+				if constant.BoolVal(tv.Value) {
+					stmt = if_stmt.Body
+				} else if if_stmt.Else != nil {
+					stmt = if_stmt.Else
+				} else {
+					continue
+				}
+			}
+		}
+		live = append(live, stmt)
+		switch stmt.(type) {
+			case *ast.ReturnStmt, *ast.BranchStmt:
+				/// a label after it can still be jumped to.
+				for _, rest := range stmts[i+1:] {
+					if _, is_label := rest.(*ast.LabeledStmt); is_label {
+						return append(live, stmts[i+1:]...)
+					}
+				}
+				return live
+		}
+	}
+	return live
+}
+
+/// forwards, natives, methods and '//go2sp:keep' functions are called by SourceMod even when the plugin doesn't.
+func IsKeptFunc(f *ast.FuncDecl) bool {
+	if _, found := GetDirective(f.Doc, "keep"); found || f.Recv != nil || f.Body==nil {
+		return true
+	}
+	if f.Name.Name=="main" || ASTCtxt.Forwards[f.Name.Name] {
+		return true
+	}
+	return IsNativeImpl(f)
+}
+
+/// drops functions and globals nothing else uses, until dropping them leaves nothing more unused.
+func RemoveUnusedDecls(file *ast.File) {
+	for {
+		uses := make(map[types.Object]int)
+		for _, decl := range file.Decls {
+			/// a function calling itself doesn't keep it around.
+			var own types.Object
+			if f, is_func := decl.(*ast.FuncDecl); is_func {
+				own = ASTCtxt.TypeInfo.Defs[f.Name]
+			}
+			ast.Inspect(decl, func(n ast.Node) bool {
+				if iden, is_ident := n.(*ast.Ident); is_ident {
+					if obj := ASTCtxt.TypeInfo.Uses[iden]; obj != nil && obj != own {
+						uses[obj]++
+					}
+				}
+				return true
+			})
+		}
+		
+		removed := false
+		decls := make([]ast.Decl, 0, len(file.Decls))
+		for _, decl := range file.Decls {
+			switch d := decl.(type) {
+				case *ast.FuncDecl:
+					if !IsKeptFunc(d) && uses[ASTCtxt.TypeInfo.Defs[d.Name]]==0 {
+						removed = true
+						continue
+					}
+				case *ast.GenDecl:
+					if d.Tok==token.CONST {
+						used := false
+						for _, spec := range d.Specs {
+							for _, name := range spec.(*ast.ValueSpec).Names {
+								used = used || uses[ASTCtxt.TypeInfo.Defs[name]] > 0
+							}
+						}
+						if !used {
+							removed = true
+							continue
+						}
+					} else if d.Tok==token.VAR {
+						specs := make([]ast.Spec, 0, len(d.Specs))
+						for _, spec := range d.Specs {
+							val_spec := spec.(*ast.ValueSpec)
+							used := false
+							for _, name := range val_spec.Names {
+								used = used || uses[ASTCtxt.TypeInfo.Defs[name]] > 0
+							}
+							/// initializers with calls might have side effects.
+							for _, value := range val_spec.Values {
+								ast.Inspect(value, func(n ast.Node) bool {
+									if call, is_call := n.(*ast.CallExpr); is_call && !IsTypeConversion(call) {
+										used = true
+									}
+									return !used
+								})
+							}
+							if used {
+								specs = append(specs, spec)
+							}
+						}
+						if len(specs)==0 {
+							removed = true
+							continue
+						} else if len(specs) < len(d.Specs) {
+							removed = true
+							d.Specs = specs
+						}
+					}
+			}
+			decls = append(decls, decl)
+		}
+		file.Decls = decls
+		if !removed {
+			return
+		}
+	}
+}
+
+func NameAnonFuncs(file *ast.File) bool {
+	/**
+	 * Function Literals can be represented in different ways:
+	 * 
+	 * f := func(params){
+	 *     code
+	 * }
+	 * f(args)
+	 * 
+	 * OR
+	 * 
+	 * func(params){
+	 *     code
+	 * }(args)
+	 * 
+	 * this runs after type-checking so the local vars given to 'go' and 'go2sp.After' literals can be captured,
+	 * returns whether any were hoisted and need type-checking again.
+	 */
+	ASTCtxt.NewDecls = make([]ast.Decl, 0)
+	ASTCtxt.FuncMap = make(map[string]*ast.FuncDecl)
+	ASTCtxt.Captures = make(map[string][]ast.Expr)
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.FuncMap[d.Name.Name] = d
+		}
+		ASTCtxt.NewDecls = append(ASTCtxt.NewDecls, decl)
+	}
+	
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.CurrFunc = d
+				if d.Body != nil {
+					MutateBlock(d.Body, MutateFuncLit)
+				}
+				ASTCtxt.CurrFunc = nil
+		}
+	}
+	hoisted := len(file.Decls) < len(ASTCtxt.NewDecls)
+	if hoisted {
+		file.Decls = ASTCtxt.NewDecls
+	}
+	ASTCtxt.NewDecls = nil
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+			case *ast.FuncDecl:
+				ASTCtxt.FuncMap[d.Name.Name] = d
+		}
+	}
+	return hoisted
+}
+
+func MutateBlock(b *ast.BlockStmt, mutator StmtMutator) {
+	/// mutators insert statements, so visit each original statement once, wherever it is now.
+	stmts := make([]ast.Stmt, len(b.List))
+	copy(stmts, b.List)
+	for _, stmt := range stmts {
+		if i := FindStmt(b.List, stmt); i != -1 {
+			mutator(&b.List, i, stmt, MutateBlock)
+		}
+	}
+}
+
+func MutateRetStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateRetStmts)
+		
+		case *ast.ForStmt:
+			if n.Init != nil {
+				MutateRetStmts(owner_list, index, n.Init, bm)
+			}
+			if n.Post != nil {
+				MutateRetStmts(owner_list, index, n.Post, bm)
+			}
+			bm(n.Body, MutateRetStmts)
+		
+		case *ast.IfStmt:
+			if n.Init != nil {
+				MutateRetStmts(owner_list, index, n.Init, bm)
+			}
+			bm(n.Body, MutateRetStmts)
+			if n.Else != nil {
+				MutateRetStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.ReturnStmt:
+			index := FindStmt(*owner_list, s)
+			res_len := len(n.Results)
+			func_calls := make([]*ast.CallExpr, 0)
+			for i := range n.Results {
+				switch call := n.Results[i].(type) {
+					case *ast.CallExpr:
+						func_calls = append(func_calls, call)
+					default:
+						func_calls = append(func_calls, nil)
+				}
+			}
+			
+			res := len(n.Results)
+			for i:=1; i<res; i++ {
+				call := func_calls[i]
+				if call != nil && IsFuncPtr(call.Fun) {
+					calls := ExpandFuncPtrCalls(call, []ast.Expr{ast.NewIdent(fmt.Sprintf("%s_param%d", ASTCtxt.CurrFunc.Name.Name, i))}, nil)
+					for i:=len(calls)-1; i>=0; i-- {
+						*owner_list = InsertStmt(*owner_list, index, calls[i])
+					}
+				} else {
+					ptr_deref := PtrizeExpr(ast.NewIdent(fmt.Sprintf("%s_param%d", ASTCtxt.CurrFunc.Name.Name, i)))
+					assign := MakeAssign(false)
+					assign.Lhs = append(assign.Lhs, ptr_deref)
+					assign.Rhs = append(assign.Rhs, n.Results[i])
+					*owner_list = InsertStmt(*owner_list, index, assign)
+					index++
+				}
+			}
+			
+			if res_len > 1 {
+				n.Results = n.Results[:1]
+				MutateRetStmts(owner_list, index, s, bm)
+			} else if res_len==1 {
+				/// check for function call.
+				ast.Inspect(n.Results[0], func(node ast.Node) bool {
+					if node != nil {
+						switch call := node.(type) {
+							case *ast.CallExpr:
+								if IsFuncPtr(call.Fun) {
+									ret_tmp := ast.NewIdent(fmt.Sprintf("fptr_temp%d", ASTCtxt.TmpVar))
+									ASTCtxt.TmpVar++
+									declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, n.Results[0], nil)
+									calls := ExpandFuncPtrCalls(call, []ast.Expr{ret_tmp}, nil)
+									calls = InsertStmt(calls, 0, declstmt)
+									for i:=len(calls)-1; i>=0; i-- {
+										*owner_list = InsertStmt(*owner_list, index, calls[i])
+									}
+									n.Results[0] = ret_tmp
+								} else if fn, found := ASTCtxt.FuncMap[GetFuncName(call.Fun)]; found {
+									if arg_count, param_count := len(call.Args), len(fn.Type.Params.List); arg_count < param_count {
+										for _, param := range ASTCtxt.CurrFunc.Type.Params.List {
+											for _, name := range param.Names {
+												if strings.HasPrefix(name.Name, ASTCtxt.CurrFunc.Name.Name + "_param") {
+													call.Args = append(call.Args, name)
+												}
+											}
+										}
+									}
+								}
+						}
+					}
+					return true
+				})
+			}
+		
+		case *ast.SwitchStmt:
+			MutateRetStmts(owner_list, index, n.Init, bm)
+			bm(n.Body, MutateRetStmts)
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateRetStmts(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateRetStmts)
+	}
+}
+
+/// SourcePawn switches only take constant ints and can't fall through.
+func IsNativeSwitch(n *ast.SwitchStmt) bool {
+	if n.Tag==nil {
+		return false
+	}
+	if typ := ASTCtxt.TypeInfo.TypeOf(n.Tag); typ==nil || GetBasicInfoOf(typ) & types.IsInteger==0 {
+		return false
+	}
+	for _, stmt := range n.Body.List {
+		clause := stmt.(*ast.CaseClause)
+		for _, e := range clause.List {
+			if ASTCtxt.TypeInfo.Types[e].Value==nil {
+				return false
+			}
+		}
+		if HasFallthrough(clause) {
+			return false
+		}
+	}
+	return true
+}
+
+func GetBasicInfoOf(typ types.Type) types.BasicInfo {
+	if basic, is_basic := typ.Underlying().(*types.Basic); is_basic {
+		return basic.Info()
+	}
+	return 0
+}
+
+func HasFallthrough(clause *ast.CaseClause) bool {
+	if len(clause.Body)==0 {
+		return false
+	}
+	branch, is_branch := clause.Body[len(clause.Body)-1].(*ast.BranchStmt)
+	return is_branch && branch.Tok==token.FALLTHROUGH
+}
+
+/// finds an unlabeled 'tok' that belongs to the switch itself, not a loop or switch inside of it.
+func HasSwitchBranch(stmts []ast.Stmt, tok token.Token) bool {
+	found := false
+	for _, stmt := range stmts {
+		ast.Inspect(stmt, func(n ast.Node) bool {
+			switch x := n.(type) {
+				case *ast.BranchStmt:
+					if x.Tok==tok && x.Label==nil {
+						found = true
+					}
+				case *ast.ForStmt, *ast.RangeStmt, *ast.FuncLit:
+					return false
+				case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+					/// 'continue' still goes through a switch.
+					return tok==token.CONTINUE
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+/** switches that SourcePawn can't do become if-else chains, the tag is evaluated once:
+ * 
+ * switch name { case "a", "b": x() default: y() }
+ * => { if (StrEqual(name, "a") || StrEqual(name, "b")) { x(); } else { y(); } }
+ * 
+ * fallthrough picks the case first and then runs every case body in order:
+ * 
+ * switch n { case a: x(); fallthrough; case b: y() }
+ * => { int switch_case# = -1; if (n == a) switch_case# = 0; else if (n == b) switch_case# = 1;
+ *      if (switch_case# == 0) { x(); switch_case# = 1; } if (switch_case# == 1) { y(); } }
+ * 
+ * 'break' in a case is kept by running the chain inside a 'for (;;) { ... break; }'.
+ */
+func MutateSwitchStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateSwitchStmts)
+		
+		case *ast.ForStmt:
+			bm(n.Body, MutateSwitchStmts)
+		
+		case *ast.IfStmt:
+			bm(n.Body, MutateSwitchStmts)
+			if n.Else != nil {
+				MutateSwitchStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateSwitchStmts(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateSwitchStmts)
+		
+		case *ast.SwitchStmt:
+			bm(n.Body, MutateSwitchStmts)
+			if n.Init != nil || IsNativeSwitch(n) {
+				return
+			}
+			var clauses []*ast.CaseClause
+			has_fallthrough := false
+			for _, stmt := range n.Body.List {
+				clause := stmt.(*ast.CaseClause)
+				clauses = append(clauses, clause)
+				has_fallthrough = has_fallthrough || HasFallthrough(clause)
+			}
+			if n.Tag==nil && !has_fallthrough {
+				/// the generator already makes these into if-else chains.
+				return
+			}
+			i := FindStmt(*owner_list, s)
+			if i == -1 {
+				return
+			}
+			
+			var scope_list []ast.Stmt
+			tag := n.Tag
+			if tag != nil && ASTCtxt.TypeInfo.Types[tag].Value==nil {
+				if _, is_ident := tag.(*ast.Ident); !is_ident {
+					tag_tmp := ast.NewIdent(fmt.Sprintf("switch_tag%d", ASTCtxt.TmpVar))
+					ASTCtxt.TmpVar++
+					/// typed now so the string passes can see it.
+					ASTCtxt.TypeInfo.Defs[tag_tmp] = types.NewVar(tag.Pos(), nil, tag_tmp.Name, types.Default(ASTCtxt.TypeInfo.TypeOf(tag)))
+					scope_list = append(scope_list, MakeAssignTok(tag_tmp, token.DEFINE, tag))
+					tag = tag_tmp
+				}
+			}
+			tag_use := func() ast.Expr {
+				if iden, is_ident := tag.(*ast.Ident); is_ident {
+					use := ast.NewIdent(iden.Name)
+					ASTCtxt.TypeInfo.Uses[use] = ASTCtxt.TypeInfo.ObjectOf(iden)
+					return use
+				}
+				return tag
+			}
+			clause_cond := func(clause *ast.CaseClause) ast.Expr {
+				var cond ast.Expr
+				for _, e := range clause.List {
+					match := e
+					if tag != nil {
+						match = MakeBinaryExpr(tag_use(), token.EQL, e)
+					}
+					if cond==nil {
+						cond = match
+					} else {
+						cond = MakeBinaryExpr(cond, token.LOR, match)
+					}
+				}
+				return cond
+			}
+			block_of := func(stmts []ast.Stmt) *ast.BlockStmt {
+				block := new(ast.BlockStmt)
+				block.List = stmts
+				return block
+			}
+			
+			var chain_list []ast.Stmt
+			if !has_fallthrough {
+				var first, last *ast.IfStmt
+				var default_clause *ast.CaseClause
+				for _, clause := range clauses {
+					if clause.List==nil {
+						default_clause = clause
+						continue
+					}
+					if_stmt := new(ast.IfStmt)
+					if_stmt.If = clause.Case
+					if_stmt.Cond = clause_cond(clause)
+					if_stmt.Body = block_of(clause.Body)
+					if first==nil {
+						first = if_stmt
+					} else {
+						last.Else = if_stmt
+					}
+					last = if_stmt
+				}
+				if first==nil {
+					if default_clause != nil {
+						chain_list = append(chain_list, block_of(default_clause.Body))
+					}
+				} else {
+					if default_clause != nil {
+						last.Else = block_of(default_clause.Body)
+					}
+					chain_list = append(chain_list, first)
+				}
+			} else {
+				sel := fmt.Sprintf("switch_case%d", ASTCtxt.TmpVar)
+				ASTCtxt.TmpVar++
+				scope_list = append(scope_list, MakeAssignTok(ast.NewIdent(sel), token.DEFINE, MakeBasicLit(token.INT, "-1")))
+				
+				var first, last *ast.IfStmt
+				default_index := -1
+				for k, clause := range clauses {
+					if clause.List==nil {
+						default_index = k
+						continue
+					}
+					if_stmt := new(ast.IfStmt)
+					if_stmt.Cond = clause_cond(clause)
+					if_stmt.Body = block_of([]ast.Stmt{ MakeAssignTok(ast.NewIdent(sel), token.ASSIGN, MakeBasicLit(token.INT, fmt.Sprintf("%d", k))) })
+					if first==nil {
+						first = if_stmt
+					} else {
+						last.Else = if_stmt
+					}
+					last = if_stmt
+				}
+				set_default := MakeAssignTok(ast.NewIdent(sel), token.ASSIGN, MakeBasicLit(token.INT, fmt.Sprintf("%d", default_index)))
+				if first==nil {
+					scope_list = append(scope_list, set_default)
+				} else {
+					if default_index != -1 {
+						last.Else = block_of([]ast.Stmt{ set_default })
+					}
+					scope_list = append(scope_list, first)
+				}
+				
+				for k, clause := range clauses {
+					body := clause.Body
+					if HasFallthrough(clause) {
+						body[len(body)-1] = MakeAssignTok(ast.NewIdent(sel), token.ASSIGN, MakeBasicLit(token.INT, fmt.Sprintf("%d", k+1)))
+					}
+					run_case := new(ast.IfStmt)
+					run_case.If = clause.Case
+					run_case.Cond = MakeBinaryExpr(ast.NewIdent(sel), token.EQL, MakeBasicLit(token.INT, fmt.Sprintf("%d", k)))
+					run_case.Body = block_of(body)
+					chain_list = append(chain_list, run_case)
+				}
+			}
+			
+			var all_bodies []ast.Stmt
+			for _, clause := range clauses {
+				all_bodies = append(all_bodies, clause.Body...)
+			}
+			if HasSwitchBranch(all_bodies, token.BREAK) {
+				if HasSwitchBranch(all_bodies, token.CONTINUE) {
+					PrintSrcGoErr(n.Pos(), "'break' and 'continue' can't both be used in a switch that becomes an if-else chain.")
+				}
+				leave := new(ast.BranchStmt)
+				leave.TokPos = n.End()
+				leave.Tok = token.BREAK
+				once := new(ast.ForStmt)
+				once.For = n.Switch
+				once.Body = block_of(append(chain_list, leave))
+				chain_list = []ast.Stmt{ once }
+			}
+			scope_list = append(scope_list, chain_list...)
+			
+			scope := new(ast.BlockStmt)
+			scope.Lbrace = n.Pos()
+			scope.List = scope_list
+			scope.Rbrace = n.End()
+			(*owner_list)[i] = scope
+	}
+}
+
+/// whether every var made by 'a, b := x, y' has the same type.
+func IsSameTypeDefine(n *ast.AssignStmt) bool {
+	var first types.Type
+	for _, e := range n.Lhs {
+		iden, is_ident := e.(*ast.Ident)
+		if !is_ident {
+			return false
+		}
+		obj := ASTCtxt.TypeInfo.ObjectOf(iden)
+		if obj==nil {
+			return false
+		}
+		if first==nil {
+			first = obj.Type()
+		} else if !types.Identical(first, obj.Type()) {
+			return false
+		}
+	}
+	return true
+}
+
+/** for-loop headers can only declare a single type, parallel assigns need temps:
+ * 
+ * for i, j := 0, n-1; i < j; i, j = i+1, j-1 {}  => for (int i = 0, j = n - 1; i < j; i = i + 1, j = j - 1) {}
+ * for a, f := 0, 1.5; ...; a, f = f, a {}        => { int a = 0; float f = 1.5; int assign_temp#; for (; ...; assign_temp# = a, a = f, f = assign_temp#) {} }
+ */
+func MutateForInitStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateForInitStmts)
+		
+		case *ast.ForStmt:
+			if i := FindStmt(*owner_list, s); i != -1 {
+				var hoisted []ast.Stmt
+				if init, is_assign := n.Init.(*ast.AssignStmt); is_assign && len(init.Lhs) > 1 && len(init.Lhs)==len(init.Rhs) {
+					switch init.Tok {
+						case token.DEFINE:
+							if !IsSameTypeDefine(init) {
+								hoisted = append(hoisted, init)
+								n.Init = nil
+							}
+						case token.ASSIGN:
+							temps, seq := SequenceParallelAssign(init)
+							for t, tmp := range temps {
+								hoisted = append(hoisted, MakeTempDecl(tmp, seq.Rhs[t]))
+							}
+							n.Init = seq
+					}
+				}
+				if post, is_assign := n.Post.(*ast.AssignStmt); is_assign && post.Tok==token.ASSIGN && len(post.Lhs) > 1 && len(post.Lhs)==len(post.Rhs) {
+					temps, seq := SequenceParallelAssign(post)
+					for t, tmp := range temps {
+						hoisted = append(hoisted, MakeTempDecl(tmp, seq.Rhs[t]))
+					}
+					n.Post = seq
+				}
+				if len(hoisted) > 0 {
+					scope := new(ast.BlockStmt)
+					scope.Lbrace = n.Pos()
+					scope.List = append(hoisted, n)
+					scope.Rbrace = n.End()
+					(*owner_list)[i] = scope
+				}
+			}
+			bm(n.Body, MutateForInitStmts)
+		
+		case *ast.IfStmt:
+			bm(n.Body, MutateForInitStmts)
+			if n.Else != nil {
+				MutateForInitStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			bm(n.Body, MutateForInitStmts)
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateForInitStmts(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateForInitStmts)
+	}
+}
+
+/** if and switch init statements are hoisted into their own scope:
+ * 
+ * if ok := kv.JumpToKey("x"); ok {} else if n := f(); n > 0 {}
+ * 
+ * becomes
+ * 
+ * { ok := kv.JumpToKey("x"); if ok {} else { n := f(); if n > 0 {} } }
+ */
+func HoistStmtInit(s ast.Stmt) ast.Stmt {
+	var init ast.Stmt
+	switch n := s.(type) {
+		case *ast.IfStmt:
+			init, n.Init = n.Init, nil
+		case *ast.SwitchStmt:
+			init, n.Init = n.Init, nil
+	}
+	if init==nil {
+		return s
+	}
+	scope := new(ast.BlockStmt)
+	scope.Lbrace = s.Pos()
+	scope.List = []ast.Stmt{ init, s }
+	scope.Rbrace = s.End()
+	return scope
+}
+
+func MutateStmtInitStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateStmtInitStmts)
+		
+		case *ast.ForStmt:
+			bm(n.Body, MutateStmtInitStmts)
+		
+		case *ast.IfStmt:
+			if n.Init != nil {
+				if i := FindStmt(*owner_list, s); i != -1 {
+					(*owner_list)[i] = HoistStmtInit(n)
+				}
+			}
+			/// walk the else-if chain, each else-if with an init gets its own scope under the else.
+			for if_stmt := n; if_stmt != nil; {
+				bm(if_stmt.Body, MutateStmtInitStmts)
+				switch e := if_stmt.Else.(type) {
+					case *ast.IfStmt:
+						if e.Init != nil {
+							if_stmt.Else = HoistStmtInit(e)
+						}
+						if_stmt = e
+					case *ast.BlockStmt:
+						bm(e, MutateStmtInitStmts)
+						if_stmt = nil
+					default:
+						if_stmt = nil
+				}
+			}
+		
+		case *ast.SwitchStmt:
+			if n.Init != nil {
+				if i := FindStmt(*owner_list, s); i != -1 {
+					(*owner_list)[i] = HoistStmtInit(n)
+				}
+			}
+			bm(n.Body, MutateStmtInitStmts)
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateStmtInitStmts(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateStmtInitStmts)
+	}
+}
+
+func MutateAssignDefStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateAssignDefStmts)
+		
+		case *ast.ForStmt:
+			if n.Init != nil {
+				MutateAssignDefStmts(owner_list, index, n.Init, bm)
+			}
+			if n.Post != nil {
+				MutateAssignDefStmts(owner_list, index, n.Post, bm)
+			}
+			bm(n.Body, MutateAssignDefStmts)
+		
+		case *ast.IfStmt:
+			if n.Init != nil {
+				MutateAssignDefStmts(owner_list, index, n.Init, bm)
+			}
+			bm(n.Body, MutateAssignDefStmts)
+			if n.Else != nil {
+				MutateAssignDefStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			MutateAssignDefStmts(owner_list, index, n.Init, bm)
+			bm(n.Body, MutateAssignDefStmts)
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateAssignDefStmts(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateAssignDefStmts)
+		
+		case *ast.AssignStmt:
+			/**
+			 * a,b,c := f()
+			 * 
+			 * if not func ptr:
+			 *     var a,b,c type
+			 *     a = f(&b, &c)
+			 * 
+			 * if func ptr:
+			 *     var a,b,c type
+			 *     Call_StartFunction(nil, f)
+			 *     Call_PushCellRef(&b)
+			 *     Call_PushCellRef(&c)
+			 *     Call_Finish(&a)
+			 */
+			left_len, rite_len := len(n.Lhs), len(n.Rhs)
+			if rite_len==1 && left_len >= rite_len {
+				switch e := n.Rhs[0].(type) {
+					case *ast.CallExpr:
+						if IsTypeConversion(e) {
+							return
+						} else if iden, is_ident := e.Fun.(*ast.Ident); is_ident && iden.Name=="make" {
+							arg_len := len(e.Args)
+							switch {
+								case arg_len > 2:
+									PrintSrcGoErr(n.TokPos, "'make' has too many arguments.")
+								case arg_len < 2:
+									PrintSrcGoErr(n.TokPos, "'make' has too few arguments.")
+								case left_len > 1:
+									PrintSrcGoErr(n.TokPos, "'make' only returns one value.")
+							}
+							return
+						}
+						/// a func call returning multiple items as a decl + init.
+						switch n.Tok {
+							case token.DEFINE:
+								decl_stmt := new(ast.DeclStmt)
+								gen_decl := new(ast.GenDecl)
+								gen_decl.Tok = token.VAR
+								
+								/// first we get each name of a var and then map them to a type.
+								var_map := make(map[types.Type][]ast.Expr)
+								/// the types in the order they're first seen, so the decls come out the same every time.
+								var_types := make([]types.Type, 0)
+								for _, e := range n.Lhs {
+									if iden, is_ident := e.(*ast.Ident); is_ident && iden.Name=="_" {
+										/// MutateAssignStmts gives '_' outs a throwaway var.
+										continue
+									}
+									if type_expr := ASTCtxt.TypeInfo.TypeOf(e); type_expr != nil {
+										if _, seen := var_map[type_expr]; !seen {
+											var_types = append(var_types, type_expr)
+										}
+										var_map[type_expr] = append(var_map[type_expr], e)
+									} else {
+										PrintSrcGoErr(n.TokPos, "Failed to expand assignment statement.")
+									}
+								}
+								
+								for _, key := range var_types {
+									val_spec := new(ast.ValueSpec)
+									for _, name := range var_map[key] {
+										val_spec.Names = append(val_spec.Names, name.(*ast.Ident))
+									}
+									val_spec.Type = TypeToASTExpr(key)
+									gen_decl.Specs = append(gen_decl.Specs, val_spec)
+								}
+								
+								decl_stmt.Decl = gen_decl
+								*owner_list = InsertStmt(*owner_list, 0, decl_stmt)
+								n.Tok = token.ASSIGN
+						}
+				}
+			}
+	}
+}
+
+func MutateAssignStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateAssignStmts)
+		
+		case *ast.ForStmt:
+			if n.Init != nil {
+				MutateAssignStmts(owner_list, index, n.Init, bm)
+			}
+			if n.Post != nil {
+				MutateAssignStmts(owner_list, index, n.Post, bm)
+			}
+			if HasStrConcat(n.Cond) {
+				MoveForCondIntoBody(n)
+			}
+			bm(n.Body, MutateAssignStmts)
+		
+		case *ast.IfStmt:
+			if n.Init != nil {
+				MutateAssignStmts(owner_list, index, n.Init, bm)
+			}
+			MutateStrConcatExpr(owner_list, s, &n.Cond)
+			bm(n.Body, MutateAssignStmts)
+			if else_if, is_if := n.Else.(*ast.IfStmt); is_if && HasStrConcat(else_if.Cond) {
+				/// else if a + b == c => else { hoisted...; if a + b == c }
+				block := new(ast.BlockStmt)
+				block.List = append(block.List, else_if)
+				n.Else = block
+			}
+			if n.Else != nil {
+				MutateAssignStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			MutateAssignStmts(owner_list, index, n.Init, bm)
+			MutateStrConcatExpr(owner_list, s, &n.Tag)
+			bm(n.Body, MutateAssignStmts)
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateAssignStmts(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateAssignStmts)
+		
+		case *ast.ExprStmt:
+			MutateStrConcatExpr(owner_list, s, &n.X)
+		
+		case *ast.ReturnStmt:
+			for i := range n.Results {
+				MutateStrConcatExpr(owner_list, s, &n.Results[i])
+			}
+		
+		case *ast.DeclStmt:
+			/// var s string = a + b => s := a + b
+			gen_decl, is_gen := n.Decl.(*ast.GenDecl)
+			if !is_gen || gen_decl.Tok != token.VAR || len(gen_decl.Specs) != 1 {
+				break
+			}
+			val_spec := gen_decl.Specs[0].(*ast.ValueSpec)
+			if len(val_spec.Names) != 1 || len(val_spec.Values) != 1 {
+				break
+			} else if call, is_call := val_spec.Values[0].(*ast.CallExpr); !IsStringExpr(val_spec.Names[0]) && !(is_call && IsCharArrayConv(call)) {
+				break
+			}
+			if i := FindStmt(*owner_list, s); i != -1 {
+				define := MakeAssignTok(val_spec.Names[0], token.DEFINE, val_spec.Values[0])
+				define.TokPos = val_spec.Names[0].Pos()
+				(*owner_list)[i] = define
+				if !MutateStrAssign(owner_list, define) {
+					(*owner_list)[i] = s
+				}
+			}
+		
+		case *ast.AssignStmt:
+			if MutateParallelAssign(owner_list, n) || MutateStrAssign(owner_list, n) {
+				return
+			}
+			for i := range n.Rhs {
+				MutateStrConcatExpr(owner_list, s, &n.Rhs[i])
+			}
+			left_len, rite_len := len(n.Lhs), len(n.Rhs)
+			if rite_len==1 && left_len >= rite_len {
+				switch n.Tok {
+					case token.ASSIGN:
+						switch fn := n.Rhs[0].(type) {
+							case *ast.CallExpr:
+								/// a func call returning multiple items as a decl + init.
+								if IsFuncPtr(fn) {
+									ret_tmp := ast.NewIdent(fmt.Sprintf("fptr_temp%d", ASTCtxt.TmpVar))
+									ASTCtxt.TmpVar++
+									declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, n.Lhs[0], nil)
+									
+									retvals := make([]ast.Expr, 0)
+									retvals = append(retvals, ret_tmp)
+									for i:=1; i<left_len; i++ {
+										retvals = append(retvals, n.Lhs[i])
+									}
+									calls := ExpandFuncPtrCalls(fn, retvals, nil)
+									calls = InsertStmt(calls, 0, declstmt)
+									for i := len(calls)-1; i>=0; i-- {
+										*owner_list = InsertStmt(*owner_list, index, calls[i])
+									}
+									n.Lhs = n.Lhs[:1]
+									n.Rhs[0] = ret_tmp
+								} else {
+									/// '_' can't be passed by reference or assigned, it gets a throwaway var.
+									if tuple, is_tuple := ASTCtxt.TypeInfo.TypeOf(fn).(*types.Tuple); is_tuple {
+										for i:=1; i<left_len && i<tuple.Len(); i++ {
+											if iden, is_ident := n.Lhs[i].(*ast.Ident); is_ident && iden.Name=="_" {
+												discard := ast.NewIdent(fmt.Sprintf("discard_temp%d", ASTCtxt.TmpVar))
+												ASTCtxt.TmpVar++
+												*owner_list = InsertStmt(*owner_list, index, MakeVarDecl([]*ast.Ident{discard}, nil, tuple.At(i).Type()))
+												n.Lhs[i] = discard
+											}
+										}
+									}
+									/// transform the tuple return into a single return + pass by ref.
+									for i:=1; i<left_len; i++ {
+										switch e := n.Lhs[i].(type) {
+											case *ast.Ident, *ast.SelectorExpr, *ast.IndexExpr:
+												fn.Args = append(fn.Args, MakeReference(e))
+										}
+									}
+									if left_len > 1 {
+										n.Lhs = n.Lhs[:1]
+										if iden, is_ident := n.Lhs[0].(*ast.Ident); is_ident && iden.Name=="_" {
+											/// the outs are passed already, MutateNoRetCalls mustn't add more.
+											delete(ASTCtxt.TypeInfo.Types, fn)
+											(*owner_list)[FindStmt(*owner_list, n)] = MakeExprStmt(fn)
+										}
+									}
+								}
+							/*
+							case *ast.IndexExpr:
+								/// value, found = map[str]
+								/// Becomes: found = map.GetValue(str, &value)
+								if typ := ASTCtxt.TypeInfo.TypeOf(fn.X); typ != nil {
+									switch t := typ.(type) {
+										case *types.Map:
+											ret_tmp := ast.NewIdent(fmt.Sprintf("map_value%d", ASTCtxt.TmpVar))
+											ASTCtxt.TmpVar++
+											declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, nil, t.Elem())
+											
+											new_stmts := make([]ast.Stmt, 0)
+											map_access := new(ast.CallExpr)
+											switch elem_typ := t.Elem().(type) {
+												case 
+												map_access.Fun = ast.NewIdent("GetValue")
+											
+											map_access.Args = append(Call_StartFunction.Args, ast.NewIdent("nil"))
+											map_access.Args = append(Call_StartFunction.Args, x.Fun)
+								}
+							*/
+						}
 				}
-				ASTCtxt.CurrFunc = nil
+			}
+	}
+}
+
+/// len(string) => strlen(string), done before strings are turned into char buffers.
+func MutateStrLens(body *ast.BlockStmt) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		if n != nil {
+			if call, is_call := n.(*ast.CallExpr); is_call && len(call.Args)==1 {
+				if iden, is_ident := call.Fun.(*ast.Ident); is_ident && iden.Name=="len" && IsBasicStringExpr(call.Args[0]) {
+					call.Fun = ast.NewIdent("strlen")
+				}
+			}
 		}
+		return true
+	})
+}
+
+/** a, b = b, a        => int assign_temp# = b; a = b; b = assign_temp#;
+ * arr[i], arr[j] = arr[j], arr[i] => same, since both sides index 'arr'.
+ * s1, s2 = s2, s1     => char assign_temp#[N]; strcopy(assign_temp#, sizeof(assign_temp#), s1); ...
+ * 
+ * temps are only made for values that read a var assigned before them.
+ */
+func MutateParallelAssign(owner_list *[]ast.Stmt, n *ast.AssignStmt) bool {
+	if n.Tok != token.ASSIGN || len(n.Lhs) < 2 || len(n.Lhs) != len(n.Rhs) {
+		return false
 	}
-	if len(file.Decls) < len(ASTCtxt.NewDecls) {
-		file.Decls = ASTCtxt.NewDecls
+	index := FindStmt(*owner_list, n)
+	if index == -1 {
+		/// for-loop posts are handled by MutateForInits.
+		return false
 	}
-	ASTCtxt.NewDecls = nil
-	for _, decl := range file.Decls {
-		switch d := decl.(type) {
-			case *ast.FuncDecl:
-				ASTCtxt.FuncMap[d.Name.Name] = d
+	
+	temps, seq := SequenceParallelAssign(n)
+	var new_stmts []ast.Stmt
+	for i := range seq.Lhs {
+		assign := MakeAssign(false)
+		assign.TokPos = n.TokPos
+		assign.Lhs = append(assign.Lhs, seq.Lhs[i])
+		assign.Rhs = append(assign.Rhs, seq.Rhs[i])
+		if i < len(temps) {
+			typ := ASTCtxt.TypeInfo.TypeOf(seq.Rhs[i])
+			if typ != nil && IsStringType(typ) {
+				buf_decl := MakeStrBufDecl(temps[i])
+				if arr, is_arr := types.Unalias(typ).(*types.Array); is_arr {
+					/// a '[N]char' temp keeps its 'N' so nothing is truncated.
+					buf_decl.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Type = MakeTypeArgExpr(arr)
+				}
+				new_stmts = append(new_stmts, buf_decl)
+				new_stmts = append(new_stmts, MakeExprStmt(MakeCall("strcopy", ast.NewIdent(temps[i].Name), MakeCall("sizeof", ast.NewIdent(temps[i].Name)), seq.Rhs[i])))
+				continue
+			} else if typ != nil {
+				switch typ.Underlying().(type) {
+					case *types.Array, *types.Struct:
+						/// arrays and enum structs can't be initialized from a var.
+						new_stmts = append(new_stmts, MakeTempDecl(temps[i], seq.Rhs[i]))
+						assign.Lhs[0] = ast.NewIdent(temps[i].Name)
+						new_stmts = append(new_stmts, assign)
+						continue
+				}
+			}
+			assign.Tok = token.DEFINE
 		}
+		new_stmts = append(new_stmts, assign)
 	}
+	
+	(*owner_list)[index] = new_stmts[0]
+	for i:=1; i<len(new_stmts); i++ {
+		*owner_list = InsertStmt(*owner_list, index + i, new_stmts[i])
+	}
+	/// each single assign can now be a string copy.
+	for _, stmt := range new_stmts {
+		if assign, is_assign := stmt.(*ast.AssignStmt); is_assign && assign.Tok==token.ASSIGN {
+			MutateStrAssign(owner_list, assign)
+		}
+	}
+	return true
 }
 
-func MutateBlock(b *ast.BlockStmt, mutator StmtMutator) {
-	for i, stmt := range b.List {
-		mutator(&b.List, i, stmt, MutateBlock)
+
+/**
+ * s := a + b   => var s [StrBufLen]char; Format(s, sizeof(s), "%s%s", a, b)
+ * s := a       => var s [StrBufLen]char; strcopy(s, sizeof(s), a)
+ * s = a + b    => Format(s, sizeof(s), "%s%s", a, b)
+ * s = a        => strcopy(s, sizeof(s), a)
+ * s += a       => StrCat(s, sizeof(s), a)
+ * s += a + b   => Format(s, sizeof(s), "%s%s%s", s, a, b)
+ */
+func MutateStrAssign(owner_list *[]ast.Stmt, n *ast.AssignStmt) bool {
+	if len(n.Lhs) != 1 || len(n.Rhs) != 1 {
+		return false
+	} else if call, is_call := n.Rhs[0].(*ast.CallExpr); is_call && IsCharArrayConv(call) {
+		/// s := string(buf) doesn't type-check, it's a copy of 'buf'.
+		n.Rhs[0] = call.Args[0]
+	} else if !IsStringExpr(n.Lhs[0]) {
+		return false
+	} else if is_call && IsFuncPtr(call) {
+		return false
+	}
+	
+	index := FindStmt(*owner_list, n)
+	if index == -1 {
+		return false
+	}
+	
+	dest, value := n.Lhs[0], n.Rhs[0]
+	var new_stmts []ast.Stmt
+	switch n.Tok {
+		case token.DEFINE:
+			iden, is_ident := dest.(*ast.Ident)
+			if !is_ident || iden.Name=="_" || (ASTCtxt.TypeInfo.Types[value].Value != nil && !IsReassigned(iden)) {
+				/// constant strings are fine as 'char s[] = "str";' unless a longer one can be copied in later.
+				return false
+			}
+			new_stmts = append(new_stmts, MakeStrBufDecl(iden))
+			dest = ast.NewIdent(iden.Name)
+			fallthrough
+		
+		case token.ASSIGN:
+			if n.Tok==token.ASSIGN && !IsStringBufExpr(dest) {
+				return false
+			}
+			if IsStrConcat(value) {
+				new_stmts = append(new_stmts, MakeStrFormat(dest, FlattenStrConcat(value, nil)))
+			} else {
+				MutateStrConcatExpr(owner_list, n, &n.Rhs[0])
+				new_stmts = append(new_stmts, MakeExprStmt(MakeCall("strcopy", dest, MakeCall("sizeof", dest), n.Rhs[0])))
+			}
+		
+		case token.ADD_ASSIGN:
+			if !IsStringBufExpr(dest) {
+				return false
+			}
+			if IsStrConcat(value) {
+				new_stmts = append(new_stmts, MakeStrFormat(dest, FlattenStrConcat(value, []ast.Expr{dest})))
+			} else {
+				MutateStrConcatExpr(owner_list, n, &n.Rhs[0])
+				new_stmts = append(new_stmts, MakeExprStmt(MakeCall("StrCat", dest, MakeCall("sizeof", dest), n.Rhs[0])))
+			}
+		
+		default:
+			return false
+	}
+	
+	/// hoisting may have shifted us.
+	index = FindStmt(*owner_list, n)
+	(*owner_list)[index] = new_stmts[0]
+	for i:=1; i<len(new_stmts); i++ {
+		*owner_list = InsertStmt(*owner_list, index + i, new_stmts[i])
 	}
+	return true
 }
 
-func MutateRetStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+/// string(buf) where 'buf' is a '[N]char'.
+func IsCharArrayConv(call *ast.CallExpr) bool {
+	iden, is_ident := call.Fun.(*ast.Ident)
+	if !is_ident || len(call.Args) != 1 || ASTCtxt.TypeInfo.Uses[iden] != types.Universe.Lookup("string") {
+		return false
+	}
+	arr, is_arr := types.Unalias(ASTCtxt.TypeInfo.TypeOf(call.Args[0])).(*types.Array)
+	return is_arr && IsCharType(arr.Elem())
+}
+
+/// whether the local defined by 'iden' is assigned again or has its address taken in the current function.
+func IsReassigned(iden *ast.Ident) bool {
+	obj := ASTCtxt.TypeInfo.Defs[iden]
+	if obj==nil || ASTCtxt.CurrFunc==nil || ASTCtxt.CurrFunc.Body==nil {
+		return false
+	}
+	uses := func(e ast.Expr) bool {
+		used, is_ident := e.(*ast.Ident)
+		return is_ident && ASTCtxt.TypeInfo.Uses[used]==obj
+	}
+	found := false
+	ast.Inspect(ASTCtxt.CurrFunc.Body, func(n ast.Node) bool {
+		switch x := n.(type) {
+			case *ast.AssignStmt:
+				for _, lhs := range x.Lhs {
+					found = found || uses(lhs)
+				}
+			case *ast.UnaryExpr:
+				found = found || (x.Op==token.AND && uses(x.X))
+		}
+		return !found
+	})
+	return found
+}
+
+/// whether a string concatenation is anywhere in 'n'.
+func HasStrConcat(n ast.Node) bool {
+	found := false
+	if n != nil && !reflect.ValueOf(n).IsNil() {
+		ast.Inspect(n, func(n ast.Node) bool {
+			if expr, is_expr := n.(ast.Expr); is_expr && IsStrConcat(expr) {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+/// f(a + b) => var str_temp# [StrBufLen]char; Format(str_temp#, sizeof(str_temp#), "%s%s", a, b); f(str_temp#)
+func MutateStrConcatExpr(owner_list *[]ast.Stmt, s ast.Stmt, e *ast.Expr) {
+	if e==nil || *e == nil {
+		return
+	}
+	switch n := (*e).(type) {
+		case *ast.BinaryExpr:
+			if IsStrConcat(n) {
+				index := FindStmt(*owner_list, s)
+				if index == -1 {
+					return
+				}
+				str_tmp := ast.NewIdent(fmt.Sprintf("str_temp%d", ASTCtxt.TmpVar))
+				ASTCtxt.TmpVar++
+				*owner_list = InsertStmt(*owner_list, index, MakeStrBufDecl(str_tmp))
+				*owner_list = InsertStmt(*owner_list, index + 1, MakeStrFormat(ast.NewIdent(str_tmp.Name), FlattenStrConcat(n, nil)))
+				/// the temp keeps the type of the concat, so comparisons with it are still lowered.
+				tmp := ast.NewIdent(str_tmp.Name)
+				tmp.NamePos = n.Pos()
+				ASTCtxt.TypeInfo.Types[tmp] = types.TypeAndValue{Type: types.Typ[types.String]}
+				*e = tmp
+			} else {
+				MutateStrConcatExpr(owner_list, s, &n.X)
+				MutateStrConcatExpr(owner_list, s, &n.Y)
+			}
+		
+		case *ast.CallExpr:
+			for i := range n.Args {
+				MutateStrConcatExpr(owner_list, s, &n.Args[i])
+			}
+		
+		case *ast.ParenExpr:
+			MutateStrConcatExpr(owner_list, s, &n.X)
+		
+		case *ast.UnaryExpr:
+			MutateStrConcatExpr(owner_list, s, &n.X)
+		
+		case *ast.IndexExpr:
+			MutateStrConcatExpr(owner_list, s, &n.Index)
+	}
+}
+
+func MutateRangeStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
 	switch n := s.(type) {
 		case *ast.BlockStmt:
-			bm(n, MutateRetStmts)
+			bm(n, MutateRangeStmts)
+		
+		case *ast.EmptyStmt:
 		
 		case *ast.ForStmt:
 			if n.Init != nil {
-				MutateRetStmts(owner_list, index, n.Init, bm)
+				MutateRangeStmts(owner_list, index, n.Init, bm)
 			}
 			if n.Post != nil {
-				MutateRetStmts(owner_list, index, n.Post, bm)
+				MutateRangeStmts(owner_list, index, n.Post, bm)
 			}
-			bm(n.Body, MutateRetStmts)
+			bm(n.Body, MutateRangeStmts)
 		
 		case *ast.IfStmt:
 			if n.Init != nil {
-				MutateRetStmts(owner_list, index, n.Init, bm)
+				MutateRangeStmts(owner_list, index, n.Init, bm)
 			}
-			bm(n.Body, MutateRetStmts)
+			bm(n.Body, MutateRangeStmts)
 			if n.Else != nil {
-				MutateRetStmts(owner_list, index, n.Else, bm)
+				MutateRangeStmts(owner_list, index, n.Else, bm)
 			}
 		
-		case *ast.ReturnStmt:
-			index := FindStmt(*owner_list, s)
-			res_len := len(n.Results)
-			func_calls := make([]*ast.CallExpr, 0)
-			for i := range n.Results {
-				switch call := n.Results[i].(type) {
-					case *ast.CallExpr:
-						func_calls = append(func_calls, call)
-					default:
-						func_calls = append(func_calls, nil)
+		case *ast.SwitchStmt:
+			MutateRangeStmts(owner_list, index, n.Init, bm)
+			bm(n.Body, MutateRangeStmts)
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateRangeStmts(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			if MutateRangeKinds(owner_list, n) {
+				bm(n.Body, MutateRangeStmts)
+				return
+			}
+			if n.Key != nil {
+				if iden, ok := n.Key.(*ast.Ident); ok && iden.Name=="_" {
+					n.Key = ast.NewIdent(fmt.Sprintf("%s_iter%d", ASTCtxt.CurrFunc.Name.Name, ASTCtxt.RangeIter))
+					ASTCtxt.RangeIter++
 				}
+			} else {
+				n.Key = ast.NewIdent(fmt.Sprintf("%s_iter%d", ASTCtxt.CurrFunc.Name.Name, ASTCtxt.RangeIter))
+				ASTCtxt.RangeIter++
 			}
 			
-			res := len(n.Results)
-			for i:=1; i<res; i++ {
-				call := func_calls[i]
-				if call != nil && IsFuncPtr(call.Fun) {
-					calls := ExpandFuncPtrCalls(call, []ast.Expr{ast.NewIdent(fmt.Sprintf("%s_param%d", ASTCtxt.CurrFunc.Name.Name, i))}, nil)
-					for i:=len(calls)-1; i>=0; i-- {
-						*owner_list = InsertStmt(*owner_list, index, calls[i])
-					}
+			if n.Value != nil {
+				if iden, ok := n.Value.(*ast.Ident); ok && iden.Name=="_" {
+					n.Value = nil
 				} else {
-					ptr_deref := PtrizeExpr(ast.NewIdent(fmt.Sprintf("%s_param%d", ASTCtxt.CurrFunc.Name.Name, i)))
-					assign := MakeAssign(false)
-					assign.Lhs = append(assign.Lhs, ptr_deref)
-					assign.Rhs = append(assign.Rhs, n.Results[i])
-					*owner_list = InsertStmt(*owner_list, index, assign)
-					index++
+					switch n.Tok {
+						case token.DEFINE:
+							decl_stmt := MakeVarDecl([]*ast.Ident{n.Value.(*ast.Ident)}, n.Value, nil)
+							n.Body.List = InsertStmt(n.Body.List, 0, decl_stmt)
+							
+							assign := MakeAssign(false)
+							assign.Lhs = append(assign.Lhs, n.Value)
+							
+							get_index := MakeIndex(n.Key, n.X)
+							assign.Rhs = append(assign.Rhs, get_index)
+							
+							n.Body.List = InsertStmt(n.Body.List, 1, assign)
+							n.Value = nil
+					}
 				}
 			}
-			
-			if res_len > 1 {
-				n.Results = n.Results[:1]
-				MutateRetStmts(owner_list, index, s, bm)
-			} else if res_len==1 {
-				/// check for function call.
-				ast.Inspect(n.Results[0], func(node ast.Node) bool {
-					if node != nil {
-						switch call := node.(type) {
-							case *ast.CallExpr:
-								if IsFuncPtr(call.Fun) {
-									ret_tmp := ast.NewIdent(fmt.Sprintf("fptr_temp%d", ASTCtxt.TmpVar))
-									ASTCtxt.TmpVar++
-									declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, n.Results[0], nil)
-									calls := ExpandFuncPtrCalls(call, []ast.Expr{ret_tmp}, nil)
-									calls = InsertStmt(calls, 0, declstmt)
-									for i:=len(calls)-1; i>=0; i-- {
-										*owner_list = InsertStmt(*owner_list, index, calls[i])
-									}
-									n.Results[0] = ret_tmp
-								} else if fn, found := ASTCtxt.FuncMap[GetFuncName(call.Fun)]; found {
-									if arg_count, param_count := len(call.Args), len(fn.Type.Params.List); arg_count < param_count {
-										for _, param := range ASTCtxt.CurrFunc.Type.Params.List {
-											for _, name := range param.Names {
-												if strings.HasPrefix(name.Name, ASTCtxt.CurrFunc.Name.Name + "_param") {
-													call.Args = append(call.Args, name)
-												}
-											}
-										}
-									}
-								}
-						}
+			bm(n.Body, MutateRangeStmts)
+	}
+}
+
+/** ranges over non-arrays become for-loops:
+ * 
+ * for i := range n {}        => for (int i = 0; i < n; i++) {}
+ * for i, r := range str {}   => int range_step#; for (int i = 0; str[i] != 0; i += range_step#) { range_step# = IsCharMB(str[i]); ... }
+ *                               a string literal is strcopy'd into a 'char range_str#[N]' first.
+ * for i, v := range list {}  => for (int i = 0; i < list.Length; i++) { any v = list.Get(i, 0, false); }
+ * for k, v := range smap {}  => StringMapSnapshot range_snap# = smap.Snapshot();
+ *                               for (int range_index# = 0; range_index# < range_snap#.Length; range_index#++) {
+ *                                   char k[N]; range_snap#.GetKey(range_index#, k, sizeof(k));
+ *                                   any v; smap.GetValue(k, v);
+ *                               }
+ *                               delete range_snap#;
+ */
+func MutateRangeKinds(owner_list *[]ast.Stmt, n *ast.RangeStmt) bool {
+	typ := ASTCtxt.TypeInfo.TypeOf(n.X)
+	if typ==nil {
+		return false
+	}
+	var type_name string
+	if named, is_named := types.Unalias(typ).(*types.Named); is_named {
+		type_name = named.Obj().Name()
+	}
+	basic, is_basic := typ.Underlying().(*types.Basic)
+	is_int := is_basic && basic.Info() & types.IsInteger > 0 && type_name != "char"
+	if type_name != "StringMap" && type_name != "ArrayList" && !is_int && !IsStringType(typ) {
+		return false
+	}
+	
+	index := FindStmt(*owner_list, n)
+	if index == -1 {
+		return false
+	}
+	
+	define := n.Tok==token.DEFINE
+	key, value := n.Key, n.Value
+	if iden, is_ident := key.(*ast.Ident); is_ident && iden.Name=="_" {
+		key = nil
+	}
+	if iden, is_ident := value.(*ast.Ident); is_ident && iden.Name=="_" {
+		value = nil
+	}
+	
+	/// 'tok' for vars from the range clause, new vars are always defined.
+	bind := func(lhs ast.Expr, rhs ast.Expr, is_new bool) *ast.AssignStmt {
+		if is_new || define {
+			return MakeAssignTok(lhs, token.DEFINE, rhs)
+		}
+		return MakeAssignTok(lhs, token.ASSIGN, rhs)
+	}
+	
+	iter, new_iter := key, false
+	if iter==nil || type_name=="StringMap" {
+		iter, new_iter = ast.NewIdent(fmt.Sprintf("%s_iter%d", ASTCtxt.CurrFunc.Name.Name, ASTCtxt.RangeIter)), true
+		ASTCtxt.RangeIter++
+	}
+	
+	for_stmt := new(ast.ForStmt)
+	for_stmt.For = n.For
+	for_stmt.Body = n.Body
+	for_stmt.Init = bind(iter, MakeBasicLit(token.INT, "0"), new_iter)
+	inc := new(ast.IncDecStmt)
+	inc.X = ast.NewIdent(GetFuncName(iter))
+	inc.Tok = token.INC
+	for_stmt.Post = inc
+	
+	var before, each, after []ast.Stmt
+	switch {
+		case type_name=="StringMap":
+			snap := ast.NewIdent(fmt.Sprintf("range_snap%d", ASTCtxt.TmpVar))
+			ASTCtxt.TmpVar++
+			before = append(before, MakeAssignTok(snap, token.DEFINE, MakeMethodCall(n.X, "Snapshot")))
+			for_stmt.Cond = MakeBinaryExpr(ast.NewIdent(GetFuncName(iter)), token.LSS, MakeSelector(snap, "Length"))
+			
+			if key != nil || value != nil {
+				key_buf := key
+				if key_buf==nil {
+					key_buf = ast.NewIdent(fmt.Sprintf("range_key%d", ASTCtxt.TmpVar))
+					ASTCtxt.TmpVar++
+					each = append(each, MakeStrBufDecl(key_buf.(*ast.Ident)))
+				} else if define {
+					each = append(each, MakeStrBufDecl(key_buf.(*ast.Ident)))
+				}
+				each = append(each, MakeExprStmt(MakeMethodCall(snap, "GetKey", ast.NewIdent(GetFuncName(iter)), CloneLvalue(key_buf), MakeCall("sizeof", CloneLvalue(key_buf)))))
+				if value != nil {
+					if define {
+						each = append(each, MakeAnyDecl(value.(*ast.Ident)))
 					}
-					return true
-				})
+					each = append(each, MakeExprStmt(MakeMethodCall(n.X, "GetValue", CloneLvalue(key_buf), MakeReference(CloneLvalue(value)))))
+				}
+			}
+			/// snapshots are handles, every way out of the loop has to delete it.
+			delete_snap := func() ast.Stmt {
+				return MakeExprStmt(MakeCall("__sp__", MakeBasicLit(token.STRING, `"delete ` + snap.Name + `;"`)))
 			}
+			after = append(after, delete_snap())
+			InsertBeforeExits(&n.Body.List, CollectLabels(n.Body), delete_snap)
 		
-		case *ast.SwitchStmt:
-			MutateRetStmts(owner_list, index, n.Init, bm)
-			bm(n.Body, MutateRetStmts)
+		case type_name=="ArrayList":
+			for_stmt.Cond = MakeBinaryExpr(ast.NewIdent(GetFuncName(iter)), token.LSS, MakeSelector(n.X, "Length"))
+			if value != nil {
+				each = append(each, bind(value, MakeMethodCall(n.X, "Get", ast.NewIdent(GetFuncName(iter)), MakeBasicLit(token.INT, "0"), ast.NewIdent("false")), false))
+			}
 		
-		case *ast.CaseClause:
-			for i, stmt := range n.Body {
-				MutateRetStmts(&n.Body, i, stmt, bm)
+		case is_int:
+			bound := n.X
+			if ASTCtxt.TypeInfo.Types[n.X].Value==nil {
+				if _, is_ident := n.X.(*ast.Ident); !is_ident {
+					/// Go evaluates the range expression once.
+					bound = ast.NewIdent(fmt.Sprintf("range_len%d", ASTCtxt.TmpVar))
+					ASTCtxt.TmpVar++
+					before = append(before, MakeAssignTok(bound, token.DEFINE, n.X))
+					bound = ast.NewIdent(bound.(*ast.Ident).Name)
+				}
 			}
+			for_stmt.Cond = MakeBinaryExpr(ast.NewIdent(GetFuncName(iter)), token.LSS, bound)
 		
-		case *ast.RangeStmt:
-			bm(n.Body, MutateRetStmts)
+		default:
+			/// strings end at the NUL, multibyte chars are stepped over with IsCharMB.
+			step := fmt.Sprintf("range_step%d", ASTCtxt.TmpVar)
+			ASTCtxt.TmpVar++
+			before = append(before, MakeAssignTok(ast.NewIdent(step), token.DEFINE, MakeBasicLit(token.INT, "0")))
+			str := n.X
+			if tv := ASTCtxt.TypeInfo.Types[n.X]; tv.Value != nil && tv.Value.Kind()==constant.String {
+				if _, is_ident := n.X.(*ast.Ident); !is_ident {
+					/// string literals can't be indexed, they're copied into a buffer first.
+					buf := ast.NewIdent(fmt.Sprintf("range_str%d", ASTCtxt.TmpVar))
+					ASTCtxt.TmpVar++
+					buf_decl := MakeStrBufDecl(buf)
+					buf_decl.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Type = Arrayify(ast.NewIdent("char"), MakeBasicLit(token.INT, strconv.Itoa(len(constant.StringVal(tv.Value)) + 1)))
+					before = append(before, buf_decl, MakeExprStmt(MakeCall("strcopy", ast.NewIdent(buf.Name), MakeCall("sizeof", ast.NewIdent(buf.Name)), n.X)))
+					str = ast.NewIdent(buf.Name)
+				}
+			}
+			curr_char := func(offset ast.Expr) ast.Expr {
+				if offset==nil {
+					return MakeIndex(ast.NewIdent(GetFuncName(iter)), CloneLvalue(str))
+				}
+				return MakeIndex(MakeBinaryExpr(ast.NewIdent(GetFuncName(iter)), token.ADD, offset), CloneLvalue(str))
+			}
+			for_stmt.Cond = MakeBinaryExpr(curr_char(nil), token.NEQ, MakeBasicLit(token.INT, "0"))
+			for_stmt.Post = MakeAssignTok(ast.NewIdent(GetFuncName(iter)), token.ADD_ASSIGN, ast.NewIdent(step))
+			
+			each = append(each, MakeAssignTok(ast.NewIdent(step), token.ASSIGN, MakeCall("IsCharMB", MakeCall("int", curr_char(nil)))))
+			single_byte := new(ast.IfStmt)
+			single_byte.Cond = MakeBinaryExpr(ast.NewIdent(step), token.EQL, MakeBasicLit(token.INT, "0"))
+			single_byte.Body = new(ast.BlockStmt)
+			single_byte.Body.List = append(single_byte.Body.List, MakeAssignTok(ast.NewIdent(step), token.ASSIGN, MakeBasicLit(token.INT, "1")))
+			each = append(each, single_byte)
+			
+			if value != nil {
+				/// 'rune' is 'int32', which becomes 'int'.
+				rune_type := types.Typ[types.Int32]
+				if val_type, is_basic := ASTCtxt.TypeInfo.TypeOf(value).(*types.Basic); is_basic && val_type.Info() & types.IsInteger > 0 {
+					rune_type = types.Typ[val_type.Kind()]
+				}
+				to_rune := func(x ast.Expr) ast.Expr {
+					conv := new(ast.CallExpr)
+					conv.Fun = ast.NewIdent(rune_type.Name())
+					conv.Args = append(conv.Args, x)
+					return conv
+				}
+				each = append(each, bind(value, to_rune(curr_char(nil)), false))
+				
+				/// decode the UTF-8 lead byte and its continuation bytes.
+				decode := new(ast.IfStmt)
+				decode.Cond = MakeBinaryExpr(ast.NewIdent(step), token.GTR, MakeBasicLit(token.INT, "1"))
+				decode.Body = new(ast.BlockStmt)
+				lead_mask := MakeBinaryExpr(MakeBasicLit(token.INT, "0xFF"), token.SHR, MakeParenExpr(MakeBinaryExpr(ast.NewIdent(step), token.ADD, MakeBasicLit(token.INT, "1"))))
+				decode.Body.List = append(decode.Body.List, MakeAssignTok(CloneLvalue(value), token.AND_ASSIGN, lead_mask))
+				
+				cont := fmt.Sprintf("range_byte%d", ASTCtxt.TmpVar)
+				ASTCtxt.TmpVar++
+				cont_loop := new(ast.ForStmt)
+				cont_loop.Init = MakeAssignTok(ast.NewIdent(cont), token.DEFINE, MakeBasicLit(token.INT, "1"))
+				cont_loop.Cond = MakeBinaryExpr(ast.NewIdent(cont), token.LSS, ast.NewIdent(step))
+				cont_inc := new(ast.IncDecStmt)
+				cont_inc.X = ast.NewIdent(cont)
+				cont_inc.Tok = token.INC
+				cont_loop.Post = cont_inc
+				cont_loop.Body = new(ast.BlockStmt)
+				cont_bits := MakeParenExpr(MakeBinaryExpr(curr_char(ast.NewIdent(cont)), token.AND, MakeBasicLit(token.INT, "0x3F")))
+				shifted := MakeParenExpr(MakeBinaryExpr(CloneLvalue(value), token.SHL, MakeBasicLit(token.INT, "6")))
+				cont_loop.Body.List = append(cont_loop.Body.List, MakeAssignTok(CloneLvalue(value), token.ASSIGN, MakeBinaryExpr(shifted, token.OR, to_rune(cont_bits))))
+				decode.Body.List = append(decode.Body.List, cont_loop)
+				each = append(each, decode)
+			}
 	}
+	
+	n.Body.List = append(each, n.Body.List...)
+	var replacement ast.Stmt = for_stmt
+	if len(before) > 0 || len(after) > 0 {
+		scope := new(ast.BlockStmt)
+		scope.Lbrace = n.Pos()
+		scope.List = append(append(before, for_stmt), after...)
+		scope.Rbrace = n.End()
+		replacement = scope
+	}
+	(*owner_list)[index] = replacement
+	return true
 }
 
-func MutateAssignDefStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
-	switch n := s.(type) {
-		case *ast.BlockStmt:
-			bm(n, MutateAssignDefStmts)
-		
-		case *ast.ForStmt:
-			if n.Init != nil {
-				MutateAssignDefStmts(owner_list, index, n.Init, bm)
-			}
-			if n.Post != nil {
-				MutateAssignDefStmts(owner_list, index, n.Post, bm)
+/// the labels declared inside a statement, not counting func literals.
+func CollectLabels(body ast.Node) map[string]bool {
+	labels := make(map[string]bool)
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch x := n.(type) {
+			case *ast.FuncLit:
+				return false
+			case *ast.LabeledStmt:
+				labels[x.Label.Name] = true
+		}
+		return true
+	})
+	return labels
+}
+
+/// inserts 'cleanup' before each return and each branch to a label outside of 'labels'.
+func InsertBeforeExits(list *[]ast.Stmt, labels map[string]bool, cleanup func() ast.Stmt) {
+	stmts := make([]ast.Stmt, len(*list))
+	copy(stmts, *list)
+	for _, stmt := range stmts {
+		exits := false
+		inner := stmt
+		if labeled, is_labeled := inner.(*ast.LabeledStmt); is_labeled {
+			inner = labeled.Stmt
+		}
+		switch n := inner.(type) {
+			case *ast.ReturnStmt:
+				exits = true
+			case *ast.BranchStmt:
+				exits = n.Label != nil && !labels[n.Label.Name]
+			case *ast.BlockStmt:
+				InsertBeforeExits(&n.List, labels, cleanup)
+			case *ast.IfStmt:
+				InsertBeforeExits(&n.Body.List, labels, cleanup)
+				if n.Else != nil {
+					/// an else is always a block or another if.
+					else_list := []ast.Stmt{n.Else}
+					InsertBeforeExits(&else_list, labels, cleanup)
+				}
+			case *ast.ForStmt:
+				InsertBeforeExits(&n.Body.List, labels, cleanup)
+			case *ast.RangeStmt:
+				InsertBeforeExits(&n.Body.List, labels, cleanup)
+			case *ast.SwitchStmt:
+				InsertBeforeExits(&n.Body.List, labels, cleanup)
+			case *ast.TypeSwitchStmt:
+				InsertBeforeExits(&n.Body.List, labels, cleanup)
+			case *ast.SelectStmt:
+				InsertBeforeExits(&n.Body.List, labels, cleanup)
+			case *ast.CaseClause:
+				InsertBeforeExits(&n.Body, labels, cleanup)
+			case *ast.CommClause:
+				InsertBeforeExits(&n.Body, labels, cleanup)
+		}
+		if exits {
+			if i := FindStmt(*list, stmt); i != -1 {
+				*list = InsertStmt(*list, i, cleanup())
 			}
-			bm(n.Body, MutateAssignDefStmts)
-		
-		case *ast.IfStmt:
-			if n.Init != nil {
-				MutateAssignDefStmts(owner_list, index, n.Init, bm)
+		}
+	}
+}
+
+/// x.name(args...)
+func MakeMethodCall(x ast.Expr, name string, args ...ast.Expr) *ast.CallExpr {
+	call := new(ast.CallExpr)
+	call.Fun = MakeSelector(x, name)
+	call.Args = args
+	return call
+}
+
+/// var name any
+func MakeAnyDecl(name *ast.Ident) *ast.DeclStmt {
+	decl_stmt := MakeVarDecl([]*ast.Ident{name}, nil, nil)
+	decl_stmt.Decl.(*ast.GenDecl).Specs[0].(*ast.ValueSpec).Type = ast.NewIdent("any")
+	return decl_stmt
+}
+
+/// returns the name of the 'fmt' function being called, if any.
+func GetFmtFuncName(call *ast.CallExpr) string {
+	if sel, is_sel := call.Fun.(*ast.SelectorExpr); is_sel {
+		if pkg_iden, is_ident := sel.X.(*ast.Ident); is_ident {
+			if pkg_name, is_pkg := ASTCtxt.TypeInfo.Uses[pkg_iden].(*types.PkgName); is_pkg && pkg_name.Imported().Path()=="fmt" {
+				return sel.Sel.Name
 			}
-			bm(n.Body, MutateAssignDefStmts)
-			if n.Else != nil {
-				MutateAssignDefStmts(owner_list, index, n.Else, bm)
+		}
+	}
+	return ""
+}
+
+/// gets the SourceMod format specifier that matches Go's '%v' for a type, 0 if none.
+func GetSMFmtSpec(typ types.Type) byte {
+	if typ==nil {
+		return 0
+	} else if IsStringType(typ) {
+		return 's'
+	} else if IsCharType(typ) {
+		return 'c'
+	}
+	switch t := types.Unalias(typ).Underlying().(type) {
+		case *types.Basic:
+			switch {
+				case t.Info() & types.IsFloat > 0:
+					return 'f'
+				case t.Info() & (types.IsInteger | types.IsBoolean) > 0:
+					return 'd'
 			}
+	}
+	return 0
+}
+
+/**
+ * Maps Go's format verbs to SourceMod's format specifiers.
+ * %v -> by type, %q -> "%s", %t -> %d for bools.
+ * %t with a string and %T with a phrase + client are kept for SourceMod translations.
+ */
+func MakeSMFormat(format string, args []ast.Expr, pos token.Pos) string {
+	var sm_fmt strings.Builder
+	arg := 0
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			sm_fmt.WriteByte(format[i])
+			continue
+		}
+		j := i + 1
+		for j < len(format) && strings.IndexByte("+-# 0123456789.", format[j]) != -1 {
+			j++
+		}
+		if j >= len(format) {
+			PrintSrcGoErr(pos, "Incomplete format verb at end of format string.")
+			break
+		}
+		flags, verb := format[i+1 : j], format[j]
+		i = j
+		if verb=='%' {
+			sm_fmt.WriteString("%%")
+			continue
+		} else if arg >= len(args) {
+			PrintSrcGoErr(pos, fmt.Sprintf("Missing argument for format verb '%%%c'.", verb))
+			continue
+		} else if k := strings.IndexAny(flags, "+# "); k != -1 {
+			/// SourceMod only pads with '-' and '0'.
+			PrintSrcGoErr(args[arg].Pos(), fmt.Sprintf("Format flag '%c' in '%%%s%c' has no SourceMod equivalent.", flags[k], flags, verb))
+			arg++
+			continue
+		}
 		
-		case *ast.SwitchStmt:
-			MutateAssignDefStmts(owner_list, index, n.Init, bm)
-			bm(n.Body, MutateAssignDefStmts)
-		
-		case *ast.CaseClause:
-			for i, stmt := range n.Body {
-				MutateAssignDefStmts(&n.Body, i, stmt, bm)
+		typ := ASTCtxt.TypeInfo.TypeOf(args[arg])
+		spec := GetSMFmtSpec(typ)
+		switch verb {
+			case 'v':
+				if spec==0 {
+					PrintSrcGoErr(args[arg].Pos(), fmt.Sprintf("'%%v' has no SourceMod equivalent for type '%v'.", typ))
+				}
+			case 'd', 's', 'c', 'f':
+				spec = verb
+			case 'F':
+				spec = 'f'
+			case 'x', 'X', 'b':
+				if spec != 'd' && spec != 'c' {
+					PrintSrcGoErr(args[arg].Pos(), fmt.Sprintf("'%%%c' has no SourceMod equivalent for type '%v'.", verb, typ))
+				}
+				spec = verb
+			case 'q':
+				if spec=='c' {
+					sm_fmt.WriteString("'%" + flags + "c'")
+				} else {
+					sm_fmt.WriteString(`"%` + flags + `s"`)
+				}
+				arg++
+				continue
+			case 't':
+				if spec != 's' {
+					/// Go's bool verb, SourceMod bools print as integers.
+					spec = 'd'
+				} else {
+					spec = 't'
+				}
+			case 'T':
+				/// SourceMod's per-client translation, takes a phrase and client.
+				spec = 'T'
+				arg++
+			default:
+				PrintSrcGoErr(args[arg].Pos(), fmt.Sprintf("Format verb '%%%c' has no SourceMod equivalent.", verb))
+				arg++
+				continue
+		}
+		sm_fmt.WriteString("%" + flags + string(spec))
+		arg++
+	}
+	return sm_fmt.String()
+}
+
+/**
+ * fmt.Sprintf(format, args...) => Format(buffer, sizeof(buffer), format, args...)
+ * fmt.Printf(format, args...)  => PrintToServer(format, args...)
+ * fmt.Println(args...)         => PrintToServer("%s %d...", args...)
+ */
+func MakeFmtCall(call *ast.CallExpr, buffer ast.Expr) *ast.CallExpr {
+	var format string
+	var args []ast.Expr
+	switch GetFmtFuncName(call) {
+		case "Println":
+			specs := make([]string, 0)
+			for _, arg := range call.Args {
+				spec := GetSMFmtSpec(ASTCtxt.TypeInfo.TypeOf(arg))
+				if spec==0 {
+					PrintSrcGoErr(arg.Pos(), fmt.Sprintf("'fmt.Println' can't print type '%v' in SourcePawn.", ASTCtxt.TypeInfo.TypeOf(arg)))
+				}
+				specs = append(specs, "%" + string(spec))
 			}
-		
-		case *ast.RangeStmt:
-			bm(n.Body, MutateAssignDefStmts)
-		
-		case *ast.AssignStmt:
-			/**
-			 * a,b,c := f()
-			 * 
-			 * if not func ptr:
-			 *     var a,b,c type
-			 *     a = f(&b, &c)
-			 * 
-			 * if func ptr:
-			 *     var a,b,c type
-			 *     Call_StartFunction(nil, f)
-			 *     Call_PushCellRef(&b)
-			 *     Call_PushCellRef(&c)
-			 *     Call_Finish(&a)
-			 */
-			left_len, rite_len := len(n.Lhs), len(n.Rhs)
-			if rite_len==1 && left_len >= rite_len {
-				switch e := n.Rhs[0].(type) {
-					case *ast.CallExpr:
-						if iden, is_ident := e.Fun.(*ast.Ident); is_ident && iden.Name=="make" {
-							arg_len := len(e.Args)
-							switch {
-								case arg_len > 2:
-									PrintSrcGoErr(n.TokPos, "'make' has too many arguments.")
-								case arg_len < 2:
-									PrintSrcGoErr(n.TokPos, "'make' has too few arguments.")
-								case left_len > 1:
-									PrintSrcGoErr(n.TokPos, "'make' only returns one value.")
-							}
-							return
-						}
-						/// a func call returning multiple items as a decl + init.
-						switch n.Tok {
-							case token.DEFINE:
-								decl_stmt := new(ast.DeclStmt)
-								gen_decl := new(ast.GenDecl)
-								gen_decl.Tok = token.VAR
-								
-								/// first we get each name of a var and then map them to a type.
-								var_map := make(map[types.Type][]ast.Expr)
-								for _, e := range n.Lhs {
-									if type_expr := ASTCtxt.TypeInfo.TypeOf(e); type_expr != nil {
-										var_map[type_expr] = append(var_map[type_expr], e)
-									} else {
-										PrintSrcGoErr(n.TokPos, "Failed to expand assignment statement.")
-									}
-								}
-								
-								for key, val := range var_map {
-									val_spec := new(ast.ValueSpec)
-									for _, name := range val {
-										val_spec.Names = append(val_spec.Names, name.(*ast.Ident))
-									}
-									val_spec.Type = TypeToASTExpr(key)
-									gen_decl.Specs = append(gen_decl.Specs, val_spec)
-								}
-								
-								decl_stmt.Decl = gen_decl
-								*owner_list = InsertStmt(*owner_list, 0, decl_stmt)
-								n.Tok = token.ASSIGN
-						}
+			return MakeCall("PrintToServer", append([]ast.Expr{ MakeBasicLit(token.STRING, strconv.Quote(strings.Join(specs, " "))) }, call.Args...)...)
+		default:
+			if len(call.Args)==0 {
+				PrintSrcGoErr(call.Pos(), "missing format string.")
+				return call
+			}
+			args = call.Args[1:]
+			if tv, found := ASTCtxt.TypeInfo.Types[call.Args[0]]; found && tv.Value != nil && tv.Value.Kind()==constant.String && !call.Ellipsis.IsValid() {
+				format = MakeSMFormat(constant.StringVal(tv.Value), args, call.Pos())
+			} else {
+				/// non-constant formats and forwarded varargs are left as SourceMod formats.
+				var sm_call *ast.CallExpr
+				if buffer != nil {
+					sm_call = MakeCall("Format", append([]ast.Expr{ buffer, MakeCall("sizeof", buffer), call.Args[0] }, args...)...)
+				} else {
+					sm_call = MakeCall("PrintToServer", call.Args...)
 				}
+				sm_call.Ellipsis = call.Ellipsis
+				return sm_call
 			}
 	}
+	
+	if buffer != nil {
+		return MakeCall("Format", append([]ast.Expr{ buffer, MakeCall("sizeof", buffer), MakeBasicLit(token.STRING, strconv.Quote(format)) }, args...)...)
+	}
+	/// PrintToServer already ends with a newline.
+	format = strings.TrimSuffix(format, "\n")
+	return MakeCall("PrintToServer", append([]ast.Expr{ MakeBasicLit(token.STRING, strconv.Quote(format)) }, args...)...)
 }
 
-func MutateAssignStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+func MutateFmtCallStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	MutateFmtCallStmt(owner_list, s, s, bm)
+}
+
+/// 'anchor' is the statement in 'owner_list' that hoisted 'Format' calls go before, 's' can be nested in it, like a labeled loop.
+func MutateFmtCallStmt(owner_list *[]ast.Stmt, anchor, s ast.Stmt, bm BlockMutator) {
 	switch n := s.(type) {
 		case *ast.BlockStmt:
-			bm(n, MutateAssignStmts)
+			bm(n, MutateFmtCallStmts)
+		
+		case *ast.LabeledStmt:
+			MutateFmtCallStmt(owner_list, anchor, n.Stmt, bm)
 		
 		case *ast.ForStmt:
-			if n.Init != nil {
-				MutateAssignStmts(owner_list, index, n.Init, bm)
-			}
-			if n.Post != nil {
-				MutateAssignStmts(owner_list, index, n.Post, bm)
+			if HasSprintf(n.Cond) {
+				MoveForCondIntoBody(n)
 			}
-			bm(n.Body, MutateAssignStmts)
+			bm(n.Body, MutateFmtCallStmts)
 		
 		case *ast.IfStmt:
-			if n.Init != nil {
-				MutateAssignStmts(owner_list, index, n.Init, bm)
+			MutateSprintfExpr(owner_list, anchor, &n.Cond)
+			bm(n.Body, MutateFmtCallStmts)
+			if else_if, is_if := n.Else.(*ast.IfStmt); is_if && HasSprintf(else_if.Cond) {
+				/// else if cond => else { hoisted...; if cond }, the cond can't be run before the first one fails.
+				block := new(ast.BlockStmt)
+				block.List = append(block.List, else_if)
+				n.Else = block
 			}
-			bm(n.Body, MutateAssignStmts)
 			if n.Else != nil {
-				MutateAssignStmts(owner_list, index, n.Else, bm)
+				MutateFmtCallStmt(owner_list, anchor, n.Else, bm)
 			}
 		
 		case *ast.SwitchStmt:
-			MutateAssignStmts(owner_list, index, n.Init, bm)
-			bm(n.Body, MutateAssignStmts)
-		
-		case *ast.CaseClause:
-			for i, stmt := range n.Body {
-				MutateAssignStmts(&n.Body, i, stmt, bm)
-			}
-		
-		case *ast.RangeStmt:
-			bm(n.Body, MutateAssignStmts)
-		
-		case *ast.AssignStmt:
-			left_len, rite_len := len(n.Lhs), len(n.Rhs)
-			if rite_len==1 && left_len >= rite_len {
-				switch n.Tok {
-					case token.ASSIGN:
-						switch fn := n.Rhs[0].(type) {
-							case *ast.CallExpr:
-								/// a func call returning multiple items as a decl + init.
-								if IsFuncPtr(fn) {
-									ret_tmp := ast.NewIdent(fmt.Sprintf("fptr_temp%d", ASTCtxt.TmpVar))
-									ASTCtxt.TmpVar++
-									declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, n.Lhs[0], nil)
-									
-									retvals := make([]ast.Expr, 0)
-									retvals = append(retvals, ret_tmp)
-									for i:=1; i<left_len; i++ {
-										retvals = append(retvals, n.Lhs[i])
-									}
-									calls := ExpandFuncPtrCalls(fn, retvals, nil)
-									calls = InsertStmt(calls, 0, declstmt)
-									for i := len(calls)-1; i>=0; i-- {
-										*owner_list = InsertStmt(*owner_list, index, calls[i])
-									}
-									n.Lhs = n.Lhs[:1]
-									n.Rhs[0] = ret_tmp
-								} else {
-									/// transform the tuple return into a single return + pass by ref.
-									for i:=1; i<left_len; i++ {
-										switch e := n.Lhs[i].(type) {
-											case *ast.Ident:
-												fn.Args = append(fn.Args, MakeReference(e))
-										}
-									}
-									if left_len > 1 {
-										n.Lhs = n.Lhs[:1]
-									}
-								}
-							/*
-							case *ast.IndexExpr:
-								/// value, found = map[str]
-								/// Becomes: found = map.GetValue(str, &value)
-								if typ := ASTCtxt.TypeInfo.TypeOf(fn.X); typ != nil {
-									switch t := typ.(type) {
-										case *types.Map:
-											ret_tmp := ast.NewIdent(fmt.Sprintf("map_value%d", ASTCtxt.TmpVar))
-											ASTCtxt.TmpVar++
-											declstmt := MakeVarDecl([]*ast.Ident{ret_tmp}, nil, t.Elem())
-											
-											new_stmts := make([]ast.Stmt, 0)
-											map_access := new(ast.CallExpr)
-											switch elem_typ := t.Elem().(type) {
-												case 
-												map_access.Fun = ast.NewIdent("GetValue")
-											
-											map_access.Args = append(Call_StartFunction.Args, ast.NewIdent("nil"))
-											map_access.Args = append(Call_StartFunction.Args, x.Fun)
-								}
-							*/
-						}
+			MutateSprintfExpr(owner_list, anchor, &n.Tag)
+			for _, clause := range n.Body.List {
+				for _, expr := range clause.(*ast.CaseClause).List {
+					if HasSprintf(expr) {
+						PrintSrcGoErr(expr.Pos(), "'fmt.Sprintf' can't be used as a case, switch on a variable set to it.")
+					}
+				}
+			}
+			bm(n.Body, MutateFmtCallStmts)
+		
+		case *ast.CaseClause:
+			block := new(ast.BlockStmt)
+			block.List = n.Body
+			bm(block, MutateFmtCallStmts)
+			n.Body = block.List
+		
+		case *ast.RangeStmt:
+			MutateSprintfExpr(owner_list, anchor, &n.X)
+			bm(n.Body, MutateFmtCallStmts)
+		
+		case *ast.ReturnStmt:
+			for i := range n.Results {
+				MutateSprintfExpr(owner_list, anchor, &n.Results[i])
+			}
+		
+		case *ast.ExprStmt:
+			if call, is_call := n.X.(*ast.CallExpr); is_call {
+				switch GetFmtFuncName(call) {
+					case "Printf", "Println":
+						/// the format is made from the arg types first, then any 'Sprintf' args are hoisted.
+						n.X = MakeFmtCall(call, nil)
+				}
+			}
+			MutateSprintfExpr(owner_list, anchor, &n.X)
+		
+		case *ast.DeclStmt:
+			/// var s = fmt.Sprintf(...) | var s string = fmt.Sprintf(...)
+			gen_decl, is_gen := n.Decl.(*ast.GenDecl)
+			if !is_gen || gen_decl.Tok != token.VAR {
+				return
+			}
+			for _, spec := range gen_decl.Specs {
+				val_spec := spec.(*ast.ValueSpec)
+				if len(gen_decl.Specs)==1 && len(val_spec.Names)==1 && len(val_spec.Values)==1 && val_spec.Names[0].Name != "_" {
+					if call, is_call := val_spec.Values[0].(*ast.CallExpr); is_call && GetFmtFuncName(call)=="Sprintf" {
+						MutateSprintfFormat(owner_list, anchor, call, val_spec.Names[0], true)
+						return
+					}
+				}
+				for i := range val_spec.Values {
+					MutateSprintfExpr(owner_list, anchor, &val_spec.Values[i])
+				}
+			}
+		
+		case *ast.AssignStmt:
+			/// s := fmt.Sprintf(...) | s = fmt.Sprintf(...)
+			if len(n.Lhs)==1 && len(n.Rhs)==1 {
+				if call, is_call := n.Rhs[0].(*ast.CallExpr); is_call && GetFmtFuncName(call)=="Sprintf" {
+					iden, is_ident := n.Lhs[0].(*ast.Ident)
+					switch {
+						case n.Tok==token.DEFINE && is_ident && iden.Name != "_":
+							MutateSprintfFormat(owner_list, anchor, call, iden, true)
+							return
+						case n.Tok==token.ASSIGN && IsStringBufExpr(n.Lhs[0]):
+							MutateSprintfFormat(owner_list, anchor, call, n.Lhs[0], false)
+							return
+					}
 				}
 			}
+			for i := range n.Rhs {
+				MutateSprintfExpr(owner_list, anchor, &n.Rhs[i])
+			}
 	}
 }
 
-func MutateRangeStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+/// for init; cond; post {} => for init; ; post { if !(cond) { break } ... }, so the cond's hoisted statements run each loop.
+func MoveForCondIntoBody(n *ast.ForStmt) {
+	brk := new(ast.BranchStmt)
+	brk.Tok = token.BREAK
+	cond := new(ast.ParenExpr)
+	cond.X = n.Cond
+	not_cond := new(ast.UnaryExpr)
+	not_cond.Op = token.NOT
+	not_cond.X = cond
+	exit := new(ast.IfStmt)
+	exit.Cond = not_cond
+	exit.Body = new(ast.BlockStmt)
+	exit.Body.List = append(exit.Body.List, brk)
+	n.Body.List = InsertStmt(n.Body.List, 0, exit)
+	n.Cond = nil
+}
+
+/// replaces 'anchor' with 'Format' into 'buffer', declaring it first if 'declare' is set.
+func MutateSprintfFormat(owner_list *[]ast.Stmt, anchor ast.Stmt, call *ast.CallExpr, buffer ast.Expr, declare bool) {
+	format := MakeFmtCall(call, CloneLvalue(buffer))
+	for i := 3; i < len(format.Args); i++ {
+		MutateSprintfExpr(owner_list, anchor, &format.Args[i])
+	}
+	index := FindStmt(*owner_list, anchor)
+	if index == -1 {
+		return
+	}
+	(*owner_list)[index] = MakeExprStmt(format)
+	if declare {
+		*owner_list = InsertStmt(*owner_list, index, MakeStrBufDecl(ast.NewIdent(buffer.(*ast.Ident).Name)))
+	}
+}
+
+/// whether 'fmt.Sprintf' is called anywhere in 'n'.
+func HasSprintf(n ast.Node) bool {
+	found := false
+	if n != nil && !reflect.ValueOf(n).IsNil() {
+		ast.Inspect(n, func(n ast.Node) bool {
+			if call, is_call := n.(*ast.CallExpr); is_call && GetFmtFuncName(call)=="Sprintf" {
+				found = true
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+/// f(fmt.Sprintf(...)) => var str_temp# [StrBufLen]char; Format(str_temp#, sizeof(str_temp#), ...); f(str_temp#)
+func MutateSprintfExpr(owner_list *[]ast.Stmt, anchor ast.Stmt, e *ast.Expr) {
+	if e==nil || *e == nil || !HasSprintf(*e) {
+		return
+	}
+	/// inner calls go first so they're formatted before the calls that use them.
+	ReplaceExprs(reflect.ValueOf(e).Elem(), func(expr ast.Expr) ast.Expr {
+		call, is_call := expr.(*ast.CallExpr)
+		if !is_call || GetFmtFuncName(call) != "Sprintf" {
+			return expr
+		}
+		index := FindStmt(*owner_list, anchor)
+		if index == -1 {
+			PrintSrcGoErr(call.Pos(), "'fmt.Sprintf' can't be used here, set a variable to it first.")
+			return expr
+		}
+		str_tmp := ast.NewIdent(fmt.Sprintf("str_temp%d", ASTCtxt.TmpVar))
+		ASTCtxt.TmpVar++
+		*owner_list = InsertStmt(*owner_list, index, MakeStrBufDecl(str_tmp))
+		*owner_list = InsertStmt(*owner_list, index + 1, MakeExprStmt(MakeFmtCall(call, ast.NewIdent(str_tmp.Name))))
+		
+		/// the temp stands in for the call, it keeps its type and position for the formats around it.
+		tmp := ast.NewIdent(str_tmp.Name)
+		tmp.NamePos = call.Pos()
+		ASTCtxt.TypeInfo.Types[tmp] = types.TypeAndValue{Type: types.Typ[types.String]}
+		return tmp
+	})
+}
+
+/// returns the variadic param of a function and its 1-based param position for 'VFormat'.
+func GetVariadicParam(fn_type *ast.FuncType) (*ast.Ident, int) {
+	varpos := 0
+	for _, param := range fn_type.Params.List {
+		varpos += len(param.Names)
+		if _, is_variadic := param.Type.(*ast.Ellipsis); is_variadic && len(param.Names) > 0 {
+			return param.Names[len(param.Names)-1], varpos
+		}
+	}
+	return nil, 0
+}
+
+/// checks if a call is to a function like 'PrintToServer(format string, args ...any)'.
+func IsFormatFunc(call *ast.CallExpr) bool {
+	if sig, is_sig := ASTCtxt.TypeInfo.TypeOf(call.Fun).(*types.Signature); is_sig && sig.Variadic() && sig.Params().Len() > 1 {
+		return IsStringType(sig.Params().At(sig.Params().Len() - 2).Type())
+	}
+	return false
+}
+
+func MutateVariadicStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
 	switch n := s.(type) {
 		case *ast.BlockStmt:
-			bm(n, MutateRangeStmts)
-		
-		case *ast.EmptyStmt:
+			bm(n, MutateVariadicStmts)
 		
 		case *ast.ForStmt:
-			if n.Init != nil {
-				MutateRangeStmts(owner_list, index, n.Init, bm)
-			}
-			if n.Post != nil {
-				MutateRangeStmts(owner_list, index, n.Post, bm)
-			}
-			bm(n.Body, MutateRangeStmts)
+			bm(n.Body, MutateVariadicStmts)
 		
 		case *ast.IfStmt:
-			if n.Init != nil {
-				MutateRangeStmts(owner_list, index, n.Init, bm)
-			}
-			bm(n.Body, MutateRangeStmts)
+			bm(n.Body, MutateVariadicStmts)
 			if n.Else != nil {
-				MutateRangeStmts(owner_list, index, n.Else, bm)
+				MutateVariadicStmts(owner_list, index, n.Else, bm)
 			}
 		
 		case *ast.SwitchStmt:
-			MutateRangeStmts(owner_list, index, n.Init, bm)
-			bm(n.Body, MutateRangeStmts)
+			bm(n.Body, MutateVariadicStmts)
 		
 		case *ast.CaseClause:
 			for i, stmt := range n.Body {
-				MutateRangeStmts(&n.Body, i, stmt, bm)
+				MutateVariadicStmts(&n.Body, i, stmt, bm)
 			}
 		
 		case *ast.RangeStmt:
-			if n.Key != nil {
-				if iden, ok := n.Key.(*ast.Ident); ok && iden.Name=="_" {
-					n.Key = ast.NewIdent(fmt.Sprintf("%s_iter%d", ASTCtxt.CurrFunc.Name.Name, ASTCtxt.RangeIter))
-					ASTCtxt.RangeIter++
-				}
-			} else {
-				n.Key = ast.NewIdent(fmt.Sprintf("%s_iter%d", ASTCtxt.CurrFunc.Name.Name, ASTCtxt.RangeIter))
-				ASTCtxt.RangeIter++
+			bm(n.Body, MutateVariadicStmts)
+		
+		case *ast.ExprStmt:
+			MutateVarArgsExpr(owner_list, s, &n.X)
+		
+		case *ast.AssignStmt:
+			for i := range n.Rhs {
+				MutateVarArgsExpr(owner_list, s, &n.Rhs[i])
+			}
+		
+		case *ast.ReturnStmt:
+			for i := range n.Results {
+				MutateVarArgsExpr(owner_list, s, &n.Results[i])
+			}
+	}
+}
+
+/**
+ * func Log(format string, args ...any) {
+ *     Format(buffer, len(buffer), format, args...)   => VFormat(buffer, sizeof(buffer), format, 2)
+ *     PrintToServer(format, args...)                 => char str_temp#[N]; VFormat(str_temp#, sizeof(str_temp#), format, 2); PrintToServer("%s", str_temp#)
+ * }
+ */
+func MutateVarArgsExpr(owner_list *[]ast.Stmt, s ast.Stmt, e *ast.Expr) {
+	if e==nil || *e == nil {
+		return
+	}
+	switch n := (*e).(type) {
+		case *ast.CallExpr:
+			for i := range n.Args {
+				MutateVarArgsExpr(owner_list, s, &n.Args[i])
+			}
+			varargs, varpos := GetVariadicParam(ASTCtxt.CurrFunc.Type)
+			arg_count := len(n.Args)
+			if !n.Ellipsis.IsValid() || arg_count < 2 {
+				return
+			} else if iden, is_ident := n.Args[arg_count-1].(*ast.Ident); !is_ident || iden.Name != varargs.Name {
+				return
 			}
 			
-			if n.Value != nil {
-				if iden, ok := n.Value.(*ast.Ident); ok && iden.Name=="_" {
-					n.Value = nil
-				} else {
-					switch n.Tok {
-						case token.DEFINE:
-							decl_stmt := MakeVarDecl([]*ast.Ident{n.Value.(*ast.Ident)}, n.Value, nil)
-							n.Body.List = InsertStmt(n.Body.List, 0, decl_stmt)
-							
-							assign := MakeAssign(false)
-							assign.Lhs = append(assign.Lhs, n.Value)
-							
-							get_index := MakeIndex(n.Key, n.X)
-							assign.Rhs = append(assign.Rhs, get_index)
-							
-							n.Body.List = InsertStmt(n.Body.List, 1, assign)
-							n.Value = nil
-					}
-				}
+			format := n.Args[arg_count-2]
+			switch name := GetFuncName(n.Fun); {
+				case (name=="Format" || name=="FormatEx") && arg_count==4:
+					*e = MakeCall("VFormat", n.Args[0], n.Args[1], format, MakeBasicLit(token.INT, fmt.Sprintf("%d", varpos)))
+					return
+				case !IsFormatFunc(n):
+					PrintSrcGoErr(n.Pos(), "Variadic params can only be forwarded to format functions.")
+					return
 			}
-			bm(n.Body, MutateRangeStmts)
+			index := FindStmt(*owner_list, s)
+			if index == -1 {
+				PrintSrcGoErr(n.Pos(), "Variadic params can't be forwarded here.")
+				return
+			}
+			str_tmp := ast.NewIdent(fmt.Sprintf("str_temp%d", ASTCtxt.TmpVar))
+			ASTCtxt.TmpVar++
+			*owner_list = InsertStmt(*owner_list, index, MakeStrBufDecl(str_tmp))
+			vformat := MakeCall("VFormat", ast.NewIdent(str_tmp.Name), MakeCall("sizeof", ast.NewIdent(str_tmp.Name)), format, MakeBasicLit(token.INT, fmt.Sprintf("%d", varpos)))
+			*owner_list = InsertStmt(*owner_list, index + 1, MakeExprStmt(vformat))
+			n.Args = append(n.Args[:arg_count-2], MakeBasicLit(token.STRING, `"%s"`), ast.NewIdent(str_tmp.Name))
+			n.Ellipsis = token.NoPos
+		
+		case *ast.BinaryExpr:
+			MutateVarArgsExpr(owner_list, s, &n.X)
+			MutateVarArgsExpr(owner_list, s, &n.Y)
+		
+		case *ast.ParenExpr:
+			MutateVarArgsExpr(owner_list, s, &n.X)
+		
+		case *ast.UnaryExpr:
+			MutateVarArgsExpr(owner_list, s, &n.X)
 	}
 }
 
@@ -1297,6 +8153,287 @@ func MutateNoRetCallStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm Bloc
 	}
 }
 
+/// T{...} or &T{...} where T is a struct.
+func GetStructLit(expr ast.Expr) (*ast.CompositeLit, *types.Named) {
+	if unary, is_unary := expr.(*ast.UnaryExpr); is_unary && unary.Op==token.AND {
+		expr = unary.X
+	}
+	lit, is_lit := expr.(*ast.CompositeLit)
+	if !is_lit {
+		return nil, nil
+	}
+	if named, is_named := types.Unalias(ASTCtxt.TypeInfo.TypeOf(lit)).(*types.Named); is_named {
+		if _, is_struct := named.Underlying().(*types.Struct); is_struct {
+			return lit, named
+		}
+	}
+	return nil, nil
+}
+
+/// copies an lvalue like 'a.b[0]' so generated statements don't share nodes.
+func CloneLvalue(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+		case *ast.Ident:
+			return ast.NewIdent(e.Name)
+		case *ast.SelectorExpr:
+			sel := new(ast.SelectorExpr)
+			sel.X = CloneLvalue(e.X)
+			sel.Sel = ast.NewIdent(e.Sel.Name)
+			return sel
+		case *ast.IndexExpr:
+			return MakeIndex(e.Index, CloneLvalue(e.X))
+	}
+	return expr
+}
+
+func MakeSelector(x ast.Expr, name string) *ast.SelectorExpr {
+	sel := new(ast.SelectorExpr)
+	sel.X = CloneLvalue(x)
+	sel.Sel = ast.NewIdent(name)
+	return sel
+}
+
+/// for copy_iter# := 0; copy_iter# < N; copy_iter#++ { dest[copy_iter#] = value[copy_iter#] }
+func MakeArrayCopy(dest, value ast.Expr, length int64) *ast.ForStmt {
+	iter := fmt.Sprintf("copy_iter%d", ASTCtxt.TmpVar)
+	ASTCtxt.TmpVar++
+	
+	for_stmt := new(ast.ForStmt)
+	init := MakeAssign(true)
+	init.Lhs = append(init.Lhs, ast.NewIdent(iter))
+	init.Rhs = append(init.Rhs, MakeBasicLit(token.INT, "0"))
+	for_stmt.Init = init
+	
+	cond := new(ast.BinaryExpr)
+	cond.X = ast.NewIdent(iter)
+	cond.Op = token.LSS
+	cond.Y = MakeBasicLit(token.INT, fmt.Sprintf("%d", length))
+	for_stmt.Cond = cond
+	
+	post := new(ast.IncDecStmt)
+	post.X = ast.NewIdent(iter)
+	post.Tok = token.INC
+	for_stmt.Post = post
+	
+	copy_elem := MakeAssign(false)
+	copy_elem.Lhs = append(copy_elem.Lhs, MakeIndex(ast.NewIdent(iter), CloneLvalue(dest)))
+	copy_elem.Rhs = append(copy_elem.Rhs, MakeIndex(ast.NewIdent(iter), value))
+	for_stmt.Body = new(ast.BlockStmt)
+	for_stmt.Body.List = append(for_stmt.Body.List, copy_elem)
+	return for_stmt
+}
+
+/** p.Name = "x" => strcopy(p.Name, sizeof(p.Name), "x")
+ * p.Origin = o  => for (int copy_iter# = 0; copy_iter# < 3; copy_iter#++) p.Origin[copy_iter#] = o[copy_iter#]
+ * p.Origin = Vec3{1.0, 2.0, 3.0} => p.Origin[0] = 1.0; p.Origin[1] = 2.0; ...
+ */
+func MakeFieldAssigns(dest, value ast.Expr, typ types.Type) []ast.Stmt {
+	var stmts []ast.Stmt
+	if lit, is_lit := value.(*ast.CompositeLit); is_lit {
+		switch t := typ.Underlying().(type) {
+			case *types.Struct:
+				return MakeStructLitAssigns(dest, lit, t)
+			case *types.Array, *types.Slice:
+				var elem types.Type
+				if arr, is_arr := t.(*types.Array); is_arr {
+					elem = arr.Elem()
+				} else {
+					elem = t.(*types.Slice).Elem()
+				}
+				var idx int64
+				for _, elt := range lit.Elts {
+					if kv, is_kv := elt.(*ast.KeyValueExpr); is_kv {
+						if key := ASTCtxt.TypeInfo.Types[kv.Key].Value; key != nil {
+							idx, _ = constant.Int64Val(constant.ToInt(key))
+						}
+						elt = kv.Value
+					}
+					stmts = append(stmts, MakeFieldAssigns(MakeIndex(MakeBasicLit(token.INT, fmt.Sprintf("%d", idx)), CloneLvalue(dest)), elt, elem)...)
+					idx++
+				}
+				return stmts
+		}
+	}
+	
+	if IsStringType(typ) {
+		stmts = append(stmts, MakeExprStmt(MakeCall("strcopy", CloneLvalue(dest), MakeCall("sizeof", CloneLvalue(dest)), value)))
+	} else if arr, is_arr := typ.Underlying().(*types.Array); is_arr {
+		/// enum struct array fields can't be assigned whole.
+		stmts = append(stmts, MakeArrayCopy(dest, value, arr.Len()))
+	} else {
+		assign := MakeAssign(false)
+		assign.Lhs = append(assign.Lhs, CloneLvalue(dest))
+		assign.Rhs = append(assign.Rhs, value)
+		stmts = append(stmts, assign)
+	}
+	return stmts
+}
+
+/// T{A: a, B: b} => dest.A = a; dest.B = b; omitted fields stay zeroed.
+func MakeStructLitAssigns(dest ast.Expr, lit *ast.CompositeLit, struc *types.Struct) []ast.Stmt {
+	var stmts []ast.Stmt
+	for i, elt := range lit.Elts {
+		var field *types.Var
+		value := elt
+		if kv, is_kv := elt.(*ast.KeyValueExpr); is_kv {
+			name := kv.Key.(*ast.Ident).Name
+			for f := 0; f < struc.NumFields(); f++ {
+				if struc.Field(f).Name()==name {
+					field = struc.Field(f)
+					break
+				}
+			}
+			value = kv.Value
+		} else if i < struc.NumFields() {
+			field = struc.Field(i)
+		}
+		if field==nil {
+			continue
+		}
+		stmts = append(stmts, MakeFieldAssigns(MakeSelector(dest, field.Name()), value, field.Type())...)
+	}
+	return stmts
+}
+
+/** Enum structs can't be initialized with keyed fields inside functions:
+ * 
+ * p := PlayerInfo{Origin: o, Name: "x"}  => PlayerInfo p; p.Origin[...] = o[...]; strcopy(p.Name, sizeof(p.Name), "x");
+ * p = PlayerInfo{Health: 100}            => PlayerInfo lit_temp#; lit_temp#.Health = 100; p = lit_temp#;
+ * f(PlayerInfo{Health: 100})             => PlayerInfo lit_temp#; lit_temp#.Health = 100; f(lit_temp#);
+ */
+func MutateStructLitStmts(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutator) {
+	switch n := s.(type) {
+		case *ast.BlockStmt:
+			bm(n, MutateStructLitStmts)
+		
+		case *ast.ForStmt:
+			bm(n.Body, MutateStructLitStmts)
+		
+		case *ast.IfStmt:
+			bm(n.Body, MutateStructLitStmts)
+			if n.Else != nil {
+				MutateStructLitStmts(owner_list, index, n.Else, bm)
+			}
+		
+		case *ast.SwitchStmt:
+			bm(n.Body, MutateStructLitStmts)
+		
+		case *ast.CaseClause:
+			for i, stmt := range n.Body {
+				MutateStructLitStmts(&n.Body, i, stmt, bm)
+			}
+		
+		case *ast.RangeStmt:
+			bm(n.Body, MutateStructLitStmts)
+		
+		case *ast.ExprStmt:
+			MutateStructLitExpr(owner_list, s, &n.X)
+		
+		case *ast.ReturnStmt:
+			for i := range n.Results {
+				MutateStructLitExpr(owner_list, s, &n.Results[i])
+			}
+		
+		case *ast.AssignStmt:
+			if len(n.Lhs)==1 && len(n.Rhs)==1 {
+				if lit, named := GetStructLit(n.Rhs[0]); lit != nil {
+					var new_stmts []ast.Stmt
+					switch n.Tok {
+						case token.DEFINE:
+							iden, is_ident := n.Lhs[0].(*ast.Ident)
+							if !is_ident || iden.Name=="_" {
+								break
+							}
+							new_stmts = append(new_stmts, MakeVarDecl([]*ast.Ident{iden}, nil, named))
+							new_stmts = append(new_stmts, MakeStructLitAssigns(iden, lit, named.Underlying().(*types.Struct))...)
+						case token.ASSIGN:
+							/// through a temp so omitted fields are zeroed and 'p = T{A: p.B}' works.
+							lit_tmp := ast.NewIdent(fmt.Sprintf("lit_temp%d", ASTCtxt.TmpVar))
+							ASTCtxt.TmpVar++
+							new_stmts = append(new_stmts, MakeVarDecl([]*ast.Ident{lit_tmp}, nil, named))
+							new_stmts = append(new_stmts, MakeStructLitAssigns(lit_tmp, lit, named.Underlying().(*types.Struct))...)
+							n.Rhs[0] = ast.NewIdent(lit_tmp.Name)
+							new_stmts = append(new_stmts, n)
+					}
+					if len(new_stmts) > 0 {
+						if i := FindStmt(*owner_list, s); i != -1 {
+							(*owner_list)[i] = new_stmts[0]
+							for k:=1; k<len(new_stmts); k++ {
+								*owner_list = InsertStmt(*owner_list, i + k, new_stmts[k])
+							}
+						}
+						return
+					}
+				}
+			}
+			for i := range n.Rhs {
+				MutateStructLitExpr(owner_list, s, &n.Rhs[i])
+			}
+		
+		case *ast.DeclStmt:
+			gen_decl, is_gen := n.Decl.(*ast.GenDecl)
+			if !is_gen || gen_decl.Tok != token.VAR {
+				return
+			}
+			var field_stmts []ast.Stmt
+			for _, spec := range gen_decl.Specs {
+				val_spec := spec.(*ast.ValueSpec)
+				if len(val_spec.Names) != len(val_spec.Values) {
+					continue
+				}
+				lowered := false
+				for v, value := range val_spec.Values {
+					if lit, named := GetStructLit(value); lit != nil {
+						field_stmts = append(field_stmts, MakeStructLitAssigns(val_spec.Names[v], lit, named.Underlying().(*types.Struct))...)
+						if val_spec.Type==nil {
+							val_spec.Type = TypeToASTExpr(named)
+						}
+						lowered = true
+					}
+				}
+				if lowered {
+					/// the var decl zeroes the struct, the fields are set after.
+					val_spec.Values = nil
+				}
+			}
+			if i := FindStmt(*owner_list, s); i != -1 {
+				for k, stmt := range field_stmts {
+					*owner_list = InsertStmt(*owner_list, i + 1 + k, stmt)
+				}
+			}
+	}
+}
+
+/// struct literals as call args or returns are built in a temp first.
+func MutateStructLitExpr(owner_list *[]ast.Stmt, s ast.Stmt, e *ast.Expr) {
+	if e==nil || *e == nil {
+		return
+	}
+	if lit, named := GetStructLit(*e); lit != nil {
+		index := FindStmt(*owner_list, s)
+		if index == -1 {
+			return
+		}
+		lit_tmp := ast.NewIdent(fmt.Sprintf("lit_temp%d", ASTCtxt.TmpVar))
+		ASTCtxt.TmpVar++
+		new_stmts := []ast.Stmt{ MakeVarDecl([]*ast.Ident{lit_tmp}, nil, named) }
+		new_stmts = append(new_stmts, MakeStructLitAssigns(lit_tmp, lit, named.Underlying().(*types.Struct))...)
+		for k, stmt := range new_stmts {
+			*owner_list = InsertStmt(*owner_list, index + k, stmt)
+		}
+		*e = ast.NewIdent(lit_tmp.Name)
+		return
+	}
+	switch n := (*e).(type) {
+		case *ast.CallExpr:
+			for i := range n.Args {
+				MutateStructLitExpr(owner_list, s, &n.Args[i])
+			}
+		case *ast.ParenExpr:
+			MutateStructLitExpr(owner_list, s, &n.X)
+	}
+}
+
 /// a &^ b ==> a & ^(b) ==> a & ~(b) in sp.
 func MutateAndNotExpr(file *ast.File) {
 	ast.Inspect(file, func(n ast.Node) bool {
@@ -1369,8 +8506,29 @@ func MutateFuncLit(owner_list *[]ast.Stmt, index int, s ast.Stmt, bm BlockMutato
 			bm(n.Body, MutateFuncLit)
 		
 		case *ast.ExprStmt:
+			/// go2sp.After(delay, func() {...}), the local vars it captures are passed along by 'MutateGoCall'.
+			if call, is_call := n.X.(*ast.CallExpr); is_call && IsGo2SPAfter(call) && len(call.Args)==2 {
+				if lit, is_lit := call.Args[1].(*ast.FuncLit); is_lit {
+					fn_name, captures := HoistFuncLit(lit)
+					call.Args[1] = fn_name
+					if len(captures) > 0 {
+						ASTCtxt.Captures[fn_name.Name] = captures
+					}
+				}
+			}
 			MutateFuncLitExprs(&n.X)
 		
+		case *ast.GoStmt:
+			/// go func() {...}(), the local vars it captures are passed as extra args.
+			if lit, is_lit := n.Call.Fun.(*ast.FuncLit); is_lit {
+				fn_name, captures := HoistFuncLit(lit)
+				n.Call.Fun = fn_name
+				n.Call.Args = append(n.Call.Args, captures...)
+			}
+			for i := range n.Call.Args {
+				MutateFuncLitExprs(&n.Call.Args[i])
+			}
+		
 		case *ast.ReturnStmt:
 			for i := range n.Results {
 				MutateFuncLitExprs(&n.Results[i])
@@ -1425,18 +8583,67 @@ func MutateFuncLitExprs(e *ast.Expr) {
 			MutateFuncLitExprs(&n.X)
 		
 		case *ast.FuncLit:
-			tmp_func_name := ast.NewIdent(fmt.Sprintf("SrcGoTmpFunc%d", ASTCtxt.TmpFunc))
-			ASTCtxt.TmpFunc++
-			fn_decl := new(ast.FuncDecl)
-			fn_decl.Name = tmp_func_name
-			fn_decl.Type = n.Type
-			n.Type = nil
-			fn_decl.Body = n.Body
-			n.Body = nil
-			*e = tmp_func_name
-			
-			ASTCtxt.NewDecls = append(ASTCtxt.NewDecls, fn_decl)
+			fn_name, captures := HoistFuncLit(n)
+			if len(captures) > 0 {
+				/// nothing passes them to a plain function value.
+				PrintSrcGoErr(captures[0].Pos(), fmt.Sprintf("function literal captures local var '%s', only 'go' and 'go2sp.After' can pass captured vars.", captures[0].(*ast.Ident).Name))
+			}
+			*e = fn_name
+	}
+}
+
+/// hoists a function literal into 'SrcGoTmpFunc#', the local vars it captures become extra params and are returned as the args to pass.
+func HoistFuncLit(lit *ast.FuncLit) (*ast.Ident, []ast.Expr) {
+	pkg := ASTCtxt.TypeInfo.Defs[ASTCtxt.CurrFunc.Name].Pkg()
+	captured := make(map[*types.Var]bool)
+	captures := make([]ast.Expr, 0)
+	fn_decl := new(ast.FuncDecl)
+	fn_decl.Name = ast.NewIdent(fmt.Sprintf("SrcGoTmpFunc%d", ASTCtxt.TmpFunc))
+	ASTCtxt.TmpFunc++
+	fn_decl.Type = lit.Type
+	fn_decl.Body = lit.Body
+	
+	sig := ASTCtxt.TypeInfo.TypeOf(lit).(*types.Signature)
+	params := make([]*types.Var, 0)
+	for i := 0; i < sig.Params().Len(); i++ {
+		params = append(params, sig.Params().At(i))
 	}
+	ast.Inspect(lit.Body, func(n ast.Node) bool {
+		iden, is_ident := n.(*ast.Ident)
+		if !is_ident {
+			return true
+		}
+		obj, is_var := ASTCtxt.TypeInfo.Uses[iden].(*types.Var)
+		if !is_var || captured[obj] || obj.IsField() || obj.Parent()==nil || obj.Parent()==obj.Pkg().Scope() {
+			return true
+		} else if obj.Pos() >= lit.Pos() && obj.Pos() < lit.End() {
+			return true
+		}
+		captured[obj] = true
+		param := ast.NewIdent(obj.Name())
+		param_var := types.NewVar(token.NoPos, pkg, obj.Name(), obj.Type())
+		ASTCtxt.TypeInfo.Defs[param] = param_var
+		field := new(ast.Field)
+		field.Names = append(field.Names, param)
+		field.Type = MakeTypeArgExpr(obj.Type())
+		fn_decl.Type.Params.List = append(fn_decl.Type.Params.List, field)
+		params = append(params, param_var)
+		
+		capture := ast.NewIdent(obj.Name())
+		capture.NamePos = iden.Pos()
+		ASTCtxt.TypeInfo.Uses[capture] = obj
+		captures = append(captures, capture)
+		return true
+	})
+	
+	fn_obj := types.NewFunc(token.NoPos, pkg, fn_decl.Name.Name, types.NewSignatureType(nil, nil, nil, types.NewTuple(params...), sig.Results(), false))
+	ASTCtxt.TypeInfo.Defs[fn_decl.Name] = fn_obj
+	ASTCtxt.FuncMap[fn_decl.Name.Name] = fn_decl
+	ASTCtxt.NewDecls = append(ASTCtxt.NewDecls, fn_decl)
+	
+	fn_name := ast.NewIdent(fn_decl.Name.Name)
+	ASTCtxt.TypeInfo.Uses[fn_name] = fn_obj
+	return fn_name, captures
 }
 
 /*
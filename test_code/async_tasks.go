@@ -0,0 +1,36 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+//go2sp:async
+func Intro(client int) {
+	hp := GetClientHealth(client)
+	Sleep(2.0)
+	SetEntityHealth(client, hp + 100)
+}
+
+//go2sp:async 8
+func Countdown(seconds int) Task {
+	for i := seconds; i > 0; i-- {
+		if GetClientCount(true)==0 {
+			return 0
+		}
+		PrintToChatAll("starting in %d", i)
+		Sleep(1.0)
+	}
+	WaitFrames(10)
+	PrintToChatAll("go!")
+	return 0
+}
+
+
+func main() {
+	intro := Intro(1)
+	count := Countdown(3)
+	if !CancelTask(intro) {
+		CancelTask(count)
+	}
+}
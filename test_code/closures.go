@@ -0,0 +1,34 @@
+package main
+
+import (
+	"sourcemod"
+	"datapack"
+	"go2sp"
+)
+
+
+func Announce(client int, msg string) {
+	PrintToChat(client, "%s", msg)
+}
+
+func OnClientPutInServer(client int) {
+	hp := 100
+	go func() {
+		SetEntityHealth(client, hp)
+	}()
+	
+	go2sp.After(2.0, func() {
+		Announce(client, "welcome")
+	})
+	
+	//go2sp:delay 0.5
+	go Announce(client, "hi")
+}
+
+func main() {
+	for client := 1; client <= MaxClients; client++ {
+		if IsClientInGame(client) {
+			OnClientPutInServer(client)
+		}
+	}
+}
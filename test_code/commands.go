@@ -0,0 +1,25 @@
+package main
+
+import (
+	"sourcemod"
+	"sdktools"
+)
+
+
+//go2sp:command sm_slay admin=ADMFLAG_SLAY filter=COMMAND_FILTER_ALIVE desc="slays a target"
+func Slay(client Entity, target Entity, damage int, silent bool) {
+	SlapPlayer(int(target), damage, !silent)
+	if !silent {
+		PrintToChatAll("%N was slain", target)
+	}
+}
+
+//go2sp:command sm_speed
+func SetSpeed(client int, speed float, reason string) Action {
+	ReplyToCommand(client, "speed %f: %s", speed, reason)
+	return Plugin_Handled
+}
+
+
+func main() {
+}
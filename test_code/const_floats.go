@@ -0,0 +1,23 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+const (
+	MAX_HP     = 300
+	SPEED      = 1.5
+	SPEED_HALF float = SPEED / 2
+)
+
+
+func main() {
+	var scale float = MAX_HP * 2
+	var ratio float = (MAX_HP + 100) / 4
+	var neg float = -MAX_HP
+	speed := SPEED * 2
+	half := SPEED_HALF
+	hp := MAX_HP
+	PrintToServer("%f %f %f %f %f %d", scale, ratio, neg, speed, half, hp)
+}
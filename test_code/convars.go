@@ -0,0 +1,35 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+type Config struct {
+	Enabled bool   `cvar:"sm_gravmod_enabled" default:"1" desc:"Enable the gravity modifier" min:"0" max:"1"`
+	Scale   float  `cvar:"sm_gravmod_scale" default:"0.5" desc:"Gravity scale for alive players" min:"0.1" max:"2.0"`
+	Rounds  int    `cvar:"sm_gravmod_rounds" default:"3" desc:"Rounds before the modifier resets" min:"1"`
+	Message string `cvar:"sm_gravmod_message" default:"Low gravity!" desc:"Message shown on spawn" flags:"FCVAR_NOTIFY"`
+}
+
+var cfg Config
+
+
+func OnPlayerSpawn(client int) {
+	if !cfg.Enabled {
+		return
+	}
+	SetEntityGravity(client, cfg.Scale)
+	PrintToChat(client, cfg.Message)
+}
+
+func OnRoundEnd(round int) {
+	if round >= cfg.Rounds {
+		cfg.Enabled = false
+		cfg.Scale = 1.0
+	}
+}
+
+
+func main() {
+}
@@ -0,0 +1,21 @@
+/// transpiled with: go2sp --debug-runtime=trace -f test_code/debug_trace.go
+package main
+
+import (
+	"sourcemod"
+)
+
+
+var g_scores [MAXPLAYERS+1]int
+
+
+func GetScore(client int) int {
+	if client <= 0 {
+		return 0
+	}
+	return g_scores[client] + GetClientTeam(client)
+}
+
+func main() {
+	PrintToServer("%d", GetScore(1))
+}
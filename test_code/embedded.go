@@ -0,0 +1,30 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+type Base struct {
+	Health int
+}
+
+func (b *Base) Damage(amount int) {
+	b.Health -= amount
+}
+
+type Boss struct {
+	Base
+	Rage int
+}
+
+var g_boss Boss
+
+
+func main() {
+	g_boss.Base.Health = 500
+	g_boss.Health += 100
+	g_boss.Damage(50)
+	g_boss.Base.Damage(25)
+	PrintToServer("%d %d", g_boss.Health, g_boss.Rage)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"sourcemod"
+	"errors"
+	"fmt"
+)
+
+
+func Parse(s string) (int, error) {
+	if len(s)==0 {
+		return 0, errors.New("empty string")
+	}
+	n := StringToInt(s, 10)
+	if n < 0 {
+		return 0, fmt.Errorf("negative value %d", n)
+	}
+	return n, nil
+}
+
+func MustParse(s string) int {
+	n, err := Parse(s)
+	if err != nil {
+		panic("bad number")
+	}
+	return n
+}
+
+
+func main() {
+	n, err := Parse("12")
+	if err != nil {
+		panic("can't parse the config")
+	}
+	PrintToServer("%d %d", n, MustParse("7"))
+	
+	if _, err := Parse("-3"); err != nil {
+		PrintToServer("rejected")
+	}
+}
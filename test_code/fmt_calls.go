@@ -0,0 +1,39 @@
+package main
+
+import (
+	"sourcemod"
+	"fmt"
+)
+
+
+func Describe(name string, hp int, speed float, alive bool) {
+	msg := fmt.Sprintf("%s has %d hp (%v) %5.2f %q %t %%", name, hp, speed, speed, name, alive)
+	var title = fmt.Sprintf("[%-8s]", name)
+	var tag string = fmt.Sprintf("#%03d", hp)
+	fmt.Printf("%s %s %s\n", title, tag, msg)
+	fmt.Println(fmt.Sprintf("%s/%d", name, hp), hp)
+	
+	if StrEqual(fmt.Sprintf("%d", hp), "100", true) {
+		PrintToServer("full health")
+	} else if StrEqual(fmt.Sprintf("%d", hp/2), "25", true) {
+		PrintToServer("quarter health")
+	}
+	
+	for i := 0; StrContains(fmt.Sprintf("%d", i), "9", true) == -1; i++ {
+		PrintToServer("%d", i)
+	}
+	
+	switch fmt.Sprintf("%s", name) {
+		case "scout":
+			PrintToServer("fast")
+	}
+}
+
+func Label(client int) string {
+	return fmt.Sprintf("player %d", client)
+}
+
+func main() {
+	Describe("scout", 125, 400.0, true)
+	PrintToServer("%s", Label(1))
+}
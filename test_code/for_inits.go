@@ -0,0 +1,30 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+var g_nums [8]int
+
+
+func Reverse(n int) {
+	for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+		g_nums[i], g_nums[j] = g_nums[j], g_nums[i]
+	}
+}
+
+func Fib(n int) float {
+	a, b := 0, 1
+	for i, scale := 0, 1.5; i < n; i++ {
+		a, b = b, a+b
+		scale *= 2.0
+	}
+	return float(a)
+}
+
+
+func main() {
+	Reverse(len(g_nums))
+	PrintToServer("%f", Fib(10))
+}
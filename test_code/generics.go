@@ -0,0 +1,40 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+type Number interface {
+	int | float
+}
+
+type Box[T any] struct {
+	Value T
+	Set   bool
+}
+
+func (b *Box[T]) Store(value T) {
+	b.Value = value
+	b.Set = true
+}
+
+func Max[T Number](a, b T) T {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+var (
+	g_box    Box[Vec3]
+	g_counts Box[int]
+)
+
+
+func main() {
+	var origin Vec3
+	g_box.Store(origin)
+	g_counts.Store(Max(3, 4))
+	PrintToServer("%d %f", g_counts.Value, Max(1.0, 2.5))
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+const MAX_SLOTS = 4
+
+var (
+	g_slots    [MAX_SLOTS]int
+	g_max      = GetMaxHumanPlayers()
+	g_half     = g_max / 2
+	g_name     string
+	g_arraylen = len(g_slots)
+)
+
+
+func main() {
+	PrintToServer("%d %d %d", g_max, g_half, g_arraylen)
+}
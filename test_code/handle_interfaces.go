@@ -0,0 +1,35 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+type Boss interface {
+	OnRage()
+}
+
+/// a Handle based type, stored in the interface as is.
+type Hale StringMap
+
+func (h Hale) OnRage() {
+	StringMap(h).SetValue("rage", 0)
+}
+
+type Vagineer struct {
+	client int
+}
+
+func (v *Vagineer) OnRage() {
+	PrintToChat(v.client, "rage")
+}
+
+var g_vaghs [MAXPLAYERS+1]Vagineer
+
+
+func main() {
+	var b Boss = Hale(CreateTrie())
+	b.OnRage()
+	b = &g_vaghs[1]
+	b.OnRage()
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+func ReadConfig(kv KeyValues) int {
+	if ok := kv.JumpToKey("settings", false); ok {
+		return kv.GetNum("rounds", 3)
+	} else if found := kv.JumpToKey("defaults", false); found {
+		return kv.GetNum("rounds", 1)
+	}
+	
+	switch team := GetRandomInt(2, 3); team {
+		case 2:
+			return 10
+		case 3:
+			return 20
+	}
+	
+	switch n := GetRandomInt(0, 9); {
+		case n < 5:
+			return 0
+		default:
+			return n
+	}
+}
+
+
+func main() {
+	kv := CreateKeyValues("config", "", "")
+	PrintToServer("%d", ReadConfig(kv))
+}
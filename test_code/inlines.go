@@ -0,0 +1,43 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+type Stats struct {
+	kills, deaths int
+}
+
+//go2sp:inline
+func (s *Stats) Ratio() float {
+	if s.deaths==0 {
+		return float(s.kills)
+	}
+	return float(s.kills) / float(s.deaths)
+}
+
+//go2sp:inline
+func Clamp(x, lo, hi int) int {
+	if x < lo {
+		return lo
+	} else if x > hi {
+		return hi
+	}
+	return x
+}
+
+var g_stats [MAXPLAYERS+1]Stats
+
+
+func OnGameFrame() {
+	for client := 1; client <= MaxClients; client++ {
+		hp := Clamp(GetClientHealth(client), 0, 300)
+		ratio := g_stats[client].Ratio()
+		PrintToServer("%d %f", hp, ratio)
+	}
+}
+
+
+func main() {
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+type Part struct {
+	Health int
+}
+
+func (p *Part) Think(timer Timer, data any) Action {
+	p.Health--
+	return Plugin_Continue
+}
+
+type Boss struct {
+	Head, Body Part
+	Arms       [2]Part
+}
+
+type Player struct {
+	Score int
+}
+
+func (p Player) OnSpawn(client int) {
+	PrintToServer("%d spawned with %d", client, p.Score)
+}
+
+var (
+	g_boss    Boss
+	g_players [MAXPLAYERS+1]Player
+)
+
+
+func main() {
+	CreateTimer(1.0, g_boss.Head.Think, nil, 0)
+	CreateTimer(1.0, g_boss.Body.Think, nil, 0)
+	CreateTimer(1.0, g_boss.Arms[1].Think, nil, 0)
+	RequestFrame(g_players[1].OnSpawn, 1)
+	for client := 1; client <= MaxClients; client++ {
+		RequestFrame(g_players[client].OnSpawn, client)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+func Split(n int) (lo, hi int) {
+	if n < 0 {
+		return
+	}
+	lo, hi = n % 10, n / 10
+	return
+}
+
+func ScreenPos(x, y float) (xpos, ypos float) {
+	xpos = x * 0.5
+	if y > 1.0 {
+		return xpos, 1.0
+	}
+	ypos = y * 0.5
+	return
+}
+
+
+func main() {
+	lo, hi := Split(42)
+	x, y := ScreenPos(0.2, 0.8)
+	PrintToServer("%d %d %f %f", lo, hi, x, y)
+}
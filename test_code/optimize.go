@@ -0,0 +1,50 @@
+/// transpiled with: go2sp -O -f test_code/optimize.go
+package main
+
+import (
+	"sourcemod"
+	"tf2"
+	"cstrike"
+)
+
+
+const (
+	DEG_PER_RAD = 180.0 / FLOAT_PI
+	MAX_HITS    = 1 << 4
+)
+
+var (
+	g_hits   [MAX_HITS]int
+	g_unused int
+)
+
+
+func Angle(x, y float) float {
+	return (ArcTangent2(x, y) + FLOAT_PI) * DEG_PER_RAD
+}
+
+/// nothing calls it, so '-O' drops it.
+func UnusedHelper() int {
+	return g_unused
+}
+
+func CountHits(client int) int {
+	if !!IsClientInGame(client) {
+		return g_hits[client & (MAX_HITS-1)]
+		PrintToServer("unreachable")
+	}
+	return 0
+}
+
+func TF2_CalcIsAttackCritical(client, weapon int, weaponname string, result *bool) Action {
+	*result = CountHits(client) > 3
+	return Plugin_Changed
+}
+
+func CS_OnTerminateRound(delay *float, reason *CSRoundEndReason) Action {
+	return Plugin_Continue
+}
+
+func main() {
+	PrintToServer("%f", Angle(1.0, 2.0))
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+type Pair struct {
+	lo, hi int
+}
+
+var (
+	g_arr   [8]int
+	g_pairs [4]Pair
+)
+
+
+func main() {
+	a, b := 1, 2
+	a, b = b, a+b
+	
+	i := 0
+	i, g_arr[i] = 5, 7
+	
+	j := 1
+	j, g_pairs[j].hi = 3, a
+	
+	for x, y := 0, 10; x < y; x, y = x+1, y-1 {
+		PrintToServer("%d %d", x, y)
+	}
+	PrintToServer("%d %d %d %d %d", a, b, i, g_arr[0], g_pairs[1].hi)
+	
+	var motd, rules [1024]char
+	motd, rules = rules, motd
+	PrintToServer("%s %s", motd, rules)
+}
@@ -0,0 +1,27 @@
+/// transpiled with: go2sp --profile -f test_code/profiling.go
+package main
+
+import (
+	"sourcemod"
+)
+
+
+func Fib(n int) int {
+	if n < 2 {
+		return n
+	}
+	return Fib(n-1) + Fib(n-2)
+}
+
+func MinMax(a, b int) (int, int) {
+	if a < b {
+		return a, b
+	}
+	return b, a
+}
+
+
+func main() {
+	lo, hi := MinMax(Fib(10), Fib(8))
+	PrintToServer("%d %d", lo, hi)
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+var (
+	g_scores StringMap
+	g_queue  ArrayList
+)
+
+
+func HasScoreAbove(limit int) bool {
+	for _, score := range g_scores {
+		if int(score) > limit {
+			return true
+		}
+	}
+	return false
+}
+
+func SumScores() int {
+	sum := 0
+	for name, score := range g_scores {
+		if StrEqual(name, "skip", true) {
+			continue
+		} else if int(score) < 0 {
+			break
+		}
+		sum += int(score)
+	}
+	return sum
+}
+
+func main() {
+	g_scores = CreateTrie()
+	g_queue = CreateArray(1, 0)
+	for i, v := range g_queue {
+		PrintToServer("%d %d", i, v)
+	}
+	for i, c := range "héllo" {
+		PrintToServer("%d %d", i, c)
+	}
+	PrintToServer("%d %d", HasScoreAbove(10), SumScores())
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+func Greet(name string) {
+	var msg string = "hello " + name
+	msg += "!"
+	if name == "admin" {
+		PrintToServer("%s, %d chars", msg, len(msg))
+	} else if name < "m" {
+		PrintToServer("early: %s", msg)
+	}
+	var buf [64]char
+	var copied string = name
+	var title string = "boss"
+	PrintToServer("%d %d %s %s", len(buf), len(name), copied, title)
+	title = "the " + title
+	from_buf := string(buf)
+	PrintToServer("%s %s", title, from_buf)
+}
+
+func Shout(name string) string {
+	if name + "!" == "admin!" {
+		return "ADMIN"
+	}
+	PrintToServer("%d", len(name + "!!"))
+	return name + "!"
+}
+
+
+func main() {
+	Greet("admin")
+	Shout("bob")
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+type PlayerInfo struct {
+	Name   string
+	Origin Vec3
+	Weaps  [3]int
+	Health int
+}
+
+var g_spawns = [2]PlayerInfo{
+	{"red", Vec3{0.0, 0.0, 64.0}, [3]int{1, 2, 3}, 100},
+	{"blu", Vec3{128.0, 0.0, 64.0}, [3]int{4, 5, 6}, 125},
+}
+
+
+func MakeInfo(name string, o Vec3, w [3]int) PlayerInfo {
+	p := PlayerInfo{Origin: o, Weaps: w, Name: name}
+	return p
+}
+
+
+func main() {
+	var o Vec3
+	var w [3]int
+	info := MakeInfo("boss", o, w)
+	PrintToServer("%s %d %d", info.Name, info.Health, g_spawns[1].Health)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+const BASE = 10
+
+
+func Classify(n, limit int) int {
+	switch n {
+		case BASE:
+			return 0
+		case limit, limit + 1:
+			return 1
+		case BASE * 2:
+			fallthrough
+		case BASE * 3:
+			return 2
+	}
+	return 3
+}
+
+func Team(name string) int {
+	switch name {
+		case "red":
+			return 2
+		case "blu", "blue":
+			return 3
+	}
+	return 0
+}
+
+func Grade(n int) int {
+	switch n {
+		case 1:
+			return 100
+		case 2, 3:
+			return 50
+		default:
+			return 0
+	}
+}
+
+
+func main() {
+	PrintToServer("%d %d %d", Classify(GetRandomInt(0, 40), 5), Team("red"), Grade(2))
+}
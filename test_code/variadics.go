@@ -0,0 +1,22 @@
+package main
+
+import (
+	"sourcemod"
+)
+
+
+func LogAll(format string, args ...any) {
+	var buf [256]char
+	VFormat(buf, len(buf), format, 2)
+	PrintToServer("%s", buf)
+}
+
+func Announce(format string, args ...any) {
+	LogAll(format, args...)
+}
+
+
+func main() {
+	LogAll("%d players, %s", 4, "arena")
+	Announce("round %d", 2)
+}
@@ -15,6 +15,8 @@ package main
 
 import "sourcemod"
 
+//go2sp:forward TF2_CalcIsAttackCritical TF2_OnGetHoliday TF2_OnIsHolidayActive TF2_OnConditionAdded TF2_OnConditionRemoved TF2_OnWaitingForPlayersStart TF2_OnWaitingForPlayersEnd TF2_OnPlayerTeleport
+
 
 type (
 	TFClassType int
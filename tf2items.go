@@ -16,6 +16,8 @@ package main
 
 import "sourcemod"
 
+//go2sp:forward TF2Items_OnGiveNamedItem TF2Items_OnGiveNamedItem_Post
+
 const (
 	OVERRIDE_CLASSNAME =    (1 << 0)    // Item will override the entity's classname.
 	OVERRIDE_ITEM_DEF =     (1 << 1)    // Item will override the item's definition index.